@@ -9,7 +9,7 @@ import (
 	"demo/service/registry"
 	"demo/service/shopping"
 	"demo/sidecar"
-	"io/ioutil"
+	"time"
 )
 
 func main() {
@@ -17,10 +17,9 @@ func main() {
 	mmq := mq.MemoryMqInstance()
 	sidecarFactory := sidecar.NewAllInOneFactory(mmq)
 
-	// 启动监控系统
+	// 启动监控系统，monitor_pipeline.yaml修改后自动重新加载
 	monitorSys := monitor.NewSystem(config.NewYamlFactory())
-	conf, _ := ioutil.ReadFile("monitor_pipeline.yaml")
-	monitorSys.LoadConf(string(conf))
+	monitorSys.WatchConf("monitor_pipeline.yaml", 5*time.Second)
 	monitorSys.Start()
 	defer monitorSys.Shutdown()
 
@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// confWatcher 定期检查pipeline配置文件，文件内容变化时重新加载
+type confWatcher struct {
+	path     string
+	interval time.Duration
+	content  []byte
+	reload   func(confs ...string) error
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// WatchConf 加载配置文件path中的所有pipeline，并每隔interval检查一次文件，变化时调用Reload
+// yaml文件中多个pipeline配置以---分隔，重复调用时停止之前的监听
+func (s *System) WatchConf(path string, interval time.Duration) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Reload(splitConfs(string(content))...); err != nil {
+		return err
+	}
+	watcher := &confWatcher{
+		path:     path,
+		interval: interval,
+		content:  content,
+		reload:   s.Reload,
+		done:     make(chan struct{}),
+	}
+	s.mu.Lock()
+	old := s.watcher
+	s.watcher = watcher
+	s.mu.Unlock()
+	if old != nil {
+		old.stop()
+	}
+	watcher.wg.Add(1)
+	go watcher.run()
+	return nil
+}
+
+func (w *confWatcher) run() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// check 文件内容变化时重新加载，加载失败时保留原pipeline，等待下次修改
+func (w *confWatcher) check() {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		fmt.Printf("watch conf %s err %s\n", w.path, err.Error())
+		return
+	}
+	if bytes.Equal(content, w.content) {
+		return
+	}
+	w.content = content
+	if err := w.reload(splitConfs(string(content))...); err != nil {
+		fmt.Printf("reload conf %s err %s\n", w.path, err.Error())
+		return
+	}
+	fmt.Printf("reload conf %s success\n", w.path)
+}
+
+func (w *confWatcher) stop() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+// splitConfs 按照yaml的文档分隔符---拆分配置，忽略空文档
+func splitConfs(content string) []string {
+	var confs []string
+	var doc []string
+	flush := func() {
+		if conf := strings.Join(doc, "\n"); strings.TrimSpace(conf) != "" {
+			confs = append(confs, conf)
+		}
+		doc = nil
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimRight(line, " \r") == "---" {
+			flush()
+			continue
+		}
+		doc = append(doc, line)
+	}
+	flush()
+	return confs
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"demo/monitor/plugin"
+	"reflect"
 )
 
 type Type uint8
@@ -46,3 +47,9 @@ type Pipeline struct {
 func (p *Pipeline) Load(conf string) error {
 	return p.loadConf(conf, p)
 }
+
+// Equal 比较两个pipeline配置的内容是否相同，用于热加载时判断pipeline是否需要重启
+func (p Pipeline) Equal(other Pipeline) bool {
+	p.loadConf, other.loadConf = nil, nil
+	return reflect.DeepEqual(p, other)
+}
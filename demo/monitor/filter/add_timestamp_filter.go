@@ -19,11 +19,11 @@ func (a *AddTimestampFilter) Uninstall() {
 func (a *AddTimestampFilter) SetContext(ctx plugin.Context) {
 }
 
-func (a *AddTimestampFilter) Filter(event *plugin.Event) *plugin.Event {
+func (a *AddTimestampFilter) Filter(event *plugin.Event) []*plugin.Event {
 	re, ok := event.Payload().(*model.MonitorRecord)
 	if !ok {
-		return event
+		return []*plugin.Event{event}
 	}
 	re.Timestamp = time.Now().Unix()
-	return plugin.NewEvent(re)
+	return []*plugin.Event{event.Derive(re)}
 }
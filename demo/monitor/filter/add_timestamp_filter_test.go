@@ -22,7 +22,7 @@ func TestAddTimestampFilter(t *testing.T) {
 	re.Endpoint = "192.168.0.1:80"
 	re.Type = model.RecvResp
 	event := plugin.NewEvent(re)
-	event = filterPlugin.Filter(event)
+	event = filterPlugin.Filter(event)[0]
 	if event.Payload().(*model.MonitorRecord).Timestamp == 0 {
 		t.Error("timestamp add failed")
 	}
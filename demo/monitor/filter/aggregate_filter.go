@@ -0,0 +1,241 @@
+package filter
+
+import (
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 默认统计窗口
+const defaultAggregateWindow = 10 * time.Second
+
+// AggregateFilter 按endpoint统计窗口内的请求数、QPS、错误率和请求到响应的时延，窗口结束时输出model.MetricsRecord
+// 时延通过请求id关联同一endpoint的请求记录和响应记录计算，记录时间优先取model.TimeHeader，其次为MonitorRecord的Timestamp
+// context配置：window为窗口大小，默认10s；slide为滑动步长，默认等于window即滚动窗口，window需为slide的整数倍；
+// requestType和responseType为参与统计的请求和响应类型，默认为send_req和recv_resp；
+// timeout为等待响应的最长时间，默认等于window；passThrough为true时原始事件继续向后传递
+// 窗口由事件时间驱动，后续事件的时间越过窗口结束时间时才输出该窗口的统计结果，已输出窗口的迟到事件会被忽略
+type AggregateFilter struct {
+	window       time.Duration
+	slide        time.Duration
+	timeout      time.Duration
+	requestType  model.Type
+	responseType model.Type
+	passThrough  bool
+	mu           sync.Mutex
+	endpoints    map[string]*endpointStats
+	next         int64 // 下一个待输出窗口的结束时间，unix纳秒
+}
+
+// endpointStats endpoint的统计数据，按照slide切分为多个bucket，一个窗口由连续的多个bucket组成
+type endpointStats struct {
+	buckets map[int64]*bucket    // key为bucket的开始时间
+	pending map[string]time.Time // 等待响应的请求，key为请求id，value为请求时间
+}
+
+type bucket struct {
+	requests   int
+	responses  int
+	errors     int
+	latencies  int // 关联到请求的响应数
+	latencySum time.Duration
+	maxLatency time.Duration
+}
+
+func (a *AggregateFilter) Install() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.endpoints = make(map[string]*endpointStats)
+	a.next = 0
+}
+
+func (a *AggregateFilter) Uninstall() {
+}
+
+func (a *AggregateFilter) SetContext(ctx plugin.Context) {
+	a.window = defaultAggregateWindow
+	if window, ok := ctx.GetDuration("window"); ok && window > 0 {
+		a.window = window
+	}
+	a.slide = a.window
+	if slide, ok := ctx.GetDuration("slide"); ok && slide > 0 && slide <= a.window && a.window%slide == 0 {
+		a.slide = slide
+	}
+	a.timeout = a.window
+	if timeout, ok := ctx.GetDuration("timeout"); ok && timeout > 0 {
+		a.timeout = timeout
+	}
+	a.requestType, a.responseType = model.SendReq, model.RecvResp
+	if requestType, ok := ctx.GetString("requestType"); ok {
+		a.requestType = model.Type(requestType)
+	}
+	if responseType, ok := ctx.GetString("responseType"); ok {
+		a.responseType = model.Type(responseType)
+	}
+	a.passThrough, _ = ctx.GetBool("passThrough")
+}
+
+func (a *AggregateFilter) Filter(event *plugin.Event) []*plugin.Event {
+	re, ok := event.Payload().(*model.MonitorRecord)
+	if !ok {
+		return []*plugin.Event{event}
+	}
+	at := timeOf(event, re)
+	a.mu.Lock()
+	events := a.advance(at.UnixNano())
+	a.add(re, at)
+	a.mu.Unlock()
+	if a.passThrough {
+		events = append(events, event)
+	}
+	return events
+}
+
+// timeOf 记录产生的时间
+func timeOf(event *plugin.Event, re *model.MonitorRecord) time.Time {
+	if value, ok := event.Header(model.TimeHeader); ok {
+		if nanos, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(0, nanos)
+		}
+	}
+	if re.Timestamp > 0 {
+		return time.Unix(re.Timestamp, 0)
+	}
+	return time.Now()
+}
+
+// advance 输出结束时间不晚于at的所有窗口
+func (a *AggregateFilter) advance(at int64) []*plugin.Event {
+	slide := int64(a.slide)
+	if a.next == 0 {
+		a.next = at - at%slide + slide
+		return nil
+	}
+	var events []*plugin.Event
+	for a.next <= at {
+		events = append(events, a.emit(a.next)...)
+		a.next += slide
+		// 没有数据时直接跳到at所在的bucket，避免长时间空闲后逐个空窗口推进
+		if len(a.endpoints) == 0 && a.next <= at {
+			a.next = at - at%slide + slide
+		}
+	}
+	return events
+}
+
+// emit 输出[end-window, end)窗口的统计结果，并清理之后的窗口不再需要的数据
+func (a *AggregateFilter) emit(end int64) []*plugin.Event {
+	start := end - int64(a.window)
+	expired := start + int64(a.slide) // 下一个窗口的开始时间
+	names := make([]string, 0, len(a.endpoints))
+	for name := range a.endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var events []*plugin.Event
+	for _, name := range names {
+		stats := a.endpoints[name]
+		if record := a.summarize(name, stats, start, end); record != nil {
+			events = append(events, plugin.NewEvent(record).AddHeader(model.EndpointHeader, name))
+		}
+		for bucketStart := range stats.buckets {
+			if bucketStart < expired {
+				delete(stats.buckets, bucketStart)
+			}
+		}
+		for reqId, sent := range stats.pending {
+			if end-sent.UnixNano() >= int64(a.timeout) {
+				delete(stats.pending, reqId)
+			}
+		}
+		if len(stats.buckets) == 0 && len(stats.pending) == 0 {
+			delete(a.endpoints, name)
+		}
+	}
+	return events
+}
+
+func (a *AggregateFilter) summarize(name string, stats *endpointStats, start, end int64) *model.MetricsRecord {
+	total := &bucket{}
+	for bucketStart, b := range stats.buckets {
+		if bucketStart < start || bucketStart >= end {
+			continue
+		}
+		total.requests += b.requests
+		total.responses += b.responses
+		total.errors += b.errors
+		total.latencies += b.latencies
+		total.latencySum += b.latencySum
+		if b.maxLatency > total.maxLatency {
+			total.maxLatency = b.maxLatency
+		}
+	}
+	if total.requests == 0 && total.responses == 0 {
+		return nil
+	}
+	record := model.NewMetricsRecord()
+	record.Endpoint = name
+	record.WindowStart = time.Unix(0, start).UnixMilli()
+	record.WindowEnd = time.Unix(0, end).UnixMilli()
+	record.Requests = total.requests
+	record.Responses = total.responses
+	record.Errors = total.errors
+	record.Qps = float64(total.requests) / a.window.Seconds()
+	if total.responses > 0 {
+		record.ErrorRatio = float64(total.errors) / float64(total.responses)
+	}
+	if total.latencies > 0 {
+		record.AvgLatency = milliseconds(total.latencySum / time.Duration(total.latencies))
+		record.MaxLatency = milliseconds(total.maxLatency)
+	}
+	return record
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// add 将记录计入所在的bucket，属于已输出窗口的记录会被忽略
+func (a *AggregateFilter) add(re *model.MonitorRecord, at time.Time) {
+	if re.Type != a.requestType && re.Type != a.responseType {
+		return
+	}
+	nanos := at.UnixNano()
+	bucketStart := nanos - nanos%int64(a.slide)
+	if bucketStart < a.next-int64(a.slide) {
+		return
+	}
+	stats, ok := a.endpoints[re.Endpoint]
+	if !ok {
+		stats = &endpointStats{buckets: make(map[int64]*bucket), pending: make(map[string]time.Time)}
+		a.endpoints[re.Endpoint] = stats
+	}
+	b, ok := stats.buckets[bucketStart]
+	if !ok {
+		b = &bucket{}
+		stats.buckets[bucketStart] = b
+	}
+	if re.Type == a.requestType {
+		b.requests++
+		if re.ReqId != "" {
+			stats.pending[re.ReqId] = at
+		}
+		return
+	}
+	b.responses++
+	if re.Status >= 500 {
+		b.errors++
+	}
+	if sent, ok := stats.pending[re.ReqId]; ok && re.ReqId != "" {
+		delete(stats.pending, re.ReqId)
+		latency := at.Sub(sent)
+		b.latencies++
+		b.latencySum += latency
+		if latency > b.maxLatency {
+			b.maxLatency = latency
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// recordEvent 生成时间为base+offset的记录事件
+func recordEvent(base time.Time, offset time.Duration, endpoint string, typ model.Type, reqId string, status int) *plugin.Event {
+	re := model.NewMonitoryRecord()
+	re.Endpoint, re.Type, re.ReqId, re.Status = endpoint, typ, reqId, status
+	return plugin.NewEvent(re).AddHeader(model.TimeHeader, strconv.FormatInt(base.Add(offset).UnixNano(), 10))
+}
+
+func metricsOf(t *testing.T, events []*plugin.Event) []*model.MetricsRecord {
+	var records []*model.MetricsRecord
+	for _, event := range events {
+		record, ok := event.Payload().(*model.MetricsRecord)
+		if !ok {
+			t.Fatalf("want *model.MetricsRecord got %T", event.Payload())
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAggregateFilterTumbling(t *testing.T) {
+	ctx := plugin.Context{"window": "1s"}
+	filterPlugin, err := NewPlugin(config.Filter{Name: "filter0", PluginType: "aggregate", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filterPlugin.Install()
+	base := time.Unix(1000, 0)
+	inputs := []*plugin.Event{
+		recordEvent(base, 0, "a", model.SendReq, "1", 0),
+		recordEvent(base, 100*time.Millisecond, "a", model.SendReq, "2", 0),
+		recordEvent(base, 200*time.Millisecond, "b", model.SendReq, "1", 0),
+		recordEvent(base, 300*time.Millisecond, "a", model.RecvResp, "1", 200),
+		recordEvent(base, 500*time.Millisecond, "a", model.RecvResp, "2", 500),
+		recordEvent(base, 600*time.Millisecond, "a", model.RecvReq, "3", 0), // 不参与统计的类型
+	}
+	for _, event := range inputs {
+		if events := filterPlugin.Filter(event); len(events) != 0 {
+			t.Fatalf("want no output before window end, got %d", len(events))
+		}
+	}
+	// 越过窗口结束时间时输出上一个窗口
+	records := metricsOf(t, filterPlugin.Filter(recordEvent(base, 1200*time.Millisecond, "b", model.RecvResp, "1", 200)))
+	if len(records) != 2 {
+		t.Fatalf("want 2 metrics records got %d", len(records))
+	}
+	a := records[0]
+	if a.Endpoint != "a" || a.Requests != 2 || a.Responses != 2 || a.Errors != 1 || a.Qps != 2 || a.ErrorRatio != 0.5 {
+		t.Errorf("unexpected metrics of a %+v", a)
+	}
+	if a.AvgLatency != 350 || a.MaxLatency != 400 || a.WindowStart != 1000000 || a.WindowEnd != 1001000 {
+		t.Errorf("unexpected latency or window of a %+v", a)
+	}
+	if b := records[1]; b.Endpoint != "b" || b.Requests != 1 || b.Responses != 0 {
+		t.Errorf("unexpected metrics of b %+v", b)
+	}
+
+	// b的响应跨窗口关联到请求，迟到的记录被忽略
+	filterPlugin.Filter(recordEvent(base, 900*time.Millisecond, "b", model.SendReq, "2", 0))
+	records = metricsOf(t, filterPlugin.Filter(recordEvent(base, 5*time.Second, "a", model.SendReq, "4", 0)))
+	if len(records) != 1 || records[0].Endpoint != "b" || records[0].Requests != 0 || records[0].Responses != 1 || records[0].AvgLatency != 1000 {
+		t.Errorf("unexpected metrics %+v", records)
+	}
+}
+
+func TestAggregateFilterSliding(t *testing.T) {
+	filterPlugin := &AggregateFilter{}
+	filterPlugin.SetContext(plugin.Context{"window": "2s", "slide": "1s", "passThrough": "true"})
+	filterPlugin.Install()
+	base := time.Unix(2000, 0)
+	var records []*model.MetricsRecord
+	for i := 0; i < 4; i++ {
+		events := filterPlugin.Filter(recordEvent(base, time.Duration(i)*time.Second, "a", model.SendReq, strconv.Itoa(i), 0))
+		if _, ok := events[len(events)-1].Payload().(*model.MonitorRecord); !ok {
+			t.Fatal("want original event passed through")
+		}
+		records = append(records, metricsOf(t, events[:len(events)-1])...)
+	}
+	// 窗口[1999,2001)、[2000,2002)、[2001,2003)
+	want := []int{1, 2, 2}
+	if len(records) != len(want) {
+		t.Fatalf("want %d metrics records got %d", len(want), len(records))
+	}
+	for i, record := range records {
+		if record.Requests != want[i] || record.Qps != float64(want[i])/2 {
+			t.Errorf("window %d want %d requests got %+v", i, want[i], record)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+解释器模式
+*/
+
+var (
+	ErrInvalidCondition = errors.New("invalid condition")
+)
+
+// Condition 条件表达式，对事件求值
+// 语法：
+//
+//	expr       := and ( ("||" | "or") and )*
+//	and        := not ( ("&&" | "and") not )*
+//	not        := ("!" | "not") not | "(" expr ")" | comparison
+//	comparison := operand [ ("==" | "!=" | ">" | ">=" | "<" | "<=" | "=~" | "!~") operand ]
+//	operand    := 字段名 | 'string' | "string" | number | true | false
+//
+// 字段名通过FieldOf查找；两边都是数字时按数值比较，否则按字符串比较；=~和!~的右边必须是正则字符串；
+// 单独的operand为字段存在且不是空串、0或false时为真；字段不存在时比较结果为假
+type Condition interface {
+	Interpret(event *plugin.Event) bool
+	// Fields 表达式引用的字段名
+	Fields() []string
+}
+
+// ParseCondition 解析条件表达式
+func ParseCondition(expression string) (Condition, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isEnd() {
+		return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidCondition, p.peek().text)
+	}
+	return cond, nil
+}
+
+// OrExpression 逻辑或
+type OrExpression struct {
+	left, right Condition
+}
+
+func (o *OrExpression) Interpret(event *plugin.Event) bool {
+	return o.left.Interpret(event) || o.right.Interpret(event)
+}
+
+func (o *OrExpression) Fields() []string {
+	return append(o.left.Fields(), o.right.Fields()...)
+}
+
+// AndExpression 逻辑与
+type AndExpression struct {
+	left, right Condition
+}
+
+func (a *AndExpression) Interpret(event *plugin.Event) bool {
+	return a.left.Interpret(event) && a.right.Interpret(event)
+}
+
+func (a *AndExpression) Fields() []string {
+	return append(a.left.Fields(), a.right.Fields()...)
+}
+
+// NotExpression 逻辑非
+type NotExpression struct {
+	cond Condition
+}
+
+func (n *NotExpression) Interpret(event *plugin.Event) bool {
+	return !n.cond.Interpret(event)
+}
+
+func (n *NotExpression) Fields() []string {
+	return n.cond.Fields()
+}
+
+// CompareExpression 比较表达式
+type CompareExpression struct {
+	left, right operand
+	op          string
+}
+
+func (c *CompareExpression) Interpret(event *plugin.Event) bool {
+	left, ok := c.left.value(event)
+	if !ok {
+		return false
+	}
+	right, ok := c.right.value(event)
+	if !ok {
+		return false
+	}
+	lf, lErr := strconv.ParseFloat(left, 64)
+	rf, rErr := strconv.ParseFloat(right, 64)
+	if lErr == nil && rErr == nil {
+		return compare(c.op, lf < rf, lf == rf)
+	}
+	return compare(c.op, left < right, left == right)
+}
+
+func (c *CompareExpression) Fields() []string {
+	return fieldsOf(c.left, c.right)
+}
+
+func compare(op string, less, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	default:
+		return !less
+	}
+}
+
+// MatchExpression 正则匹配表达式
+type MatchExpression struct {
+	left    operand
+	pattern *regexp.Regexp
+	isNot   bool
+}
+
+func (m *MatchExpression) Interpret(event *plugin.Event) bool {
+	value, ok := m.left.value(event)
+	if !ok {
+		return false
+	}
+	return m.pattern.MatchString(value) != m.isNot
+}
+
+func (m *MatchExpression) Fields() []string {
+	return fieldsOf(m.left)
+}
+
+// TruthyExpression 单独的operand
+type TruthyExpression struct {
+	operand operand
+}
+
+func (t *TruthyExpression) Interpret(event *plugin.Event) bool {
+	value, ok := t.operand.value(event)
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+func (t *TruthyExpression) Fields() []string {
+	return fieldsOf(t.operand)
+}
+
+// operand 比较的操作数，统一转换为字符串
+type operand interface {
+	value(event *plugin.Event) (string, bool)
+}
+
+type literal string
+
+func (l literal) value(event *plugin.Event) (string, bool) {
+	return string(l), true
+}
+
+type field string
+
+// fieldsOf 操作数中的字段名
+func fieldsOf(operands ...operand) []string {
+	var fields []string
+	for _, o := range operands {
+		if f, ok := o.(field); ok {
+			fields = append(fields, string(f))
+		}
+	}
+	return fields
+}
+
+func (f field) value(event *plugin.Event) (string, bool) {
+	value, ok := FieldOf(event, string(f))
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+type tokenKind int
+
+const (
+	identToken tokenKind = iota
+	stringToken
+	numberToken
+	opToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// 按长度优先排列，保证先匹配双字符的操作符
+var operators = []string{"&&", "||", "==", "!=", ">=", "<=", "=~", "!~", ">", "<", "!", "(", ")"}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) && runes[j+1] == r {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidCondition)
+			}
+			tokens = append(tokens, token{kind: stringToken, text: sb.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: numberToken, text: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: identToken, text: string(runes[i:j])})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: opToken, text: op})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("%w: unexpected character %c", ErrInvalidCondition, r)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// conditionParser 递归下降解析器
+type conditionParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *conditionParser) isEnd() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *conditionParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// accept 下一个token为操作符或关键字之一时消费它
+func (p *conditionParser) accept(texts ...string) (string, bool) {
+	if p.isEnd() {
+		return "", false
+	}
+	t := p.peek()
+	for _, text := range texts {
+		isKeyword := t.kind == identToken && strings.EqualFold(t.text, text)
+		if (t.kind == opToken && t.text == text) || isKeyword {
+			p.pos++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *conditionParser) parseOr() (Condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrExpression{left: left, right: right}
+	}
+}
+
+func (p *conditionParser) parseAnd() (Condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndExpression{left: left, right: right}
+	}
+}
+
+func (p *conditionParser) parseNot() (Condition, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpression{cond: cond}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidCondition)
+		}
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (Condition, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", ">=", "<=", ">", "<", "=~", "!~")
+	if !ok {
+		return &TruthyExpression{operand: left}, nil
+	}
+	if op == "=~" || op == "!~" {
+		if p.isEnd() || p.peek().kind != stringToken {
+			return nil, fmt.Errorf("%w: %s requires a pattern string", ErrInvalidCondition, op)
+		}
+		pattern, err := regexp.Compile(p.peek().text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCondition, err.Error())
+		}
+		p.pos++
+		return &MatchExpression{left: left, pattern: pattern, isNot: op == "!~"}, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &CompareExpression{left: left, right: right, op: op}, nil
+}
+
+func (p *conditionParser) parseOperand() (operand, error) {
+	if p.isEnd() {
+		return nil, fmt.Errorf("%w: unexpected end", ErrInvalidCondition)
+	}
+	t := p.peek()
+	switch {
+	case t.kind == stringToken || t.kind == numberToken:
+		p.pos++
+		return literal(t.text), nil
+	case t.kind == identToken && (strings.EqualFold(t.text, "true") || strings.EqualFold(t.text, "false")):
+		p.pos++
+		return literal(strings.ToLower(t.text)), nil
+	case t.kind == identToken && !isKeyword(t.text):
+		p.pos++
+		return field(t.text), nil
+	}
+	return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidCondition, t.text)
+}
+
+func isKeyword(text string) bool {
+	switch strings.ToLower(text) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
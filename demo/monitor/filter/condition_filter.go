@@ -0,0 +1,36 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"fmt"
+)
+
+// ConditionFilter 只保留满足条件表达式的事件，表达式语法见Condition
+// context配置：expression为条件表达式，如 type == 'recv_req' && endpoint =~ '^192\.168\.0\.'
+// 表达式不合法时不过滤任何事件
+type ConditionFilter struct {
+	condition Condition
+}
+
+func (c *ConditionFilter) Install() {
+}
+
+func (c *ConditionFilter) Uninstall() {
+}
+
+func (c *ConditionFilter) SetContext(ctx plugin.Context) {
+	expression, _ := ctx.GetString("expression")
+	condition, err := ParseCondition(expression)
+	if err != nil {
+		fmt.Printf("condition filter invalid expression %s: %s\n", expression, err.Error())
+		return
+	}
+	c.condition = condition
+}
+
+func (c *ConditionFilter) Filter(event *plugin.Event) []*plugin.Event {
+	if c.condition != nil && !c.condition.Interpret(event) {
+		return nil
+	}
+	return []*plugin.Event{event}
+}
@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConditionFilter(t *testing.T) {
+	ctx := plugin.Context{"expression": `type == 'recv_req' and endpoint =~ '^192\.168\.0\.'`}
+	filterPlugin, err := NewPlugin(config.Filter{Name: "filter0", PluginType: "condition", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filterPlugin.Install()
+	cases := []struct {
+		endpoint string
+		typ      model.Type
+		want     int
+	}{
+		{"192.168.0.1:80", model.RecvReq, 1},
+		{"192.168.1.1:80", model.RecvReq, 0},
+		{"192.168.0.1:80", model.SendReq, 0},
+	}
+	for _, c := range cases {
+		re := model.NewMonitoryRecord()
+		re.Endpoint, re.Type = c.endpoint, c.typ
+		if events := filterPlugin.Filter(plugin.NewEvent(re)); len(events) != c.want {
+			t.Errorf("%s %s want %d events got %d", c.endpoint, c.typ, c.want, len(events))
+		}
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	re := model.NewMonitoryRecord()
+	re.Endpoint, re.Type, re.Timestamp = "10.0.0.1:80", model.SendResp, 100
+	event := plugin.NewEvent(re).AddHeader("status", "500")
+	cases := map[string]bool{
+		"timestamp > 20":                             true, // 按数值比较
+		"timestamp >= 100 && timestamp <= 100":       true,
+		"status != 200 || endpoint == '10.0.0.1:80'": true,
+		`!(type == "send_resp")`:                     false,
+		"not status =~ '^5' or timestamp < 0":        false,
+		"endpoint !~ '^192'":                         true,
+		"status":                                     true,
+		"missing == ''":                              false, // 字段不存在时比较为假
+		"!missing":                                   true,
+		"status == 500 or status == 1 and false":     true, // and优先于or
+	}
+	for expression, want := range cases {
+		cond, err := ParseCondition(expression)
+		if err != nil {
+			t.Errorf("parse %s err %v", expression, err)
+			continue
+		}
+		if got := cond.Interpret(event); got != want {
+			t.Errorf("%s want %v got %v", expression, want, got)
+		}
+	}
+	cond, _ := ParseCondition("!(status == 500) || endpoint =~ '^10' && errorRatio > 0.1 and 'a'")
+	if fields := cond.Fields(); strings.Join(fields, ",") != "status,endpoint,errorRatio" {
+		t.Errorf("want fields status,endpoint,errorRatio got %v", fields)
+	}
+	for _, expression := range []string{"", "a ==", "(a == 1", "a =~ b", "a =~ '['", "a == 1 b", "a & b", "'abc"} {
+		if _, err := ParseCondition(expression); !errors.Is(err, ErrInvalidCondition) {
+			t.Errorf("parse %q want ErrInvalidCondition got %v", expression, err)
+		}
+	}
+}
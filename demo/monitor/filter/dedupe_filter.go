@@ -0,0 +1,79 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认去重时间窗口
+const defaultDedupeWindow = time.Minute
+
+// DedupeFilter 去重，丢弃时间窗口内key相同的重复事件
+// context配置：fields为组成key的字段，多个以逗号分隔，默认为payload；window为去重时间窗口，默认1m
+type DedupeFilter struct {
+	fields    []string
+	window    time.Duration
+	mu        sync.Mutex
+	seen      map[string]time.Time // key为事件的key，value为首次出现的时间
+	lastSweep time.Time
+}
+
+func (d *DedupeFilter) Install() {
+	d.seen = make(map[string]time.Time)
+	d.lastSweep = time.Now()
+}
+
+func (d *DedupeFilter) Uninstall() {
+}
+
+func (d *DedupeFilter) SetContext(ctx plugin.Context) {
+	d.fields = []string{PayloadField}
+	if fields, ok := ctx.GetString("fields"); ok {
+		d.fields = strings.Split(fields, ",")
+		for i := range d.fields {
+			d.fields[i] = strings.TrimSpace(d.fields[i])
+		}
+	}
+	d.window = defaultDedupeWindow
+	if window, ok := ctx.GetDuration("window"); ok {
+		d.window = window
+	}
+}
+
+func (d *DedupeFilter) Filter(event *plugin.Event) []*plugin.Event {
+	key := d.keyOf(event)
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sweep(now)
+	if first, ok := d.seen[key]; ok && now.Sub(first) < d.window {
+		return nil
+	}
+	d.seen[key] = now
+	return []*plugin.Event{event}
+}
+
+func (d *DedupeFilter) keyOf(event *plugin.Event) string {
+	values := make([]string, 0, len(d.fields))
+	for _, field := range d.fields {
+		value, _ := FieldOf(event, field)
+		values = append(values, fmt.Sprint(value))
+	}
+	return strings.Join(values, "|")
+}
+
+// sweep 每隔一个时间窗口清理过期的key，避免内存无限增长
+func (d *DedupeFilter) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	for key, first := range d.seen {
+		if now.Sub(first) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+	d.lastSweep = now
+}
@@ -0,0 +1,32 @@
+package filter
+
+import (
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"testing"
+	"time"
+)
+
+func TestDedupeFilter(t *testing.T) {
+	dedupe := &DedupeFilter{}
+	dedupe.SetContext(plugin.Context{"fields": "endpoint, type", "window": "50ms"})
+	dedupe.Install()
+	record := func(endpoint string, recordType model.Type) *plugin.Event {
+		re := model.NewMonitoryRecord()
+		re.Endpoint, re.Type = endpoint, recordType
+		return plugin.NewEvent(re)
+	}
+	if len(dedupe.Filter(record("192.168.0.1:80", model.RecvReq))) != 1 {
+		t.Error("want first event kept")
+	}
+	if len(dedupe.Filter(record("192.168.0.1:80", model.RecvReq))) != 0 {
+		t.Error("want duplicated event dropped")
+	}
+	if len(dedupe.Filter(record("192.168.0.1:80", model.SendResp))) != 1 {
+		t.Error("want event with different key kept")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if len(dedupe.Filter(record("192.168.0.1:80", model.RecvReq))) != 1 {
+		t.Error("want event kept after window")
+	}
+}
@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"fmt"
+	"regexp"
+)
+
+// DropIfFilter 丢弃字段满足条件的事件，字段可以是header或payload结构体的字段
+// context配置：field为字段名；equals为字段值等于该值时丢弃；pattern为字段值匹配该正则时丢弃
+// 两者都配置时满足任一即丢弃，都不配置时丢弃所有带有该字段的事件，pattern不合法时不丢弃任何事件
+type DropIfFilter struct {
+	field     string
+	equals    string
+	hasEq     bool
+	pattern   *regexp.Regexp
+	isInvalid bool
+}
+
+func (d *DropIfFilter) Install() {
+}
+
+func (d *DropIfFilter) Uninstall() {
+}
+
+func (d *DropIfFilter) SetContext(ctx plugin.Context) {
+	d.field, _ = ctx.GetString("field")
+	d.equals, d.hasEq = ctx.GetString("equals")
+	if pattern, ok := ctx.GetString("pattern"); ok {
+		var err error
+		if d.pattern, err = regexp.Compile(pattern); err != nil {
+			fmt.Printf("drop_if filter invalid pattern %s: %s\n", pattern, err.Error())
+			d.isInvalid = true
+		}
+	}
+}
+
+func (d *DropIfFilter) Filter(event *plugin.Event) []*plugin.Event {
+	value, ok := FieldOf(event, d.field)
+	if !ok || d.isInvalid {
+		return []*plugin.Event{event}
+	}
+	str := fmt.Sprint(value)
+	isMatch := !d.hasEq && d.pattern == nil
+	if d.hasEq && str == d.equals {
+		isMatch = true
+	}
+	if d.pattern != nil && d.pattern.MatchString(str) {
+		isMatch = true
+	}
+	if isMatch {
+		return nil
+	}
+	return []*plugin.Event{event}
+}
@@ -0,0 +1,29 @@
+package filter
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"testing"
+)
+
+func TestDropIfFilter(t *testing.T) {
+	ctx := plugin.Context{"field": "endpoint", "equals": "192.168.0.1:80", "pattern": `^10\.`}
+	filterPlugin, err := NewPlugin(config.Filter{Name: "filter0", PluginType: "drop_if", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filterPlugin.Install()
+	for endpoint, want := range map[string]int{"192.168.0.1:80": 0, "10.0.0.1:80": 0, "192.168.0.2:80": 1} {
+		re := model.NewMonitoryRecord()
+		re.Endpoint = endpoint
+		if events := filterPlugin.Filter(plugin.NewEvent(re)); len(events) != want {
+			t.Errorf("endpoint %s want %d events got %d", endpoint, want, len(events))
+		}
+	}
+	// header优先于payload字段
+	event := plugin.NewEvent("log").AddHeader("endpoint", "192.168.0.1:80")
+	if events := filterPlugin.Filter(event); len(events) != 0 {
+		t.Errorf("want event dropped by header, got %d events", len(events))
+	}
+}
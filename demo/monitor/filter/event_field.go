@@ -0,0 +1,34 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"reflect"
+	"strings"
+)
+
+// PayloadField 表示事件的payload本身
+const PayloadField = "payload"
+
+// FieldOf 获取事件的字段，依次查找header、payload本身（name为PayloadField时）和payload结构体的字段，字段名不区分大小写
+func FieldOf(event *plugin.Event, name string) (interface{}, bool) {
+	if value, ok := event.Header(name); ok {
+		return value, true
+	}
+	if name == PayloadField {
+		return event.Payload(), event.Payload() != nil
+	}
+	payload := reflect.ValueOf(event.Payload())
+	if payload.Kind() == reflect.Ptr {
+		payload = payload.Elem()
+	}
+	if payload.Kind() != reflect.Struct {
+		return nil, false
+	}
+	field := payload.FieldByNameFunc(func(field string) bool {
+		return strings.EqualFold(field, name)
+	})
+	if !field.IsValid() || !field.CanInterface() {
+		return nil, false
+	}
+	return field.Interface(), true
+}
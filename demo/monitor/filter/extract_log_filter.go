@@ -4,11 +4,13 @@ import (
 	"demo/monitor/model"
 	"demo/monitor/plugin"
 	"regexp"
+	"strconv"
 )
 
 // ExtractLogFilter 从日志中提 endpoint 和 model type
 // 举例[192.168.1.1:8088][recv_req]receive request from address 192.168.1.91:80 success
 // 则endpoint为192.168.1.1:8088，model type为recv_req
+// event带有model.EndpointHeader和model.TypeHeader时，直接从header中提取，不再解析日志，同时提取请求id和状态码
 type ExtractLogFilter struct {
 	pattern *regexp.Regexp
 }
@@ -23,18 +25,29 @@ func (e *ExtractLogFilter) Uninstall() {
 func (e *ExtractLogFilter) SetContext(ctx plugin.Context) {
 }
 
-func (e *ExtractLogFilter) Filter(event *plugin.Event) *plugin.Event {
+func (e *ExtractLogFilter) Filter(event *plugin.Event) []*plugin.Event {
+	endpoint, hasEndpoint := event.Header(model.EndpointHeader)
+	recordType, hasType := event.Header(model.TypeHeader)
+	if hasEndpoint && hasType {
+		re := model.NewMonitoryRecord()
+		re.Endpoint = endpoint
+		re.Type = model.Type(recordType)
+		re.ReqId, _ = event.Header(model.ReqIdHeader)
+		if status, ok := event.Header(model.StatusHeader); ok {
+			re.Status, _ = strconv.Atoi(status)
+		}
+		return []*plugin.Event{event.Derive(re)}
+	}
 	log, ok := event.Payload().(string)
 	if !ok {
-		return event
+		return []*plugin.Event{event}
 	}
 	matches := e.pattern.FindStringSubmatch(log)
 	if len(matches) != 3 {
-		return event
+		return []*plugin.Event{event}
 	}
 	re := model.NewMonitoryRecord()
 	re.Endpoint = matches[1]
 	re.Type = model.Type(matches[2])
-	ev := plugin.NewEvent(re)
-	return ev
+	return []*plugin.Event{event.Derive(re)}
 }
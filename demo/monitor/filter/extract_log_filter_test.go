@@ -20,7 +20,7 @@ func TestExtractLogFilter(t *testing.T) {
 	filterPlugin.Install()
 	log := "[192.168.1.1:8088][recv_req]receive request from address 192.168.1.91:80 success"
 	event := plugin.NewEvent(log)
-	event = filterPlugin.Filter(event)
+	event = filterPlugin.Filter(event)[0]
 	re, ok := event.Payload().(*model.MonitorRecord)
 	if !ok {
 		t.Errorf("want *model.MonitorRecord got %T", event.Payload())
@@ -29,3 +29,23 @@ func TestExtractLogFilter(t *testing.T) {
 		t.Errorf("want 192.168.1.1:8088 got %s, want recv_req got %s", re.Endpoint, re.Type)
 	}
 }
+
+func TestExtractLogFilterFromHeader(t *testing.T) {
+	filterPlugin := &ExtractLogFilter{}
+	filterPlugin.Install()
+	event := plugin.NewEvent("send http request").
+		AddHeader(model.EndpointHeader, "192.168.1.1:8088").
+		AddHeader(model.TypeHeader, string(model.RecvResp)).
+		AddHeader(model.ReqIdHeader, "12").
+		AddHeader(model.StatusHeader, "500")
+	re, ok := filterPlugin.Filter(event)[0].Payload().(*model.MonitorRecord)
+	if !ok {
+		t.Fatal("want *model.MonitorRecord")
+	}
+	if re.Endpoint != "192.168.1.1:8088" || re.Type != model.RecvResp {
+		t.Errorf("want 192.168.1.1:8088 got %s, want recv_resp got %s", re.Endpoint, re.Type)
+	}
+	if re.ReqId != "12" || re.Status != 500 {
+		t.Errorf("want req 12 with status 500, got %s with %d", re.ReqId, re.Status)
+	}
+}
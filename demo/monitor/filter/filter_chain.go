@@ -8,7 +8,7 @@ import (
 责任链模式
 */
 
-// Chain Filter链，按顺序调用
+// Chain Filter链，按顺序调用，前一个filter输出的每个事件都会交给下一个filter处理，事件全部被丢弃时提前结束
 type Chain struct {
 	filters []Plugin
 }
@@ -17,11 +17,19 @@ func NewChain(filters []Plugin) *Chain {
 	return &Chain{filters: filters}
 }
 
-func (c *Chain) Filter(event *plugin.Event) *plugin.Event {
+func (c *Chain) Filter(event *plugin.Event) []*plugin.Event {
+	events := []*plugin.Event{event}
 	for _, filter := range c.filters {
-		event = filter.Filter(event)
+		var next []*plugin.Event
+		for _, e := range events {
+			next = append(next, filter.Filter(e)...)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		events = next
 	}
-	return event
+	return events
 }
 
 func (c *Chain) Install() {
@@ -9,7 +9,8 @@ import (
 // Plugin 过滤插件
 type Plugin interface {
 	plugin.Plugin
-	Filter(event *plugin.Event) *plugin.Event
+	// Filter 处理事件，返回空表示丢弃事件，返回多个表示将事件拆分，也可以通过plugin.RouteHeader为事件打上路由标签
+	Filter(event *plugin.Event) []*plugin.Event
 }
 
 // NewPlugin 过滤插件工厂方法
@@ -21,5 +22,21 @@ func NewPlugin(config config.Filter) (Plugin, error) {
 	filterPlugin := reflect.New(filterType)
 	ctx := reflect.ValueOf(config.Ctx)
 	filterPlugin.MethodByName("SetContext").Call([]reflect.Value{ctx})
+	if route, ok := config.Ctx.GetString("route"); ok {
+		return &routedFilter{Plugin: filterPlugin.Interface().(Plugin), route: route}, nil
+	}
 	return filterPlugin.Interface().(Plugin), nil
 }
+
+// routedFilter 只处理路由标签为route的事件，其他事件直接放行，在filter的context中配置route时使用
+type routedFilter struct {
+	Plugin
+	route string
+}
+
+func (r *routedFilter) Filter(event *plugin.Event) []*plugin.Event {
+	if route, _ := event.Header(plugin.RouteHeader); route != r.route {
+		return []*plugin.Event{event}
+	}
+	return r.Plugin.Filter(event)
+}
@@ -11,4 +11,10 @@ var Type = make(plugin.Types)
 func init() {
 	Type["extract_log"] = reflect.TypeOf(ExtractLogFilter{})
 	Type["add_timestamp"] = reflect.TypeOf(AddTimestampFilter{})
+	Type["drop_if"] = reflect.TypeOf(DropIfFilter{})
+	Type["sample"] = reflect.TypeOf(SampleFilter{})
+	Type["dedupe"] = reflect.TypeOf(DedupeFilter{})
+	Type["route_by_header"] = reflect.TypeOf(RouteByHeaderFilter{})
+	Type["condition"] = reflect.TypeOf(ConditionFilter{})
+	Type["aggregate"] = reflect.TypeOf(AggregateFilter{})
 }
@@ -0,0 +1,50 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"strings"
+)
+
+// 路由映射配置的前缀
+const routePrefix = "route."
+
+// RouteByHeaderFilter 根据header的值为事件打上路由标签plugin.RouteHeader
+// context配置：header为header名；route.<value>为header值为value时的路由，未配置时路由即header值；
+// default为没有该header时的路由，未配置时不打标签
+type RouteByHeaderFilter struct {
+	header       string
+	routes       map[string]string
+	defaultRoute string
+}
+
+func (r *RouteByHeaderFilter) Install() {
+}
+
+func (r *RouteByHeaderFilter) Uninstall() {
+}
+
+func (r *RouteByHeaderFilter) SetContext(ctx plugin.Context) {
+	r.header, _ = ctx.GetString("header")
+	r.defaultRoute, _ = ctx.GetString("default")
+	r.routes = make(map[string]string)
+	for key, value := range ctx {
+		if strings.HasPrefix(key, routePrefix) {
+			r.routes[strings.TrimPrefix(key, routePrefix)] = value
+		}
+	}
+}
+
+func (r *RouteByHeaderFilter) Filter(event *plugin.Event) []*plugin.Event {
+	value, ok := event.Header(r.header)
+	if !ok {
+		if r.defaultRoute != "" {
+			event.AddHeader(plugin.RouteHeader, r.defaultRoute)
+		}
+		return []*plugin.Event{event}
+	}
+	if route, ok := r.routes[value]; ok {
+		value = route
+	}
+	event.AddHeader(plugin.RouteHeader, value)
+	return []*plugin.Event{event}
+}
@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"testing"
+)
+
+func TestRouteByHeaderFilter(t *testing.T) {
+	route := &RouteByHeaderFilter{}
+	route.SetContext(plugin.Context{"header": "type", "route.recv_req": "requests", "default": "unknown"})
+	cases := map[*plugin.Event]string{
+		plugin.NewEvent("log").AddHeader("type", "recv_req"):  "requests",
+		plugin.NewEvent("log").AddHeader("type", "send_resp"): "send_resp",
+		plugin.NewEvent("log"):                                "unknown",
+	}
+	for event, want := range cases {
+		got, _ := route.Filter(event)[0].Header(plugin.RouteHeader)
+		if got != want {
+			t.Errorf("want route %s got %s", want, got)
+		}
+	}
+}
+
+func TestChainWithRoute(t *testing.T) {
+	var filters []Plugin
+	for _, conf := range []config.Filter{
+		{Name: "filter0", PluginType: "route_by_header", Ctx: plugin.Context{"header": "type"}},
+		{Name: "filter1", PluginType: "extract_log"},
+		// 只丢弃send_req路由上的事件
+		{Name: "filter2", PluginType: "drop_if", Ctx: plugin.Context{"field": "endpoint", "route": "send_req"}},
+	} {
+		filterPlugin, err := NewPlugin(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filters = append(filters, filterPlugin)
+	}
+	chain := NewChain(filters)
+	chain.Install()
+	newEvent := func(recordType model.Type) *plugin.Event {
+		return plugin.NewEvent("log").AddHeader(model.EndpointHeader, "192.168.0.1:80").
+			AddHeader(model.TypeHeader, string(recordType))
+	}
+	if events := chain.Filter(newEvent(model.SendReq)); len(events) != 0 {
+		t.Errorf("want send_req dropped, got %d events", len(events))
+	}
+	events := chain.Filter(newEvent(model.RecvReq))
+	if len(events) != 1 {
+		t.Fatalf("want recv_req kept, got %d events", len(events))
+	}
+	if route, _ := events[0].Header(plugin.RouteHeader); route != "recv_req" {
+		t.Errorf("want route recv_req kept after extract_log, got %s", route)
+	}
+	if _, ok := events[0].Payload().(*model.MonitorRecord); !ok {
+		t.Errorf("want *model.MonitorRecord got %T", events[0].Payload())
+	}
+}
@@ -0,0 +1,47 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+)
+
+// SampleFilter 采样，只保留部分事件
+// context配置：every为每N个事件保留1个，配置后rate无效；rate为随机保留的比例，取值0~1，默认1
+type SampleFilter struct {
+	every   uint64
+	rate    float64
+	counter uint64
+}
+
+func (s *SampleFilter) Install() {
+}
+
+func (s *SampleFilter) Uninstall() {
+}
+
+func (s *SampleFilter) SetContext(ctx plugin.Context) {
+	if every, ok := ctx.GetInt("every"); ok && every > 0 {
+		s.every = uint64(every)
+	}
+	s.rate = 1
+	if val, ok := ctx.GetString("rate"); ok {
+		if rate, err := strconv.ParseFloat(val, 64); err == nil {
+			s.rate = rate
+		}
+	}
+}
+
+func (s *SampleFilter) Filter(event *plugin.Event) []*plugin.Event {
+	if s.every > 0 {
+		if (atomic.AddUint64(&s.counter, 1)-1)%s.every != 0 {
+			return nil
+		}
+		return []*plugin.Event{event}
+	}
+	if rand.Float64() >= s.rate {
+		return nil
+	}
+	return []*plugin.Event{event}
+}
@@ -0,0 +1,24 @@
+package filter
+
+import (
+	"demo/monitor/plugin"
+	"testing"
+)
+
+func TestSampleFilter(t *testing.T) {
+	every := &SampleFilter{}
+	every.SetContext(plugin.Context{"every": "3"})
+	none := &SampleFilter{}
+	none.SetContext(plugin.Context{"rate": "0"})
+	var kept, noneKept int
+	for i := 0; i < 9; i++ {
+		kept += len(every.Filter(plugin.NewEvent(i)))
+		noneKept += len(none.Filter(plugin.NewEvent(i)))
+	}
+	if kept != 3 {
+		t.Errorf("every 3 want 3 kept got %d", kept)
+	}
+	if noneKept != 0 {
+		t.Errorf("rate 0 want 0 kept got %d", noneKept)
+	}
+}
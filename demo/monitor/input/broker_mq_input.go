@@ -0,0 +1,38 @@
+package input
+
+import (
+	"demo/monitor/plugin"
+	"demo/network"
+	"demo/service/broker"
+)
+
+// BrokerMqInput 远程消息队列输入插件，通过broker.Client消费其他主机上的消息队列服务
+// 除了topic、group外，还需配置broker（消息队列服务的ip:port）和ip（本机ip）
+type BrokerMqInput struct {
+	MemoryMqInput
+	localIp string
+	broker  network.Endpoint
+	client  *broker.Client
+}
+
+func (b *BrokerMqInput) Install() {
+	b.client = broker.NewClient(b.localIp, b.broker)
+	b.install(b.client)
+}
+
+func (b *BrokerMqInput) Uninstall() {
+	b.MemoryMqInput.Uninstall()
+	if b.client != nil {
+		b.client.Close()
+	}
+}
+
+func (b *BrokerMqInput) SetContext(ctx plugin.Context) {
+	b.MemoryMqInput.SetContext(ctx)
+	if ip, ok := ctx.GetString("ip"); ok {
+		b.localIp = ip
+	}
+	if endpoint, ok := ctx.GetString("broker"); ok {
+		_ = b.broker.UnmarshalText([]byte(endpoint))
+	}
+}
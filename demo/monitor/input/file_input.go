@@ -0,0 +1,289 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"demo/monitor/plugin"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的文件检查间隔
+const defaultPollInterval = 200 * time.Millisecond
+
+// 文件输入事件的header
+const (
+	PathHeader   = "path"
+	OffsetHeader = "offset"
+)
+
+// FileInput 文件输入插件，像tail -F一样持续读取日志文件的新增行，每行为一个事件
+// context配置：path为日志文件路径；checkpoint为读取位置的保存路径，默认为<path>.checkpoint；
+// pollInterval为读到文件末尾后的检查间隔，默认200ms；startAt为没有checkpoint时的起始位置，可选beginning、end，默认beginning
+// 事件被Ack后才更新checkpoint，重新安装时从checkpoint继续读取，保证至少读取一次；
+// 文件被轮转（重命名后新建）时读完旧文件再从新文件开头读取，截断时从头读取，轮转前未确认的事件不再更新checkpoint
+type FileInput struct {
+	path           string
+	checkpointPath string
+	pollInterval   time.Duration
+	startAtEnd     bool
+	readMu         sync.Mutex // 保护文件的读取和关闭
+	file           *os.File
+	info           os.FileInfo
+	reader         *bufio.Reader
+	offset         int64  // 已读取的完整行在文件中的结束位置
+	partial        string // 末尾不完整的行，等待写完后再输出
+	mu             sync.Mutex
+	generation     int // 每次打开新文件时递增，旧文件事件的Ack不再更新checkpoint
+	pending        []*fileLine
+	lines          map[*plugin.Event]*fileLine
+	committed      int64
+	ctx            context.Context
+	cancel         context.CancelFunc
+}
+
+// fileLine 等待确认的行
+type fileLine struct {
+	generation int
+	end        int64
+	isAcked    bool
+}
+
+// checkpoint 读取位置
+type checkpoint struct {
+	Offset int64 `json:"offset"`
+}
+
+// Install 安装插件，上次安装时未确认的行会从checkpoint重新读取，不再等待它们确认
+func (f *FileInput) Install() {
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generation++
+	f.pending = nil
+	f.lines = make(map[*plugin.Event]*fileLine)
+}
+
+// Uninstall 卸载插件，阻塞在Input中的读取会立即返回ErrPluginUninstalled
+func (f *FileInput) Uninstall() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.readMu.Lock()
+	defer f.readMu.Unlock()
+	f.closeFile()
+}
+
+func (f *FileInput) SetContext(ctx plugin.Context) {
+	f.path, _ = ctx.GetString("path")
+	f.checkpointPath = f.path + ".checkpoint"
+	if path, ok := ctx.GetString("checkpoint"); ok {
+		f.checkpointPath = path
+	}
+	f.pollInterval = defaultPollInterval
+	if interval, ok := ctx.GetDuration("pollInterval"); ok && interval > 0 {
+		f.pollInterval = interval
+	}
+	startAt, _ := ctx.GetString("startAt")
+	f.startAtEnd = startAt == "end"
+}
+
+func (f *FileInput) Input() (*plugin.Event, error) {
+	if f.ctx == nil {
+		return nil, plugin.ErrPluginNotInstalled
+	}
+	for {
+		if f.ctx.Err() != nil {
+			return nil, plugin.ErrPluginUninstalled
+		}
+		event, err := f.readLine()
+		if event != nil || err != nil {
+			return event, err
+		}
+		select {
+		case <-f.ctx.Done():
+			return nil, plugin.ErrPluginUninstalled
+		case <-time.After(f.pollInterval):
+		}
+	}
+}
+
+// readLine 读取一行，没有完整的行时返回nil
+func (f *FileInput) readLine() (*plugin.Event, error) {
+	f.readMu.Lock()
+	defer f.readMu.Unlock()
+	if f.ctx.Err() != nil {
+		return nil, plugin.ErrPluginUninstalled
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	line, err := f.reader.ReadString('\n')
+	f.partial += line
+	if err == io.EOF {
+		return nil, f.follow()
+	}
+	if err != nil {
+		return nil, err
+	}
+	start := f.offset
+	f.offset += int64(len(f.partial))
+	text := strings.TrimRight(f.partial, "\r\n")
+	f.partial = ""
+	event := plugin.NewEvent(text).
+		AddHeader(PathHeader, f.path).
+		AddHeader(OffsetHeader, strconv.FormatInt(start, 10))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l := &fileLine{generation: f.generation, end: f.offset}
+	f.pending = append(f.pending, l)
+	f.lines[event] = l
+	return event, nil
+}
+
+// open 打开文件，第一次打开时从checkpoint开始读取，checkpoint超出文件大小说明文件已被轮转或截断，从头读取
+func (f *FileInput) open() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	offset, err := f.loadCheckpoint(info.Size())
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.info, f.offset = file, info, offset
+	f.reader = bufio.NewReader(file)
+	f.mu.Lock()
+	f.committed = offset
+	f.mu.Unlock()
+	return nil
+}
+
+// follow 读到文件末尾时检查文件是否被轮转或截断，是则切换到新文件
+// 轮转前写入旧文件的内容读完后才切换，旧文件末尾不完整的行会被丢弃
+func (f *FileInput) follow() error {
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rotated := !os.SameFile(info, f.info)
+	truncated := !rotated && info.Size() < f.offset+int64(len(f.partial))
+	if !rotated && !truncated {
+		return nil
+	}
+	if rotated {
+		old, err := f.file.Stat()
+		if err != nil {
+			return err
+		}
+		if old.Size() > f.offset+int64(len(f.partial)) {
+			return nil
+		}
+	}
+	f.closeFile()
+	f.mu.Lock()
+	f.generation++
+	f.pending = nil
+	err = f.saveCheckpoint(0)
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *FileInput) closeFile() {
+	if f.file != nil {
+		f.file.Close()
+		f.file, f.reader, f.partial = nil, nil, ""
+	}
+}
+
+// Ack 事件确认后，更新之前所有行都已确认的位置到checkpoint
+func (f *FileInput) Ack(event *plugin.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.lines[event]
+	if !ok {
+		return nil
+	}
+	delete(f.lines, event)
+	if l.generation != f.generation {
+		return nil
+	}
+	l.isAcked = true
+	committed := f.committed
+	for len(f.pending) > 0 && f.pending[0].isAcked {
+		committed = f.pending[0].end
+		f.pending = f.pending[1:]
+	}
+	if committed == f.committed {
+		return nil
+	}
+	f.committed = committed
+	return f.saveCheckpoint(committed)
+}
+
+// Nack 未确认的行阻止checkpoint前进，重新安装后会再次读取
+func (f *FileInput) Nack(event *plugin.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.lines, event)
+	return nil
+}
+
+func (f *FileInput) loadCheckpoint(size int64) (int64, error) {
+	data, err := os.ReadFile(f.checkpointPath)
+	if os.IsNotExist(err) {
+		if f.startAtEnd {
+			return size, nil
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return 0, err
+	}
+	if cp.Offset > size {
+		return 0, nil
+	}
+	return cp.Offset, nil
+}
+
+// saveCheckpoint 先写临时文件再重命名，避免崩溃时文件损坏
+func (f *FileInput) saveCheckpoint(offset int64) error {
+	data, err := json.Marshal(&checkpoint{Offset: offset})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.checkpointPath+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.checkpointPath+".tmp", f.checkpointPath)
+}
@@ -0,0 +1,157 @@
+package input
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/plugin"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newFileInput(t *testing.T, path string) *FileInput {
+	ctx := plugin.Context{"path": path, "pollInterval": "5ms"}
+	inputPlugin, err := NewPlugin(config.Input{Name: "input0", PluginType: "file", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputPlugin.Install()
+	return inputPlugin.(*FileInput)
+}
+
+// inputLine 读取一行，超时返回nil
+func inputLine(t *testing.T, input Plugin) *plugin.Event {
+	result := make(chan *plugin.Event, 1)
+	go func() {
+		event, _ := input.Input()
+		result <- event
+	}()
+	select {
+	case event := <-result:
+		return event
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("input line timeout")
+	}
+	return nil
+}
+
+func TestFileInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	fi := newFileInput(t, path)
+	// 文件不存在时等待文件创建，不完整的行等待写完
+	appendFile(t, path, "line1\nline")
+	first := inputLine(t, fi)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		appendFile(t, path, "2\r\n")
+	}()
+	second := inputLine(t, fi)
+	if first.Payload() != "line1" || second.Payload() != "line2" {
+		t.Fatalf("want line1 line2 got %v %v", first.Payload(), second.Payload())
+	}
+	if offset, _ := second.Header(OffsetHeader); offset != "6" {
+		t.Errorf("want offset 6 got %s", offset)
+	}
+
+	// 只确认了第二行时checkpoint不前进
+	fi.Ack(second)
+	fi.Uninstall()
+	fi = newFileInput(t, path)
+	if event := inputLine(t, fi); event.Payload() != "line1" {
+		t.Fatalf("want line1 read again got %v", event.Payload())
+	}
+	fi.Ack(inputLine(t, fi))
+	fi.Uninstall()
+
+	// 两行都确认后从第三行继续
+	fi = newFileInput(t, path)
+	defer fi.Uninstall()
+	first, second = inputLine(t, fi), inputLine(t, fi)
+	fi.Ack(first)
+	fi.Ack(second)
+	appendFile(t, path, "line3\n")
+	if event := inputLine(t, fi); event.Payload() != "line3" {
+		t.Fatalf("want line3 got %v", event.Payload())
+	}
+}
+
+func TestFileInputReinstall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, "line1\nline2\n")
+	fi := newFileInput(t, path)
+	fi.Nack(inputLine(t, fi))
+	fi.Uninstall()
+
+	// 重新安装后不再等待上次Nack的行，重新读取并确认后checkpoint前进
+	fi.Install()
+	event := inputLine(t, fi)
+	if event.Payload() != "line1" {
+		t.Fatalf("want line1 read again got %v", event.Payload())
+	}
+	fi.Ack(event)
+	fi.Uninstall()
+	fi.Install()
+	defer fi.Uninstall()
+	if event := inputLine(t, fi); event.Payload() != "line2" {
+		t.Fatalf("want line2 got %v", event.Payload())
+	}
+}
+
+func TestFileInputRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, "old1\n")
+	fi := newFileInput(t, path)
+	defer fi.Uninstall()
+	fi.Ack(inputLine(t, fi))
+
+	// 文件轮转后从新文件开头读取
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new1\n")
+	event := inputLine(t, fi)
+	if event.Payload() != "new1" {
+		t.Fatalf("want new1 got %v", event.Payload())
+	}
+	fi.Ack(event)
+
+	// 读到旧文件末尾后、检查轮转前写入旧文件的行读完后再切换
+	if event, err := fi.readLine(); event != nil || err != nil {
+		t.Fatalf("want end of file got %v, %v", event, err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "new2\n")
+	appendFile(t, path, "newer1\n")
+	if err := fi.follow(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"new2", "newer1"} {
+		event := inputLine(t, fi)
+		if event.Payload() != want {
+			t.Fatalf("want %s got %v", want, event.Payload())
+		}
+		fi.Ack(event)
+	}
+
+	// 文件截断后从头读取
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "n\n")
+	if event := inputLine(t, fi); event.Payload() != "n" {
+		t.Fatalf("want n got %v", event.Payload())
+	}
+}
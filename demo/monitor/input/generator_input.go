@@ -0,0 +1,74 @@
+package input
+
+import (
+	"context"
+	"demo/monitor/plugin"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 默认生成的日志，{seq}替换为事件序号
+const defaultGeneratorMessage = "[192.168.1.1:8088][recv_req]receive request {seq} from address 192.168.1.91:80 success"
+
+// 生成事件的序号header
+const SeqHeader = "seq"
+
+// GeneratorInput 事件生成输入插件，用于压测pipeline
+// context配置：message为事件的payload模板，其中的{seq}替换为从1开始的序号；count为生成的事件数，默认0表示不限制；
+// rate为每秒生成的事件数，默认0表示不限速；生成count个事件后Input阻塞直到卸载
+type GeneratorInput struct {
+	message string
+	count   int
+	rate    int
+	seq     int
+	start   time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func (g *GeneratorInput) Install() {
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.seq = 0
+	g.start = time.Now()
+}
+
+func (g *GeneratorInput) Uninstall() {
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+func (g *GeneratorInput) SetContext(ctx plugin.Context) {
+	g.message = defaultGeneratorMessage
+	if message, ok := ctx.GetString("message"); ok {
+		g.message = message
+	}
+	g.count, _ = ctx.GetInt("count")
+	g.rate, _ = ctx.GetInt("rate")
+}
+
+func (g *GeneratorInput) Input() (*plugin.Event, error) {
+	if g.ctx == nil {
+		return nil, plugin.ErrPluginNotInstalled
+	}
+	if g.count > 0 && g.seq >= g.count {
+		<-g.ctx.Done()
+		return nil, plugin.ErrPluginUninstalled
+	}
+	// 限速时第n个事件在start+n/rate时生成
+	if g.rate > 0 {
+		at := g.start.Add(time.Duration(g.seq) * time.Second / time.Duration(g.rate))
+		select {
+		case <-g.ctx.Done():
+			return nil, plugin.ErrPluginUninstalled
+		case <-time.After(time.Until(at)):
+		}
+	}
+	if g.ctx.Err() != nil {
+		return nil, plugin.ErrPluginUninstalled
+	}
+	g.seq++
+	seq := strconv.Itoa(g.seq)
+	return plugin.NewEvent(strings.ReplaceAll(g.message, "{seq}", seq)).AddHeader(SeqHeader, seq), nil
+}
@@ -0,0 +1,40 @@
+package input
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/plugin"
+	"testing"
+	"time"
+)
+
+func TestGeneratorInput(t *testing.T) {
+	ctx := plugin.Context{"message": "log {seq}", "count": "3", "rate": "100"}
+	inputPlugin, err := NewPlugin(config.Input{Name: "input0", PluginType: "generator", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputPlugin.Install()
+	start := time.Now()
+	for _, want := range []string{"log 1", "log 2", "log 3"} {
+		event, err := inputPlugin.Input()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Payload() != want {
+			t.Errorf("want %s got %v", want, event.Payload())
+		}
+	}
+	// 每秒100个，第3个事件在20ms后生成
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("want rate limited, got 3 events in %v", elapsed)
+	}
+
+	// 生成count个事件后阻塞直到卸载
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		inputPlugin.Uninstall()
+	}()
+	if _, err := inputPlugin.Input(); err != plugin.ErrPluginUninstalled {
+		t.Errorf("want ErrPluginUninstalled got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package input
+
+import (
+	"demo/monitor/plugin"
+	"demo/network"
+	"demo/network/http"
+	"sync"
+)
+
+// 默认的http输入配置
+const (
+	defaultHttpInputUri  = "/api/v1/events"
+	defaultHttpQueueSize = 1000
+	defaultHttpInputPort = 80
+)
+
+// HttpInput http输入插件，在http.Server上注册POST路由接收推送的事件，请求的body为事件的payload，请求的header为事件的header
+// context配置：ip为监听的ip；port为监听的端口，默认80；uri为路由，默认/api/v1/events；queueSize为缓存的事件数，默认1000
+// 缓存已满时返回429，卸载后返回503，成功时返回202；202只表示事件已进入缓存，
+// 卸载后Input先返回缓存中剩余的事件，之后不再读取的事件随插件一起丢弃
+type HttpInput struct {
+	ip        string
+	port      int
+	uri       http.Uri
+	queueSize int
+	server    *http.Server
+	err       error
+	events    chan *plugin.Event
+	done      chan struct{} // 卸载时关闭，唤醒阻塞在Input中的goroutine
+	closeOnce sync.Once
+}
+
+func (h *HttpInput) Install() {
+	h.events = make(chan *plugin.Event, h.queueSize)
+	h.done = make(chan struct{})
+	h.closeOnce = sync.Once{}
+	h.server = http.NewServer(network.DefaultSocket()).Listen(h.ip, h.port).Post(h.uri, h.push)
+	h.err = h.server.Start()
+}
+
+func (h *HttpInput) Uninstall() {
+	h.closeOnce.Do(func() {
+		if h.server != nil {
+			h.server.Shutdown()
+			close(h.done)
+		}
+	})
+}
+
+func (h *HttpInput) SetContext(ctx plugin.Context) {
+	h.ip, _ = ctx.GetString("ip")
+	h.port = defaultHttpInputPort
+	if port, ok := ctx.GetInt("port"); ok {
+		h.port = port
+	}
+	h.uri = defaultHttpInputUri
+	if uri, ok := ctx.GetString("uri"); ok {
+		h.uri = http.Uri(uri)
+	}
+	h.queueSize = defaultHttpQueueSize
+	if size, ok := ctx.GetInt("queueSize"); ok && size > 0 {
+		h.queueSize = size
+	}
+}
+
+func (h *HttpInput) Input() (*plugin.Event, error) {
+	if h.server == nil {
+		return nil, plugin.ErrPluginNotInstalled
+	}
+	if h.err != nil {
+		return nil, h.err
+	}
+	select {
+	case event := <-h.events:
+		return event, nil
+	case <-h.done:
+	}
+	// 卸载后先返回缓存中已经应答202的事件
+	select {
+	case event := <-h.events:
+		return event, nil
+	default:
+		return nil, plugin.ErrPluginUninstalled
+	}
+}
+
+// Endpoint 监听的endpoint
+func (h *HttpInput) Endpoint() network.Endpoint {
+	return network.EndpointOf(h.ip, h.port)
+}
+
+// push 接收推送的事件
+func (h *HttpInput) push(req *http.Request) *http.Response {
+	event := plugin.NewEvent(req.Body())
+	for key, value := range req.Headers() {
+		event.AddHeader(key, value)
+	}
+	select {
+	case <-h.done:
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusServiceUnavailable).
+			AddProblemDetails("http input uninstalled")
+	default:
+	}
+	select {
+	case h.events <- event:
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusAccepted)
+	default:
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusTooManyRequest).
+			AddHeader(http.RetryAfterHeader, "1").AddProblemDetails("http input queue is full")
+	}
+}
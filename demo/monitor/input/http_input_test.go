@@ -0,0 +1,69 @@
+package input
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/plugin"
+	"demo/network"
+	"demo/network/http"
+	"testing"
+)
+
+func TestHttpInput(t *testing.T) {
+	ctx := plugin.Context{"ip": "192.168.0.70", "uri": "/events", "queueSize": "1"}
+	inputPlugin, err := NewPlugin(config.Input{Name: "input0", PluginType: "http", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputPlugin.Install()
+	hi := inputPlugin.(*HttpInput)
+	client, err := http.NewClient(network.DefaultSocket(), "192.168.0.71")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	push := func() *http.Response {
+		req := http.EmptyRequest().AddMethod(http.POST).AddUri("/events").
+			AddHeader("endpoint", "192.168.1.1:8088").AddBody("log")
+		resp, err := client.Send(hi.Endpoint(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := push(); resp.StatusCode() != http.StatusAccepted {
+		t.Fatalf("want StatusAccepted got %v", resp.StatusCode())
+	}
+	// 缓存已满
+	if resp := push(); resp.StatusCode() != http.StatusTooManyRequest {
+		t.Errorf("want StatusTooManyRequest got %v", resp.StatusCode())
+	}
+	event, err := hi.Input()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint, _ := event.Header("endpoint"); event.Payload() != "log" || endpoint != "192.168.1.1:8088" {
+		t.Errorf("want log from 192.168.1.1:8088, got %v from %s", event.Payload(), endpoint)
+	}
+
+	// 卸载后先返回已经应答202的事件
+	push()
+	hi.Uninstall()
+	if event, err := hi.Input(); err != nil || event.Payload() != "log" {
+		t.Errorf("want queued event got %v, %v", event, err)
+	}
+	if _, err := hi.Input(); err != plugin.ErrPluginUninstalled {
+		t.Errorf("want ErrPluginUninstalled got %v", err)
+	}
+
+	// 重新安装后可以再次卸载
+	hi.Install()
+	if resp := push(); resp.StatusCode() != http.StatusAccepted {
+		t.Fatalf("want StatusAccepted after reinstall got %v", resp.StatusCode())
+	}
+	hi.Uninstall()
+	if resp, err := client.Send(hi.Endpoint(), http.EmptyRequest().AddMethod(http.POST).AddUri("/events")); err == nil &&
+		resp.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("want request rejected after uninstall got %v", resp.StatusCode())
+	}
+}
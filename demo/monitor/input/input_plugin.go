@@ -27,3 +27,9 @@ func NewPlugin(config config.Input) (Plugin, error) {
 	inputPlugin.MethodByName("SetContext").Call([]reflect.Value{ctx})
 	return inputPlugin.Interface().(Plugin), nil
 }
+
+// Acker 支持消息确认的输入插件，pipeline在event输出成功后调用Ack，失败时调用Nack
+type Acker interface {
+	Ack(event *plugin.Event) error
+	Nack(event *plugin.Event) error
+}
@@ -11,4 +11,8 @@ var Type = make(plugin.Types)
 func init() {
 	Type["memory_mq"] = reflect.TypeOf(MemoryMqInput{})
 	Type["socket"] = reflect.TypeOf(SocketInput{})
+	Type["broker_mq"] = reflect.TypeOf(BrokerMqInput{})
+	Type["file"] = reflect.TypeOf(FileInput{})
+	Type["http"] = reflect.TypeOf(HttpInput{})
+	Type["generator"] = reflect.TypeOf(GeneratorInput{})
 }
@@ -1,34 +1,98 @@
 package input
 
 import (
+	"context"
 	"demo/monitor/plugin"
 	"demo/mq"
+	"sync"
 )
 
+// MemoryMqInput 内存消息队列输入插件，配置了group时以消费组的方式消费，多个pipeline可以各自消费全量消息
 type MemoryMqInput struct {
-	topic    mq.Topic
-	consumer mq.Consumable
+	topic      mq.Topic
+	group      string
+	consumer   mq.Mq
+	deliveries sync.Map // key为*plugin.Event，value为*mq.Delivery，等待确认的消息
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
 func (m *MemoryMqInput) Install() {
-	m.consumer = mq.MemoryMqInstance()
+	m.install(mq.MemoryMqInstance())
 }
 
+func (m *MemoryMqInput) install(consumer mq.Mq) {
+	m.consumer = consumer
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+}
+
+// Uninstall 卸载插件，阻塞在Input中的消费会立即返回ErrPluginUninstalled
 func (m *MemoryMqInput) Uninstall() {
+	if m.cancel != nil {
+		m.cancel()
+	}
 }
 
 func (m *MemoryMqInput) SetContext(ctx plugin.Context) {
 	if topic, ok := ctx.GetString("topic"); ok {
 		m.topic = mq.Topic(topic)
 	}
+	if group, ok := ctx.GetString("group"); ok {
+		m.group = group
+	}
 }
 
 func (m *MemoryMqInput) Input() (*plugin.Event, error) {
-	msg, err := m.consumer.Consume(m.topic)
+	if m.consumer == nil {
+		return nil, plugin.ErrPluginNotInstalled
+	}
+	if m.group == "" {
+		msg, err := m.consumer.ConsumeContext(m.ctx, m.topic)
+		if err != nil {
+			return nil, m.errorOf(err)
+		}
+		return m.eventOf(msg), nil
+	}
+	delivery, err := m.consumer.ConsumeGroupContext(m.ctx, m.group, m.topic)
 	if err != nil {
-		return nil, err
+		return nil, m.errorOf(err)
 	}
-	event := plugin.NewEvent(msg.Payload())
-	event.AddHeader("topic", string(m.topic))
+	event := m.eventOf(delivery.Message())
+	m.deliveries.Store(event, delivery)
 	return event, nil
 }
+
+func (m *MemoryMqInput) Ack(event *plugin.Event) error {
+	if record, ok := m.deliveries.LoadAndDelete(event); ok {
+		return record.(*mq.Delivery).Ack()
+	}
+	return nil
+}
+
+func (m *MemoryMqInput) Nack(event *plugin.Event) error {
+	if record, ok := m.deliveries.LoadAndDelete(event); ok {
+		return record.(*mq.Delivery).Nack()
+	}
+	return nil
+}
+
+// eventOf 将消息转换为event，消息的header和key一并传递
+func (m *MemoryMqInput) eventOf(msg *mq.Message) *plugin.Event {
+	event := plugin.NewEvent(msg.Value())
+	for key, value := range msg.Headers() {
+		event.AddHeader(key, value)
+	}
+	event.AddHeader("topic", string(msg.Topic()))
+	if msg.Key() != "" {
+		event.AddHeader("key", msg.Key())
+	}
+	return event
+}
+
+// errorOf 卸载导致的消费中断转换为ErrPluginUninstalled
+func (m *MemoryMqInput) errorOf(err error) error {
+	if m.ctx.Err() != nil {
+		return plugin.ErrPluginUninstalled
+	}
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"demo/monitor/plugin"
 	"demo/mq"
 	"testing"
+	"time"
 )
 
 func TestMemoryMqInputPlugin_New(t *testing.T) {
@@ -34,3 +35,57 @@ func TestMemoryMqInputPlugin_New(t *testing.T) {
 	mi.Uninstall()
 	mq.MemoryMqInstance().Clear()
 }
+
+func TestMemoryMqInputPlugin_Group(t *testing.T) {
+	var inputs []*MemoryMqInput
+	for _, group := range []string{"pipeline0", "pipeline1"} {
+		ctx := plugin.EmptyContext()
+		ctx.Add("topic", "group_test")
+		ctx.Add("group", group)
+		inputPlugin, err := NewPlugin(config.Input{Name: group, PluginType: "memory_mq", Ctx: ctx})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputPlugin.Install()
+		inputs = append(inputs, inputPlugin.(*MemoryMqInput))
+	}
+	mq.MemoryMqInstance().Produce(mq.NewMessage("group_test", "hello"))
+	// 不同消费组的pipeline各自收到全量消息
+	for _, mi := range inputs {
+		event, err := mi.Input()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Payload().(string) != "hello" {
+			t.Errorf("want hello, got %v", event.Payload())
+		}
+		if err := mi.Ack(event); err != nil {
+			t.Error(err)
+		}
+		mi.Uninstall()
+	}
+	mq.MemoryMqInstance().Clear()
+}
+
+func TestMemoryMqInputPlugin_Uninstall(t *testing.T) {
+	ctx := plugin.EmptyContext()
+	ctx.Add("topic", "uninstall_test")
+	inputPlugin, _ := NewPlugin(config.Input{Name: "input0", PluginType: "memory_mq", Ctx: ctx})
+	inputPlugin.Install()
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := inputPlugin.Input()
+		errChan <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// 卸载后阻塞中的Input立即返回
+	inputPlugin.Uninstall()
+	select {
+	case err := <-errChan:
+		if err != plugin.ErrPluginUninstalled {
+			t.Errorf("want ErrPluginUninstalled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("input not return after uninstall")
+	}
+}
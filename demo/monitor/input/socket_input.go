@@ -10,20 +10,23 @@ type SocketInput struct {
 	socket      network.Socket
 	endpoint    network.Endpoint
 	packets     chan *network.Packet
+	done        chan struct{} // 卸载时关闭，唤醒阻塞在Input和Handle中的goroutine
 	isUninstall uint32
 }
 
 func (s *SocketInput) Install() {
 	s.socket = network.DefaultSocket()
 	s.packets = make(chan *network.Packet, 10000)
+	s.done = make(chan struct{})
 	s.socket.AddListener(s)
 	s.socket.Listen(s.endpoint)
 }
 
 func (s *SocketInput) Uninstall() {
-	close(s.packets)
-	atomic.StoreUint32(&s.isUninstall, 1)
-	s.socket.Close(s.endpoint)
+	if atomic.CompareAndSwapUint32(&s.isUninstall, 0, 1) {
+		s.socket.Close(s.endpoint)
+		close(s.done)
+	}
 }
 
 func (s *SocketInput) SetContext(ctx plugin.Context) {
@@ -39,8 +42,10 @@ func (s *SocketInput) SetContext(ctx plugin.Context) {
 }
 
 func (s *SocketInput) Input() (*plugin.Event, error) {
-	packet, ok := <-s.packets
-	if !ok {
+	var packet *network.Packet
+	select {
+	case packet = <-s.packets:
+	case <-s.done:
 		return nil, plugin.ErrPluginUninstalled
 	}
 	event := plugin.NewEvent(packet.Payload())
@@ -55,6 +60,10 @@ func (s *SocketInput) Handle(packet *network.Packet) error {
 	if atomic.LoadUint32(&s.isUninstall) == 1 {
 		return plugin.ErrPluginUninstalled
 	}
-	s.packets <- packet
-	return nil
+	select {
+	case s.packets <- packet:
+		return nil
+	case <-s.done:
+		return plugin.ErrPluginUninstalled
+	}
 }
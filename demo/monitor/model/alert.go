@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// AlertStatus 告警状态
+type AlertStatus string
+
+const (
+	Firing   AlertStatus = "firing"   // 告警中
+	Resolved AlertStatus = "resolved" // 已恢复
+)
+
+// Alert 告警通知，同一规则同一分组的告警在恢复前只通知一次
+type Alert struct {
+	Rule     string      `json:"rule"`
+	Group    string      `json:"group"` // 分组字段的值，如endpoint
+	Status   AlertStatus `json:"status"`
+	Value    string      `json:"value,omitempty"` // 触发告警的字段值
+	Message  string      `json:"message,omitempty"`
+	StartsAt time.Time   `json:"startsAt"`
+	At       time.Time   `json:"at"` // 通知时间
+}
@@ -0,0 +1,27 @@
+package model
+
+import "sync/atomic"
+
+// id生成器
+var metricsId int32 = 0
+
+// MetricsRecord 一个endpoint在一个统计窗口内的指标汇总
+type MetricsRecord struct {
+	Id          int
+	Endpoint    string
+	WindowStart int64 // 窗口开始时间，unix毫秒
+	WindowEnd   int64 // 窗口结束时间，unix毫秒，不包含
+	Requests    int
+	Responses   int
+	Errors      int     // 状态码大于等于500的响应数
+	Qps         float64 // 窗口内平均每秒请求数
+	ErrorRatio  float64 // Errors/Responses
+	AvgLatency  float64 // 请求到响应的平均时延，毫秒
+	MaxLatency  float64 // 请求到响应的最大时延，毫秒
+}
+
+func NewMetricsRecord() *MetricsRecord {
+	return &MetricsRecord{
+		Id: int(atomic.AddInt32(&metricsId, 1)),
+	}
+}
@@ -1,15 +1,27 @@
 package model
 
-import "sync/atomic"
+import (
+	"demo/sidecar"
+	"sync/atomic"
+)
 
-// Type 监控记录类型
+// Type 监控记录类型，与access log的类型一致
 type Type string
 
 const (
-	RecvReq  Type = "recv_req"  // 接收请求
-	RecvResp Type = "recv_resp" // 接收响应
-	SendReq  Type = "send_req"  // 发送请求
-	SendResp Type = "send_resp" // 发送响应
+	RecvReq  = Type(sidecar.RecvReq)  // 接收请求
+	RecvResp = Type(sidecar.RecvResp) // 接收响应
+	SendReq  = Type(sidecar.SendReq)  // 发送请求
+	SendResp = Type(sidecar.SendResp) // 发送响应
+)
+
+// 结构化access log的header，由AccessLogSidecar定义，ExtractLogFilter优先从header中提取，不存在时再解析日志文本
+const (
+	EndpointHeader = sidecar.EndpointHeader
+	TypeHeader     = sidecar.TypeHeader
+	ReqIdHeader    = sidecar.ReqIdHeader
+	StatusHeader   = sidecar.StatusHeader
+	TimeHeader     = sidecar.TimeHeader
 )
 
 // id生成器
@@ -21,6 +33,8 @@ type MonitorRecord struct {
 	Endpoint  string
 	Type      Type
 	Timestamp int64
+	ReqId     string
+	Status    int // 响应的状态码，请求记录为0
 }
 
 func NewMonitoryRecord() *MonitorRecord {
@@ -0,0 +1,22 @@
+package model
+
+import "reflect"
+
+// Record 可以保存到数据库的记录
+type Record interface {
+	PrimaryKey() int
+}
+
+// RecordTypes 记录类型，key为类型名
+var RecordTypes = map[string]reflect.Type{
+	"monitor_record": reflect.TypeOf(MonitorRecord{}),
+	"metrics_record": reflect.TypeOf(MetricsRecord{}),
+}
+
+func (m *MonitorRecord) PrimaryKey() int {
+	return m.Id
+}
+
+func (m *MetricsRecord) PrimaryKey() int {
+	return m.Id
+}
@@ -3,45 +3,280 @@ package monitor
 import (
 	"demo/monitor/config"
 	"demo/monitor/pipeline"
-	"demo/monitor/plugin"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
+var (
+	ErrPipelineExists   = errors.New("pipeline already exists")
+	ErrPipelineNotFound = errors.New("pipeline not found")
+)
+
+// 重启配置，通过pipeline的context设置：
+// maxRestarts: pipeline失败后最多自动重启的次数（累计），默认为0，不重启
+// restartBackoff: pipeline失败后等待多久重启，默认1s
+const defaultRestartBackoff = time.Second
+
+// pipelineEntry pipeline插件及其配置，配置用于热加载时判断pipeline是否变化
+type pipelineEntry struct {
+	conf     config.Pipeline
+	plugin   pipeline.Plugin
+	restarts int
+}
+
+// PipelineStatus pipeline的运行状态
+type PipelineStatus struct {
+	Name     string
+	Status   pipeline.Status
+	Err      error // 导致pipeline失败的错误
+	Restarts int   // 失败后自动重启的次数
+	Metrics  pipeline.Metrics
+}
+
+// System 监控系统，管理所有pipeline，运行过程中可以增加、删除、替换pipeline
 type System struct {
-	plugins       map[string]plugin.Plugin
+	mu            sync.Mutex
+	pipelines     map[string]*pipelineEntry
 	configFactory config.Factory
+	isStarted     bool
+	watcher       *confWatcher
 }
 
 func NewSystem(configFactory config.Factory) *System {
 	return &System{
-		plugins:       make(map[string]plugin.Plugin),
+		pipelines:     make(map[string]*pipelineEntry),
 		configFactory: configFactory,
 	}
 }
 
+// LoadConf 加载pipeline配置，同名pipeline已存在且配置变化时替换，系统已启动时新pipeline立即运行
 func (s *System) LoadConf(conf string) error {
-	pipelineConf := s.configFactory.CreatePipelineConfig()
-	if err := pipelineConf.Load(conf); err != nil {
+	entry, err := s.newEntry(conf)
+	if err != nil {
 		return err
 	}
-	pipelinePlugin, err := pipeline.NewPlugin(pipelineConf)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apply(entry)
+	return nil
+}
+
+// AddPipeline 增加pipeline，同名pipeline已存在时返回ErrPipelineExists
+func (s *System) AddPipeline(conf string) error {
+	entry, err := s.newEntry(conf)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pipelines[entry.conf.Name]; ok {
+		return ErrPipelineExists
+	}
+	s.apply(entry)
+	return nil
+}
+
+// ReplacePipeline 使用新配置替换同名pipeline，旧pipeline卸载后新pipeline才会运行
+func (s *System) ReplacePipeline(conf string) error {
+	entry, err := s.newEntry(conf)
 	if err != nil {
 		return err
 	}
-	s.plugins[pipelineConf.Name] = pipelinePlugin
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pipelines[entry.conf.Name]; !ok {
+		return ErrPipelineNotFound
+	}
+	s.apply(entry)
+	return nil
+}
+
+// RemovePipeline 卸载并删除pipeline
+func (s *System) RemovePipeline(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.pipelines[name]
+	if !ok {
+		return ErrPipelineNotFound
+	}
+	delete(s.pipelines, name)
+	s.uninstall(name, entry)
 	return nil
 }
 
+// Reload 将系统中的pipeline同步为confs，只有新增、删除和配置变化的pipeline会重启
+// 所有配置都加载成功后才会生效，任一配置有误时系统保持不变
+func (s *System) Reload(confs ...string) error {
+	entries := make(map[string]*pipelineEntry, len(confs))
+	for _, conf := range confs {
+		entry, err := s.newEntry(conf)
+		if err != nil {
+			return err
+		}
+		if _, ok := entries[entry.conf.Name]; ok {
+			return fmt.Errorf("pipeline %s: %w", entry.conf.Name, ErrPipelineExists)
+		}
+		entries[entry.conf.Name] = entry
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, entry := range s.pipelines {
+		if _, ok := entries[name]; !ok {
+			delete(s.pipelines, name)
+			s.uninstall(name, entry)
+		}
+	}
+	for _, entry := range entries {
+		s.apply(entry)
+	}
+	return nil
+}
+
+// Pipelines 所有pipeline的名称，按名称排序
+func (s *System) Pipelines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.pipelines))
+	for name := range s.pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Status 所有pipeline的运行状态，按名称排序
+func (s *System) Status() []*PipelineStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]*PipelineStatus, 0, len(s.pipelines))
+	for name, entry := range s.pipelines {
+		statuses = append(statuses, &PipelineStatus{
+			Name:     name,
+			Status:   entry.plugin.Status(),
+			Err:      entry.plugin.Err(),
+			Restarts: entry.restarts,
+			Metrics:  entry.plugin.Metrics(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
 func (s *System) Start() {
-	for name, plugin := range s.plugins {
-		plugin.Install()
-		fmt.Printf("plugin %s install success\n", name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isStarted {
+		return
+	}
+	s.isStarted = true
+	for name, entry := range s.pipelines {
+		s.install(name, entry)
 	}
 }
 
+// Shutdown 停止监听配置文件，并卸载所有pipeline
 func (s *System) Shutdown() {
-	for name, plugin := range s.plugins {
-		plugin.Uninstall()
-		fmt.Printf("plugin %s uninstall success\n", name)
+	s.mu.Lock()
+	watcher := s.watcher
+	s.watcher = nil
+	s.mu.Unlock()
+	if watcher != nil {
+		watcher.stop()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStarted {
+		return
+	}
+	for name, entry := range s.pipelines {
+		s.uninstall(name, entry)
+	}
+	s.isStarted = false
+}
+
+func (s *System) newEntry(conf string) (*pipelineEntry, error) {
+	pipelineConf := s.configFactory.CreatePipelineConfig()
+	if err := pipelineConf.Load(conf); err != nil {
+		return nil, err
+	}
+	pipelinePlugin, err := pipeline.NewPlugin(pipelineConf)
+	if err != nil {
+		return nil, err
+	}
+	return &pipelineEntry{conf: pipelineConf, plugin: pipelinePlugin}, nil
+}
+
+// apply 增加或替换pipeline，配置没有变化时保留原pipeline，调用方需持有s.mu
+func (s *System) apply(entry *pipelineEntry) {
+	name := entry.conf.Name
+	if old, ok := s.pipelines[name]; ok {
+		if old.conf.Equal(entry.conf) {
+			return
+		}
+		s.uninstall(name, old)
+	}
+	s.pipelines[name] = entry
+	s.install(name, entry)
+}
+
+// install 系统已启动时安装pipeline，调用方需持有s.mu
+func (s *System) install(name string, entry *pipelineEntry) {
+	if !s.isStarted {
+		return
+	}
+	entry.plugin.SetFailureHandler(func(err error) {
+		s.onFailure(name, entry)
+	})
+	entry.plugin.Install()
+	fmt.Printf("plugin %s install success\n", name)
+}
+
+// onFailure pipeline失败时按照重启配置，等待restartBackoff后重启
+func (s *System) onFailure(name string, entry *pipelineEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStarted || s.pipelines[name] != entry {
+		return
+	}
+	maxRestarts, _ := entry.conf.Ctx.GetInt("maxRestarts")
+	if entry.restarts >= maxRestarts {
+		return
+	}
+	backoff := defaultRestartBackoff
+	if val, ok := entry.conf.Ctx.GetDuration("restartBackoff"); ok {
+		backoff = val
+	}
+	time.AfterFunc(backoff, func() {
+		s.restart(name, entry)
+	})
+}
+
+// restart 卸载失败的pipeline，并按照原配置重新创建一个pipeline运行
+func (s *System) restart(name string, failed *pipelineEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStarted || s.pipelines[name] != failed {
+		return
+	}
+	pipelinePlugin, err := pipeline.NewPlugin(failed.conf)
+	if err != nil {
+		fmt.Printf("plugin %s restart err %s\n", name, err.Error())
+		return
+	}
+	entry := &pipelineEntry{conf: failed.conf, plugin: pipelinePlugin, restarts: failed.restarts + 1}
+	s.uninstall(name, failed)
+	s.pipelines[name] = entry
+	s.install(name, entry)
+}
+
+// uninstall 系统已启动时卸载pipeline，调用方需持有s.mu
+func (s *System) uninstall(name string, entry *pipelineEntry) {
+	if !s.isStarted {
+		return
 	}
+	entry.plugin.Uninstall()
+	fmt.Printf("plugin %s uninstall success\n", name)
 }
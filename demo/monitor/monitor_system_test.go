@@ -4,7 +4,14 @@ import (
 	"demo/db"
 	"demo/monitor/config"
 	"demo/monitor/model"
+	"demo/monitor/output"
+	"demo/monitor/pipeline"
+	"demo/monitor/plugin"
 	"demo/mq"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -31,3 +38,137 @@ func TestMonitorSystem(t *testing.T) {
 	db.MemoryDbInstance().Clear()
 	mq.MemoryMqInstance().Clear()
 }
+
+func pipelineConf(name, topic, table string) string {
+	return "name: " + name + "\ntype: simple\ninput:\n  name: input_0\n  type: memory_mq\n  context:\n    topic: " + topic +
+		"\nfilters:\n  - name: filter_0\n    type: extract_log\noutput:\n  name: output_0\n  type: memory_db\n  context:\n    tableName: " + table
+}
+
+func TestMonitorSystemReload(t *testing.T) {
+	system := NewSystem(config.NewYamlFactory())
+	defer mq.MemoryMqInstance().Clear()
+	defer db.MemoryDbInstance().Clear()
+	if err := system.AddPipeline(pipelineConf("pipeline_a", "reload_a.topic", "reload_a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.AddPipeline(pipelineConf("pipeline_a", "reload_a.topic", "reload_a")); err != ErrPipelineExists {
+		t.Errorf("add twice want ErrPipelineExists, got %v", err)
+	}
+	system.Start()
+	defer system.Shutdown()
+	a := system.pipelines["pipeline_a"].plugin
+
+	// 未变化的pipeline不会重启
+	err := system.Reload(pipelineConf("pipeline_a", "reload_a.topic", "reload_a"),
+		pipelineConf("pipeline_b", "reload_b.topic", "reload_b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := system.Pipelines(); len(names) != 2 || names[0] != "pipeline_a" || names[1] != "pipeline_b" {
+		t.Fatalf("want [pipeline_a pipeline_b] got %v", names)
+	}
+	if system.pipelines["pipeline_a"].plugin != a {
+		t.Errorf("pipeline_a restarted without changes")
+	}
+
+	// 配置变化的pipeline被替换，新pipeline消费新的topic
+	if err := system.ReplacePipeline(pipelineConf("pipeline_a", "reload_c.topic", "reload_a")); err != nil {
+		t.Fatal(err)
+	}
+	if system.pipelines["pipeline_a"].plugin == a {
+		t.Errorf("pipeline_a not restarted after changes")
+	}
+	log := "[192.168.1.1:8088][recv_req]receive request from address 192.168.1.91:80 success"
+	mq.MemoryMqInstance().Produce(mq.NewMessage("reload_c.topic", log))
+	time.Sleep(100 * time.Millisecond)
+	records, err := db.MemoryDbInstance().QueryByField("reload_a", "endpoint", "192.168.1.1:8088")
+	if err != nil || len(records) != 1 {
+		t.Errorf("want 1 record of 192.168.1.1:8088 got %v, %v", records, err)
+	}
+
+	// 配置有误时保持不变
+	if err := system.Reload(pipelineConf("pipeline_a", "reload_a.topic", "reload_a"), "name: bad\ntype: unknown"); err == nil {
+		t.Errorf("reload with bad conf want error")
+	}
+	if err := system.RemovePipeline("pipeline_b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.RemovePipeline("pipeline_b"); err != ErrPipelineNotFound {
+		t.Errorf("remove twice want ErrPipelineNotFound, got %v", err)
+	}
+	if err := system.Reload(); err != nil || len(system.Pipelines()) != 0 {
+		t.Errorf("reload empty want no pipelines, got %v, %v", system.Pipelines(), err)
+	}
+}
+
+func TestMonitorSystemWatchConf(t *testing.T) {
+	system := NewSystem(config.NewYamlFactory())
+	defer mq.MemoryMqInstance().Clear()
+	defer db.MemoryDbInstance().Clear()
+	path := filepath.Join(t.TempDir(), "monitor_pipeline.yaml")
+	if err := os.WriteFile(path, []byte(pipelineConf("pipeline_a", "watch_a.topic", "watch_a")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.WatchConf(path, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	system.Start()
+	defer system.Shutdown()
+	if names := system.Pipelines(); len(names) != 1 || names[0] != "pipeline_a" {
+		t.Fatalf("want [pipeline_a] got %v", names)
+	}
+
+	conf := pipelineConf("pipeline_b", "watch_b.topic", "watch_b") + "\n---\n" + pipelineConf("pipeline_c", "watch_c.topic", "watch_c")
+	if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if names := system.Pipelines(); len(names) != 2 || names[0] != "pipeline_b" || names[1] != "pipeline_c" {
+		t.Errorf("want [pipeline_b pipeline_c] got %v", names)
+	}
+}
+
+var errAlwaysFail = errors.New("always fail")
+
+// alwaysFailOutput 输出总是失败的测试output
+type alwaysFailOutput struct {
+}
+
+func (a *alwaysFailOutput) Install()                      {}
+func (a *alwaysFailOutput) Uninstall()                    {}
+func (a *alwaysFailOutput) SetContext(ctx plugin.Context) {}
+
+func (a *alwaysFailOutput) Output(event *plugin.Event) error {
+	return errAlwaysFail
+}
+
+func TestMonitorSystemSupervise(t *testing.T) {
+	output.Type["always_fail"] = reflect.TypeOf(alwaysFailOutput{})
+	defer delete(output.Type, "always_fail")
+	defer mq.MemoryMqInstance().Clear()
+	system := NewSystem(config.NewYamlFactory())
+	conf := "name: pipeline_fail\ntype: simple\ncontext:\n  maxRestarts: 2\n  restartBackoff: 10ms\n" +
+		"input:\n  name: input_0\n  type: memory_mq\n  context:\n    topic: supervise.topic\n" +
+		"output:\n  name: output_0\n  type: always_fail"
+	if err := system.AddPipeline(conf); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := system.Status(); len(statuses) != 1 || statuses[0].Status != pipeline.Stopped {
+		t.Fatalf("want stopped before start, got %+v", statuses[0])
+	}
+	system.Start()
+	defer system.Shutdown()
+	if status := system.Status()[0]; status.Status != pipeline.Running {
+		t.Fatalf("want running after start, got %s", status.Status)
+	}
+
+	// 每条消息导致一次失败，重启2次后不再重启
+	for i := 0; i < 3; i++ {
+		mq.MemoryMqInstance().Produce(mq.NewMessage("supervise.topic", "log"))
+		time.Sleep(50 * time.Millisecond)
+	}
+	status := system.Status()[0]
+	if status.Status != pipeline.Failed || status.Err != errAlwaysFail || status.Restarts != 2 {
+		t.Errorf("want failed with 2 restarts, got %s, %v, %d", status.Status, status.Err, status.Restarts)
+	}
+}
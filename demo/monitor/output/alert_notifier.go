@@ -0,0 +1,79 @@
+package output
+
+import (
+	"demo/monitor/model"
+	"demo/mq"
+	"demo/network"
+	"demo/network/http"
+	"fmt"
+)
+
+// 默认的告警通知topic和uri
+const (
+	defaultAlertTopic = "monitor_alert.topic"
+	defaultAlertUri   = "/api/v1/alerts"
+)
+
+func init() {
+	http.RegisterBodyType(new(model.Alert))
+}
+
+// notifier 告警通知方式
+type notifier interface {
+	notify(alert *model.Alert) error
+	close()
+}
+
+// mqNotifier 将告警发送到mq的topic上，以规则名和分组作为key，保证同一告警的通知顺序
+type mqNotifier struct {
+	producer mq.Producible
+	topic    mq.Topic
+}
+
+func (m *mqNotifier) notify(alert *model.Alert) error {
+	return m.producer.Produce(mq.NewObjectMessage(m.topic, alert).WithKey(alert.Rule + "|" + alert.Group))
+}
+
+func (m *mqNotifier) close() {
+}
+
+// httpNotifier 通过POST请求将告警发送到http服务，body为*model.Alert
+// 直接使用network.DefaultSocket，避免告警通知产生新的access log
+type httpNotifier struct {
+	localIp string
+	dest    network.Endpoint
+	uri     http.Uri
+	pool    *http.ClientPool
+}
+
+func newHttpNotifier(localIp string, dest network.Endpoint, uri http.Uri) *httpNotifier {
+	return &httpNotifier{
+		localIp: localIp,
+		dest:    dest,
+		uri:     uri,
+		pool: http.NewClientPool(func() network.Socket {
+			return network.DefaultSocket()
+		}),
+	}
+}
+
+func (h *httpNotifier) notify(alert *model.Alert) error {
+	client, err := h.pool.Get(h.localIp)
+	if err != nil {
+		return err
+	}
+	defer h.pool.Put(client)
+	req := http.EmptyRequest().AddMethod(http.POST).AddUri(h.uri).AddBody(alert)
+	resp, err := client.Send(h.dest, req)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("notify alert failed, status %d: %s", resp.StatusCode().Code, resp.ProblemDetails())
+	}
+	return nil
+}
+
+func (h *httpNotifier) close() {
+	h.pool.Close()
+}
@@ -0,0 +1,215 @@
+package output
+
+import (
+	"demo/monitor/filter"
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"demo/mq"
+	"demo/network"
+	"demo/network/http"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 默认的数据缺失检查间隔
+const defaultCheckInterval = time.Second
+
+// AlertOutput 告警插件，按照告警规则评估每条记录，告警状态变化时发送通知
+// 同一规则同一分组的告警在恢复前只通知一次，配置repeatInterval时告警期间按该间隔重复通知
+// context配置：
+//
+//	rule.<name>.type为规则类型，可选threshold、change、absence；rule.<name>.groupBy为分组字段，默认endpoint；
+//	rule.<name>.message为通知中携带的描述；threshold规则配置when条件表达式和可选的field；
+//	change规则配置数值字段field和变化率rate；absence规则配置缺失时长for；
+//	notify为通知方式，可选mq、http，默认mq；mq方式配置topic，默认monitor_alert.topic；
+//	http方式配置endpoint（ip:port）、uri（默认/api/v1/alerts）和本机ip；
+//	checkInterval为数据缺失的检查间隔，默认1s
+//
+// 规则配置有误时Output返回错误
+type AlertOutput struct {
+	rules          []*ruleConf
+	err            error
+	notifyType     string
+	topic          mq.Topic
+	localIp        string
+	dest           network.Endpoint
+	uri            http.Uri
+	repeatInterval time.Duration
+	checkInterval  time.Duration
+	notifier       notifier
+	mu             sync.Mutex
+	alerts         map[string]*alertEntry // key为规则名|分组
+	stop           chan struct{}
+	wg             sync.WaitGroup
+}
+
+// alertEntry 告警中的告警
+type alertEntry struct {
+	alert      model.Alert
+	lastNotify time.Time
+}
+
+func (a *AlertOutput) Install() {
+	if a.notifyType == "http" {
+		a.notifier = newHttpNotifier(a.localIp, a.dest, a.uri)
+	} else {
+		a.notifier = &mqNotifier{producer: mq.MemoryMqInstance(), topic: a.topic}
+	}
+	a.alerts = make(map[string]*alertEntry)
+	a.stop = make(chan struct{})
+	a.wg.Add(1)
+	go a.watch()
+}
+
+func (a *AlertOutput) Uninstall() {
+	close(a.stop)
+	a.wg.Wait()
+	a.notifier.close()
+}
+
+func (a *AlertOutput) SetContext(ctx plugin.Context) {
+	if a.rules, a.err = parseRules(ctx); a.err != nil {
+		fmt.Printf("alert output %s\n", a.err.Error())
+	}
+	a.notifyType, _ = ctx.GetString("notify")
+	a.topic = defaultAlertTopic
+	if topic, ok := ctx.GetString("topic"); ok {
+		a.topic = mq.Topic(topic)
+	}
+	a.localIp, _ = ctx.GetString("ip")
+	if endpoint, ok := ctx.GetString("endpoint"); ok {
+		_ = a.dest.UnmarshalText([]byte(endpoint))
+	}
+	a.uri = defaultAlertUri
+	if uri, ok := ctx.GetString("uri"); ok {
+		a.uri = http.Uri(uri)
+	}
+	a.repeatInterval, _ = ctx.GetDuration("repeatInterval")
+	a.checkInterval = defaultCheckInterval
+	if interval, ok := ctx.GetDuration("checkInterval"); ok && interval > 0 {
+		a.checkInterval = interval
+	}
+}
+
+func (a *AlertOutput) Output(event *plugin.Event) error {
+	if a.err != nil {
+		return a.err
+	}
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var result error
+	for _, rule := range a.rules {
+		group := ""
+		if value, ok := filter.FieldOf(event, rule.groupBy); ok {
+			group = fmt.Sprint(value)
+		}
+		// 通知失败时不提交规则的状态，按照错误策略重试时重新评估同一记录
+		if firing, value, ok := rule.rule.observe(group, event, now); ok {
+			if err := a.transition(rule, group, firing, value, now); err != nil {
+				if result == nil {
+					result = err
+				}
+				continue
+			}
+		}
+		rule.rule.commit(group, event, now)
+	}
+	return result
+}
+
+// Firing 告警中的告警，按照规则名和分组排序
+func (a *AlertOutput) Firing() []model.Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alerts := make([]model.Alert, 0, len(a.alerts))
+	for _, entry := range a.alerts {
+		alerts = append(alerts, entry.alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].Group < alerts[j].Group
+	})
+	return alerts
+}
+
+// transition 更新告警状态，只有状态变化或到达重复通知间隔时才通知，通知失败时状态不变
+func (a *AlertOutput) transition(rule *ruleConf, group string, firing bool, value string, now time.Time) error {
+	key := rule.name + "|" + group
+	entry, exists := a.alerts[key]
+	switch {
+	case firing && !exists:
+		alert := model.Alert{
+			Rule:     rule.name,
+			Group:    group,
+			Status:   model.Firing,
+			Value:    value,
+			Message:  rule.message,
+			StartsAt: now,
+			At:       now,
+		}
+		if err := a.notify(alert); err != nil {
+			return err
+		}
+		a.alerts[key] = &alertEntry{alert: alert, lastNotify: now}
+	case firing && exists:
+		entry.alert.Value = value
+		if a.repeatInterval <= 0 || now.Sub(entry.lastNotify) < a.repeatInterval {
+			return nil
+		}
+		alert := entry.alert
+		alert.At = now
+		if err := a.notify(alert); err != nil {
+			return err
+		}
+		entry.lastNotify = now
+	case !firing && exists:
+		alert := entry.alert
+		alert.Status = model.Resolved
+		alert.At = now
+		if value != "" {
+			alert.Value = value
+		}
+		if err := a.notify(alert); err != nil {
+			return err
+		}
+		delete(a.alerts, key)
+	}
+	return nil
+}
+
+// notify 每次通知发送告警的副本，避免通知后被修改
+func (a *AlertOutput) notify(alert model.Alert) error {
+	return a.notifier.notify(&alert)
+}
+
+// watch 定期检查需要定时判断的规则，如数据缺失
+func (a *AlertOutput) watch() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case now := <-ticker.C:
+			a.check(now)
+		}
+	}
+}
+
+func (a *AlertOutput) check(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, rule := range a.rules {
+		for group, firing := range rule.rule.check(now) {
+			if err := a.transition(rule, group, firing, "", now); err != nil {
+				fmt.Printf("alert output notify rule %s of %s failed: %s\n", rule.name, group, err.Error())
+			}
+		}
+	}
+}
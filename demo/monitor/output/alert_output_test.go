@@ -0,0 +1,246 @@
+package output
+
+import (
+	"demo/monitor/config"
+	"demo/monitor/model"
+	"demo/monitor/plugin"
+	"demo/mq"
+	"demo/network"
+	"demo/network/http"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func metricsEvent(endpoint string, errorRatio, qps float64) *plugin.Event {
+	record := model.NewMetricsRecord()
+	record.Endpoint, record.ErrorRatio, record.Qps = endpoint, errorRatio, qps
+	return plugin.NewEvent(record)
+}
+
+// consumeAlerts 消费topic上的所有告警通知
+func consumeAlerts(t *testing.T, topic mq.Topic) []*model.Alert {
+	messages, err := mq.MemoryMqInstance().ConsumeN(topic, 100, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alerts []*model.Alert
+	for _, message := range messages {
+		alerts = append(alerts, message.Value().(*model.Alert))
+	}
+	return alerts
+}
+
+func TestAlertOutputThreshold(t *testing.T) {
+	defer mq.MemoryMqInstance().Clear()
+	ctx := plugin.Context{
+		"topic":                   "alert_threshold.topic",
+		"rule.high_error.type":    "threshold",
+		"rule.high_error.when":    "errorRatio > 0.1",
+		"rule.high_error.field":   "errorRatio",
+		"rule.high_error.message": "error ratio too high",
+		"rule.qps_jump.type":      "change",
+		"rule.qps_jump.field":     "qps",
+		"rule.qps_jump.rate":      "1",
+		"rule.qps_jump.groupBy":   "endpoint",
+		"rule.unused_field.type":  "change",
+		"rule.unused_field.field": "missing",
+		"rule.unused_field.rate":  "0.5",
+		"checkInterval":           "1h",
+	}
+	outputPlugin, err := NewPlugin(config.Output{Name: "output0", PluginType: "alert", Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputPlugin.Install()
+	defer outputPlugin.Uninstall()
+
+	// 连续超过阈值只通知一次
+	for _, event := range []*plugin.Event{metricsEvent("a", 0.5, 10), metricsEvent("a", 0.6, 10), metricsEvent("b", 0, 10)} {
+		if err := outputPlugin.Output(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alerts := consumeAlerts(t, "alert_threshold.topic")
+	if len(alerts) != 1 || alerts[0].Rule != "high_error" || alerts[0].Group != "a" || alerts[0].Status != model.Firing ||
+		alerts[0].Value != "0.5" || alerts[0].Message != "error ratio too high" {
+		t.Fatalf("want firing alert of a, got %+v", alerts)
+	}
+	if firing := outputPlugin.(*AlertOutput).Firing(); len(firing) != 1 || firing[0].Value != "0.6" {
+		t.Errorf("want 1 firing alert with value 0.6, got %+v", firing)
+	}
+	// 缺少errorRatio字段的记录与阈值规则无关，不会使告警恢复
+	if err := outputPlugin.Output(plugin.NewEvent("log").AddHeader("endpoint", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if alerts := consumeAlerts(t, "alert_threshold.topic"); len(alerts) != 0 {
+		t.Fatalf("want no alert, got %+v", alerts)
+	}
+
+	// 恢复时通知，qps翻倍触发变化率告警
+	outputPlugin.Output(metricsEvent("a", 0, 20))
+	alerts = consumeAlerts(t, "alert_threshold.topic")
+	if len(alerts) != 2 || alerts[0].Rule != "high_error" || alerts[0].Status != model.Resolved ||
+		alerts[1].Rule != "qps_jump" || alerts[1].Status != model.Firing || alerts[1].Value != "20" {
+		t.Fatalf("want resolved high_error and firing qps_jump, got %+v", alerts)
+	}
+	if !alerts[0].StartsAt.Before(alerts[0].At) {
+		t.Errorf("want resolved alert keep start time, got %+v", alerts[0])
+	}
+}
+
+func TestAlertOutputAbsence(t *testing.T) {
+	defer mq.MemoryMqInstance().Clear()
+	outputPlugin := &AlertOutput{}
+	outputPlugin.SetContext(plugin.Context{
+		"topic":             "alert_absence.topic",
+		"rule.no_data.type": "absence",
+		"rule.no_data.for":  "30ms",
+		"checkInterval":     "10ms",
+	})
+	outputPlugin.Install()
+	defer outputPlugin.Uninstall()
+
+	outputPlugin.Output(metricsEvent("a", 0, 1))
+	time.Sleep(60 * time.Millisecond)
+	alerts := consumeAlerts(t, "alert_absence.topic")
+	if len(alerts) != 1 || alerts[0].Group != "a" || alerts[0].Status != model.Firing {
+		t.Fatalf("want 1 firing alert of a, got %+v", alerts)
+	}
+	outputPlugin.Output(metricsEvent("a", 0, 1))
+	alerts = consumeAlerts(t, "alert_absence.topic")
+	if len(alerts) != 1 || alerts[0].Status != model.Resolved {
+		t.Fatalf("want resolved alert of a, got %+v", alerts)
+	}
+}
+
+func TestAlertOutputHttp(t *testing.T) {
+	var mu sync.Mutex
+	var received []*model.Alert
+	server := http.NewServer(network.DefaultSocket()).Listen("192.168.0.60", 80).
+		Post("/alerts", func(req *http.Request) *http.Response {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, req.Body().(*model.Alert))
+			return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusNoContent)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	outputPlugin := &AlertOutput{}
+	outputPlugin.SetContext(plugin.Context{
+		"notify":               "http",
+		"ip":                   "192.168.0.61",
+		"endpoint":             "192.168.0.60:80",
+		"uri":                  "/alerts",
+		"repeatInterval":       "1ns",
+		"rule.high_error.type": "threshold",
+		"rule.high_error.when": "errorRatio > 0.1",
+	})
+	outputPlugin.Install()
+	defer outputPlugin.Uninstall()
+
+	// 配置了重复通知间隔时告警期间重复通知
+	for i := 0; i < 2; i++ {
+		if err := outputPlugin.Output(metricsEvent("a", 0.5, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Rule != "high_error" || received[1].Status != model.Firing {
+		t.Errorf("want 2 firing alerts received, got %+v", received)
+	}
+}
+
+func TestAlertOutputInvalidRule(t *testing.T) {
+	for _, ctx := range []plugin.Context{
+		{"rule.a.type": "unknown"},
+		{"rule.a.type": "threshold", "rule.a.when": "errorRatio >"},
+		{"rule.a.type": "change", "rule.a.field": "qps"},
+		{"rule.a.type": "absence"},
+		{"rule.type": "threshold"},
+	} {
+		outputPlugin := &AlertOutput{}
+		outputPlugin.SetContext(ctx)
+		if err := outputPlugin.Output(metricsEvent("a", 0, 1)); err == nil {
+			t.Errorf("context %v want error", ctx)
+		}
+	}
+	outputPlugin := &AlertOutput{}
+	outputPlugin.SetContext(plugin.Context{"rule.a.type": "unknown"})
+	if err := outputPlugin.Output(metricsEvent("a", 0, 1)); !errors.Is(err, ErrInvalidAlertRule) {
+		t.Errorf("want ErrInvalidAlertRule got %v", err)
+	}
+}
+
+// flakyNotifier 规则为rule的告警通知失败failures次
+type flakyNotifier struct {
+	rule     string
+	failures int
+	alerts   []*model.Alert
+}
+
+func (f *flakyNotifier) notify(alert *model.Alert) error {
+	if alert.Rule == f.rule && f.failures > 0 {
+		f.failures--
+		return errors.New("notify failed")
+	}
+	f.alerts = append(f.alerts, alert)
+	return nil
+}
+
+func (f *flakyNotifier) close() {
+}
+
+func TestAlertOutputRetry(t *testing.T) {
+	outputPlugin := &AlertOutput{}
+	outputPlugin.SetContext(plugin.Context{
+		"rule.high_error.type": "threshold",
+		"rule.high_error.when": "errorRatio > 0.1",
+		"rule.qps_jump.type":   "change",
+		"rule.qps_jump.field":  "qps",
+		"rule.qps_jump.rate":   "1",
+		"checkInterval":        "1h",
+	})
+	outputPlugin.Install()
+	defer outputPlugin.Uninstall()
+	notifier := &flakyNotifier{}
+	outputPlugin.notifier = notifier
+	// output 模拟errorPolicy为retry，失败时重新输出同一记录
+	output := func(rule string, event *plugin.Event) {
+		notifier.rule, notifier.failures = rule, 1
+		if err := outputPlugin.Output(event); err == nil {
+			t.Fatalf("want notify error of %s", rule)
+		}
+		if err := outputPlugin.Output(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputPlugin.Output(metricsEvent("a", 0, 10))
+	// 变化率告警通知失败，重试时仍与上一条记录比较
+	output("qps_jump", metricsEvent("a", 0, 20))
+	outputPlugin.Output(metricsEvent("a", 0, 20))
+	// 其他规则通知失败，重试时已通知的变化率告警不会恢复
+	output("high_error", metricsEvent("a", 0.5, 40))
+
+	want := []struct {
+		rule   string
+		status model.AlertStatus
+	}{
+		{"qps_jump", model.Firing}, {"qps_jump", model.Resolved}, {"qps_jump", model.Firing}, {"high_error", model.Firing},
+	}
+	alerts := notifier.alerts
+	if len(alerts) != len(want) {
+		t.Fatalf("want %d alerts, got %+v", len(want), alerts)
+	}
+	for i, alert := range alerts {
+		if alert.Rule != want[i].rule || alert.Status != want[i].status {
+			t.Errorf("alert %d want %s %v, got %+v", i, want[i].rule, want[i].status, alert)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package output
+
+import (
+	"demo/monitor/filter"
+	"demo/monitor/plugin"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+策略模式
+*/
+
+var (
+	ErrInvalidAlertRule = errors.New("invalid alert rule")
+)
+
+// 规则配置的前缀，规则配置为rule.<name>.<key>: <value>
+const rulePrefix = "rule."
+
+// 默认的告警分组字段
+const defaultGroupBy = "endpoint"
+
+// alertRule 告警规则
+type alertRule interface {
+	// observe 根据记录计算分组的告警状态及触发告警的字段值，ok为false表示记录与规则无关，不修改规则的状态
+	observe(group string, event *plugin.Event, now time.Time) (firing bool, value string, ok bool)
+	// commit 告警状态更新成功后提交记录，通知失败时不提交，重新输出同一记录时得到相同的结果
+	commit(group string, event *plugin.Event, now time.Time)
+	// check 定期检查各分组的告警状态，key为分组，只返回状态可以确定的分组
+	check(now time.Time) map[string]bool
+}
+
+// ruleConf 告警规则的公共配置
+type ruleConf struct {
+	name    string
+	groupBy string
+	message string
+	rule    alertRule
+}
+
+// parseRules 从context中解析告警规则，按规则名排序
+func parseRules(ctx plugin.Context) ([]*ruleConf, error) {
+	confs := make(map[string]plugin.Context)
+	for key, value := range ctx {
+		if !strings.HasPrefix(key, rulePrefix) {
+			continue
+		}
+		idx := strings.LastIndex(key, ".")
+		if idx <= len(rulePrefix) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAlertRule, key)
+		}
+		name := key[len(rulePrefix):idx]
+		if _, ok := confs[name]; !ok {
+			confs[name] = plugin.EmptyContext()
+		}
+		confs[name].Add(key[idx+1:], value)
+	}
+	var rules []*ruleConf
+	for name, ruleCtx := range confs {
+		rule, err := newAlertRule(ruleCtx)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		conf := &ruleConf{name: name, groupBy: defaultGroupBy, rule: rule}
+		if groupBy, ok := ruleCtx.GetString("groupBy"); ok {
+			conf.groupBy = groupBy
+		}
+		conf.message, _ = ruleCtx.GetString("message")
+		rules = append(rules, conf)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].name < rules[j].name })
+	return rules, nil
+}
+
+// newAlertRule 告警规则工厂方法，type可选threshold、change、absence
+func newAlertRule(ctx plugin.Context) (alertRule, error) {
+	ruleType, _ := ctx.GetString("type")
+	field, _ := ctx.GetString("field")
+	switch ruleType {
+	case "threshold":
+		when, ok := ctx.GetString("when")
+		if !ok {
+			return nil, fmt.Errorf("%w: threshold rule requires when", ErrInvalidAlertRule)
+		}
+		cond, err := filter.ParseCondition(when)
+		if err != nil {
+			return nil, err
+		}
+		return &thresholdRule{when: cond, field: field}, nil
+	case "change":
+		rate, err := strconv.ParseFloat(ctx["rate"], 64)
+		if field == "" || err != nil || rate <= 0 {
+			return nil, fmt.Errorf("%w: change rule requires field and positive rate", ErrInvalidAlertRule)
+		}
+		return &changeRule{field: field, rate: rate, samples: make(map[string]changeSample)}, nil
+	case "absence":
+		duration, ok := ctx.GetDuration("for")
+		if !ok || duration <= 0 {
+			return nil, fmt.Errorf("%w: absence rule requires for", ErrInvalidAlertRule)
+		}
+		return &absenceRule{duration: duration, lastSeen: make(map[string]time.Time)}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %s", ErrInvalidAlertRule, ruleType)
+}
+
+// thresholdRule 阈值规则，记录满足when条件表达式时告警，不满足时恢复，表达式语法见filter.Condition
+// field为通知中携带的字段值；记录缺少when引用的字段时与规则无关，不会使告警恢复
+type thresholdRule struct {
+	when  filter.Condition
+	field string
+}
+
+func (t *thresholdRule) observe(group string, event *plugin.Event, now time.Time) (bool, string, bool) {
+	for _, name := range t.when.Fields() {
+		if _, ok := filter.FieldOf(event, name); !ok {
+			return false, "", false
+		}
+	}
+	var value string
+	if t.field != "" {
+		if v, ok := filter.FieldOf(event, t.field); ok {
+			value = fmt.Sprint(v)
+		}
+	}
+	return t.when.Interpret(event), value, true
+}
+
+func (t *thresholdRule) commit(group string, event *plugin.Event, now time.Time) {
+}
+
+func (t *thresholdRule) check(now time.Time) map[string]bool {
+	return nil
+}
+
+// changeRule 变化率规则，数值字段相对同一分组上一条记录的变化率（绝对值）不小于rate时告警，否则恢复
+type changeRule struct {
+	field   string
+	rate    float64
+	samples map[string]changeSample
+}
+
+// changeSample 分组最近提交的记录，同一记录再次输出时（如部分规则通知失败后重试）与该记录之前的值比较
+type changeSample struct {
+	event    *plugin.Event
+	value    float64
+	previous float64
+	compared bool // 是否有之前的值
+}
+
+func (c *changeRule) valueOf(event *plugin.Event) (float64, bool) {
+	v, ok := filter.FieldOf(event, c.field)
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+	return value, err == nil
+}
+
+func (c *changeRule) observe(group string, event *plugin.Event, now time.Time) (bool, string, bool) {
+	value, ok := c.valueOf(event)
+	if !ok {
+		return false, "", false
+	}
+	sample, ok := c.samples[group]
+	previous := sample.value
+	if ok && sample.event == event {
+		previous, ok = sample.previous, sample.compared
+	}
+	if !ok {
+		return false, "", false
+	}
+	change := math.Inf(1)
+	if previous != 0 {
+		change = math.Abs(value-previous) / math.Abs(previous)
+	} else if value == 0 {
+		change = 0
+	}
+	return change >= c.rate, fmt.Sprint(value), true
+}
+
+func (c *changeRule) commit(group string, event *plugin.Event, now time.Time) {
+	value, ok := c.valueOf(event)
+	if !ok {
+		return
+	}
+	sample, ok := c.samples[group]
+	if ok && sample.event == event {
+		return
+	}
+	c.samples[group] = changeSample{event: event, value: value, previous: sample.value, compared: ok}
+}
+
+func (c *changeRule) check(now time.Time) map[string]bool {
+	return nil
+}
+
+// absenceRule 数据缺失规则，分组超过duration没有记录时告警，再次收到记录时恢复
+// 只能检测到收到过记录的分组
+type absenceRule struct {
+	duration time.Duration
+	lastSeen map[string]time.Time
+}
+
+func (a *absenceRule) observe(group string, event *plugin.Event, now time.Time) (bool, string, bool) {
+	return false, "", true
+}
+
+func (a *absenceRule) commit(group string, event *plugin.Event, now time.Time) {
+	a.lastSeen[group] = now
+}
+
+func (a *absenceRule) check(now time.Time) map[string]bool {
+	states := make(map[string]bool)
+	for group, lastSeen := range a.lastSeen {
+		if now.Sub(lastSeen) >= a.duration {
+			states[group] = true
+		}
+	}
+	return states
+}
@@ -6,17 +6,35 @@ import (
 	"demo/monitor/plugin"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
+// 路由表配置的前缀
+const routePrefix = "route."
+
+// 默认的记录类型
+const defaultRecordType = "monitor_record"
+
+// MemoryDbOutput 将记录保存到内存数据库的tableName表中
+// context中配置recordType为记录类型，取值见model.RecordTypes，默认为monitor_record；
+// 配置route.<route>: <table>时，路由标签为route的事件保存到table表中
 type MemoryDbOutput struct {
-	db        db.Db
-	tableName string
+	db          db.Db
+	tableName   string
+	recordType  reflect.Type
+	routeTables map[string]string
 }
 
 func (m *MemoryDbOutput) Install() {
 	m.db = db.MemoryDbInstance()
-	table := db.NewTable(m.tableName).WithType(reflect.TypeOf(new(model.MonitorRecord)))
-	m.db.CreateTableIfNotExist(table)
+	tables := []string{m.tableName}
+	for _, table := range m.routeTables {
+		tables = append(tables, table)
+	}
+	for _, tableName := range tables {
+		table := db.NewTable(tableName).WithType(m.recordType)
+		m.db.CreateTableIfNotExist(table)
+	}
 }
 
 func (m *MemoryDbOutput) Uninstall() {
@@ -26,12 +44,61 @@ func (m *MemoryDbOutput) SetContext(ctx plugin.Context) {
 	if name, ok := ctx.GetString("tableName"); ok {
 		m.tableName = name
 	}
+	m.recordType = model.RecordTypes[defaultRecordType]
+	if name, ok := ctx.GetString("recordType"); ok {
+		if recordType, ok := model.RecordTypes[name]; ok {
+			m.recordType = recordType
+		} else {
+			fmt.Printf("memory db output unknown record type %s, use %s\n", name, defaultRecordType)
+		}
+	}
+	m.routeTables = make(map[string]string)
+	for key, value := range ctx {
+		if strings.HasPrefix(key, routePrefix) {
+			m.routeTables[strings.TrimPrefix(key, routePrefix)] = value
+		}
+	}
 }
 
 func (m *MemoryDbOutput) Output(event *plugin.Event) error {
-	r, ok := event.Payload().(*model.MonitorRecord)
-	if !ok {
-		return fmt.Errorf("memory db output unknown event type %T", event.Payload())
+	r, err := m.recordOf(event)
+	if err != nil {
+		return err
+	}
+	return m.db.Insert(m.tableOf(event), r.PrimaryKey(), r)
+}
+
+// OutputBatch 在一个事务中插入所有记录，有记录插入失败时回滚
+func (m *MemoryDbOutput) OutputBatch(events []*plugin.Event) error {
+	tx := db.NewTransaction(m.tableName, m.db)
+	tx.Begin()
+	for _, event := range events {
+		r, err := m.recordOf(event)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec(db.NewInsertCmd(m.tableOf(event)).WithPrimaryKey(r.PrimaryKey()).WithRecord(r)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// recordOf 事件的payload需为配置的记录类型的指针
+func (m *MemoryDbOutput) recordOf(event *plugin.Event) (model.Record, error) {
+	r, ok := event.Payload().(model.Record)
+	if !ok || reflect.TypeOf(r).Elem() != m.recordType {
+		return nil, fmt.Errorf("memory db output unknown event type %T", event.Payload())
+	}
+	return r, nil
+}
+
+// tableOf 事件保存的表，没有路由标签或路由未配置表时为tableName
+func (m *MemoryDbOutput) tableOf(event *plugin.Event) string {
+	if route, ok := event.Header(plugin.RouteHeader); ok {
+		if table, ok := m.routeTables[route]; ok {
+			return table
+		}
 	}
-	return m.db.Insert(m.tableName, r.Id, r)
+	return m.tableName
 }
@@ -38,3 +38,71 @@ func TestMemoryDbOutput(t *testing.T) {
 	mo.db.DeleteTable(mo.tableName)
 	mo.Uninstall()
 }
+
+func TestMemoryDbOutputBatch(t *testing.T) {
+	mo := &MemoryDbOutput{}
+	mo.SetContext(plugin.Context{"tableName": "test_batch"})
+	mo.Install()
+	defer mo.Uninstall()
+	defer mo.db.DeleteTable(mo.tableName)
+
+	first, second := model.NewMonitoryRecord(), model.NewMonitoryRecord()
+	if err := OutputBatch(mo, []*plugin.Event{plugin.NewEvent(first), plugin.NewEvent(second)}); err != nil {
+		t.Fatal(err)
+	}
+	result := new(model.MonitorRecord)
+	if err := mo.db.Query(mo.tableName, second.Id, result); err != nil {
+		t.Errorf("query second record err %v", err)
+	}
+
+	// 有记录插入失败时整批回滚
+	third := model.NewMonitoryRecord()
+	err := OutputBatch(mo, []*plugin.Event{plugin.NewEvent(third), plugin.NewEvent(first)})
+	if err == nil {
+		t.Fatal("want error when insert duplicated record")
+	}
+	if err := mo.db.Query(mo.tableName, third.Id, result); err == nil {
+		t.Errorf("want third record rolled back")
+	}
+}
+
+func TestMemoryDbOutputRoute(t *testing.T) {
+	mo := &MemoryDbOutput{}
+	mo.SetContext(plugin.Context{"tableName": "test_all", "route.error": "test_error"})
+	mo.Install()
+	defer mo.Uninstall()
+	defer mo.db.DeleteTable("test_all")
+	defer mo.db.DeleteTable("test_error")
+
+	normal, failed := model.NewMonitoryRecord(), model.NewMonitoryRecord()
+	mo.Output(plugin.NewEvent(normal))
+	mo.Output(plugin.NewEvent(failed).AddHeader(plugin.RouteHeader, "error"))
+	result := new(model.MonitorRecord)
+	if err := mo.db.Query("test_all", normal.Id, result); err != nil {
+		t.Errorf("want normal record in test_all, got %v", err)
+	}
+	if err := mo.db.Query("test_error", failed.Id, result); err != nil {
+		t.Errorf("want failed record in test_error, got %v", err)
+	}
+}
+
+func TestMemoryDbOutputRecordType(t *testing.T) {
+	mo := &MemoryDbOutput{}
+	mo.SetContext(plugin.Context{"tableName": "test_metrics", "recordType": "metrics_record"})
+	mo.Install()
+	defer mo.Uninstall()
+	defer mo.db.DeleteTable("test_metrics")
+
+	metrics := model.NewMetricsRecord()
+	metrics.Endpoint = "service1"
+	if err := mo.Output(plugin.NewEvent(metrics)); err != nil {
+		t.Fatal(err)
+	}
+	records, err := mo.db.QueryByField("test_metrics", "endpoint", "service1")
+	if err != nil || len(records) != 1 {
+		t.Errorf("want 1 metrics record got %v, %v", records, err)
+	}
+	if err := mo.Output(plugin.NewEvent(model.NewMonitoryRecord())); err == nil {
+		t.Errorf("want error when output record of other type")
+	}
+}
@@ -23,3 +23,21 @@ func NewPlugin(config config.Output) (Plugin, error) {
 	outputPlugin.MethodByName("SetContext").Call([]reflect.Value{ctx})
 	return outputPlugin.Interface().(Plugin), nil
 }
+
+// Batcher 支持批量输出的输出插件，批量输出应当是原子的，失败时所有事件都未输出
+type Batcher interface {
+	OutputBatch(events []*plugin.Event) error
+}
+
+// OutputBatch 批量输出事件，插件不支持批量输出时逐个输出
+func OutputBatch(output Plugin, events []*plugin.Event) error {
+	if batcher, ok := output.(Batcher); ok && len(events) > 1 {
+		return batcher.OutputBatch(events)
+	}
+	for _, event := range events {
+		if err := output.Output(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -10,4 +10,5 @@ var Type = make(plugin.Types)
 
 func init() {
 	Type["memory_db"] = reflect.TypeOf(MemoryDbOutput{})
+	Type["alert"] = reflect.TypeOf(AlertOutput{})
 }
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"demo/monitor/plugin"
+	"demo/mq"
+	"fmt"
+	"time"
+)
+
+/*
+策略模式
+*/
+
+// 错误策略配置，通过pipeline的context设置：
+// errorPolicy: stop（默认）| retry | skip | dead_letter
+// maxRetries: retry策略的最大重试次数，默认3
+// retryBackoff: retry策略首次重试的等待时间，之后每次翻倍，skip和dead_letter策略在input出错时也会等待该时间，默认100ms
+// deadLetterTopic: dead_letter策略投递的主题，默认为DefaultDeadLetterTopic
+const (
+	StopPolicy       = "stop"
+	RetryPolicy      = "retry"
+	SkipPolicy       = "skip"
+	DeadLetterPolicy = "dead_letter"
+
+	DefaultDeadLetterTopic mq.Topic = "monitor_pipeline.dlq"
+	// ErrorHeader 死信消息中记录出错原因的header
+	ErrorHeader = "error"
+
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+// outcome 错误策略的处理结果
+type outcome uint8
+
+const (
+	recovered    outcome = iota // 重试成功
+	skipped                     // 跳过出错的事件
+	deadLettered                // 出错的事件已投递到死信主题
+)
+
+// errorPolicy pipeline的input或output出错时的处理策略
+type errorPolicy interface {
+	// handle 处理出错的操作，events为出错时处理的事件，批量输出时有多个，input出错时为空，retry用于重试出错的操作
+	// 返回nil时pipeline继续运行，否则pipeline失败
+	handle(ctx context.Context, events []*plugin.Event, err error, retry func() error) (outcome, error)
+}
+
+// newErrorPolicy 根据pipeline的context创建错误策略，配置无法识别时使用stop策略
+func newErrorPolicy(ctx plugin.Context) errorPolicy {
+	backoff := defaultRetryBackoff
+	if val, ok := ctx.GetDuration("retryBackoff"); ok {
+		backoff = val
+	}
+	name, _ := ctx.GetString("errorPolicy")
+	switch name {
+	case RetryPolicy:
+		maxRetries := defaultMaxRetries
+		if val, ok := ctx.GetInt("maxRetries"); ok {
+			maxRetries = val
+		}
+		return &retryPolicy{maxRetries: maxRetries, backoff: backoff}
+	case SkipPolicy:
+		return &skipPolicy{backoff: backoff}
+	case DeadLetterPolicy:
+		topic := DefaultDeadLetterTopic
+		if val, ok := ctx.GetString("deadLetterTopic"); ok {
+			topic = mq.Topic(val)
+		}
+		return &deadLetterPolicy{topic: topic, producer: mq.MemoryMqInstance(), skip: skipPolicy{backoff: backoff}}
+	default:
+		return &stopPolicy{}
+	}
+}
+
+// stopPolicy 出错时pipeline立即失败
+type stopPolicy struct {
+}
+
+func (s *stopPolicy) handle(ctx context.Context, events []*plugin.Event, err error, retry func() error) (outcome, error) {
+	return recovered, err
+}
+
+// retryPolicy 按照指数退避重试出错的操作，重试maxRetries次仍失败时pipeline失败
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+func (r *retryPolicy) handle(ctx context.Context, events []*plugin.Event, err error, retry func() error) (outcome, error) {
+	backoff := r.backoff
+	for i := 0; i < r.maxRetries; i++ {
+		if waitErr := wait(ctx, backoff); waitErr != nil {
+			return recovered, waitErr
+		}
+		if err = retry(); err == nil {
+			return recovered, nil
+		}
+		backoff *= 2
+	}
+	return recovered, fmt.Errorf("retry %d times: %w", r.maxRetries, err)
+}
+
+// skipPolicy 跳过出错的事件，input出错时等待backoff后继续，避免input持续出错时空转
+type skipPolicy struct {
+	backoff time.Duration
+}
+
+func (s *skipPolicy) handle(ctx context.Context, events []*plugin.Event, err error, retry func() error) (outcome, error) {
+	if len(events) == 0 {
+		return skipped, wait(ctx, s.backoff)
+	}
+	return skipped, nil
+}
+
+// deadLetterPolicy 将出错的事件投递到死信主题，投递失败时pipeline失败，input出错时与skipPolicy相同
+type deadLetterPolicy struct {
+	topic    mq.Topic
+	producer mq.Producible
+	skip     skipPolicy
+}
+
+func (d *deadLetterPolicy) handle(ctx context.Context, events []*plugin.Event, err error, retry func() error) (outcome, error) {
+	if len(events) == 0 {
+		return d.skip.handle(ctx, events, err, retry)
+	}
+	messages := make([]*mq.Message, 0, len(events))
+	for _, event := range events {
+		var message *mq.Message
+		if payload, ok := event.Payload().(string); ok {
+			message = mq.NewMessage(d.topic, payload)
+		} else {
+			message = mq.NewObjectMessage(d.topic, event.Payload())
+		}
+		for key, value := range event.Headers() {
+			message.AddHeader(key, value)
+		}
+		messages = append(messages, message.AddHeader(ErrorHeader, err.Error()))
+	}
+	if produceErr := d.producer.ProduceBatch(messages...); produceErr != nil {
+		return deadLettered, fmt.Errorf("send dead letter: %w", produceErr)
+	}
+	return deadLettered, nil
+}
+
+// wait 等待d，ctx结束时提前返回ctx的错误
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
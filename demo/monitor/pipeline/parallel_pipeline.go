@@ -0,0 +1,190 @@
+package pipeline
+
+import (
+	"context"
+	"demo/monitor/input"
+	"demo/monitor/plugin"
+	"sync"
+	"time"
+)
+
+// 并行pipeline配置，通过pipeline的context设置：
+// workers: 并行执行filter的goroutine数，默认4
+// ordered: 是否按照input的顺序输出，默认false，按照filter完成的顺序输出
+// batchSize: 每批输出的事件数，默认1
+// flushInterval: 未满一批的事件最多等待多久输出，默认1s
+const (
+	defaultWorkers       = 4
+	defaultBatchSize     = 1
+	defaultFlushInterval = time.Second
+)
+
+// ParallelPipeline 并行pipeline，input读取的事件分发给多个worker执行filter，再由output批量输出
+// 数据流向为 input -> workers个filter -> [按序] -> 批量output
+// 注意，filter会被多个worker并发调用，需要是并发安全的
+type ParallelPipeline struct {
+	pipelineTemplate
+	workers       int
+	ordered       bool
+	batchSize     int
+	flushInterval time.Duration
+}
+
+// job 一个事件的处理过程，seq为input读取的顺序
+type job struct {
+	seq      uint64
+	event    *plugin.Event
+	filtered []*plugin.Event
+}
+
+func (p *ParallelPipeline) SetContext(ctx plugin.Context) {
+	p.configure(ctx)
+	p.workers = defaultWorkers
+	if val, ok := ctx.GetInt("workers"); ok && val > 0 {
+		p.workers = val
+	}
+	p.ordered, _ = ctx.GetBool("ordered")
+	p.batchSize = defaultBatchSize
+	if val, ok := ctx.GetInt("batchSize"); ok && val > 0 {
+		p.batchSize = val
+	}
+	p.flushInterval = defaultFlushInterval
+	if val, ok := ctx.GetDuration("flushInterval"); ok && val > 0 {
+		p.flushInterval = val
+	}
+	p.run = func() {
+		go p.runParallel(p.ctx)
+	}
+}
+
+func (p *ParallelPipeline) runParallel(ctx context.Context) {
+	jobs := make(chan *job, p.workers)
+	results := make(chan *job, p.workers)
+	go p.dispatch(ctx, jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.filtered = p.filter.Filter(j.event)
+				results <- j
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	if p.ordered {
+		p.batch(reorder(results))
+	} else {
+		p.batch(results)
+	}
+}
+
+// dispatch 从input读取事件分发给worker，pipeline停止后关闭jobs
+func (p *ParallelPipeline) dispatch(ctx context.Context, jobs chan<- *job) {
+	defer close(jobs)
+	var seq uint64
+	for ctx.Err() == nil {
+		event, ok := p.read()
+		if !ok {
+			return
+		}
+		if event == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			p.nack(event)
+			return
+		}
+		select {
+		case jobs <- &job{seq: seq, event: event}:
+			seq++
+		case <-ctx.Done():
+			p.nack(event)
+			return
+		}
+	}
+}
+
+// batch 按照batchSize和flushInterval批量输出，直到results关闭，batchSize按照filter输出的事件数计算
+// 输出失败导致pipeline停止后，剩余的事件不再输出，全部Nack
+func (p *ParallelPipeline) batch(results <-chan *job) {
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+	var pending []*job
+	var events []*plugin.Event
+	isRunning := true
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if isRunning && len(events) > 0 {
+			isRunning = p.write(events)
+		}
+		for _, j := range pending {
+			if isRunning {
+				p.ack(j.event)
+			} else {
+				p.nack(j.event)
+			}
+		}
+		pending, events = nil, nil
+	}
+	for {
+		select {
+		case j, ok := <-results:
+			if !ok {
+				flush()
+				return
+			}
+			if len(j.filtered) == 0 {
+				p.add(&p.metrics.Dropped, 1)
+			}
+			pending = append(pending, j)
+			events = append(events, j.filtered...)
+			if len(events) >= p.batchSize || !isRunning {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (p *ParallelPipeline) ack(event *plugin.Event) {
+	if acker, ok := p.input.(input.Acker); ok {
+		acker.Ack(event)
+	}
+}
+
+func (p *ParallelPipeline) nack(event *plugin.Event) {
+	if acker, ok := p.input.(input.Acker); ok {
+		acker.Nack(event)
+	}
+}
+
+// reorder 按照seq的顺序转发job，乱序到达的job先缓存
+func reorder(in <-chan *job) <-chan *job {
+	out := make(chan *job)
+	go func() {
+		defer close(out)
+		pending := make(map[uint64]*job)
+		var next uint64
+		for j := range in {
+			pending[j.seq] = j
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				out <- ready
+				next++
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"demo/monitor/plugin"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowFilter 按照事件序号倒序sleep，使先读取的事件后完成
+type slowFilter struct {
+}
+
+func (s *slowFilter) Install()                      {}
+func (s *slowFilter) Uninstall()                    {}
+func (s *slowFilter) SetContext(ctx plugin.Context) {}
+
+func (s *slowFilter) Filter(event *plugin.Event) []*plugin.Event {
+	time.Sleep(time.Duration(10-event.Payload().(int)) * time.Millisecond)
+	return []*plugin.Event{event}
+}
+
+// batchOutput 记录每批输出的测试output
+type batchOutput struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (b *batchOutput) Install()                      {}
+func (b *batchOutput) Uninstall()                    {}
+func (b *batchOutput) SetContext(ctx plugin.Context) {}
+
+func (b *batchOutput) Output(event *plugin.Event) error {
+	return b.OutputBatch([]*plugin.Event{event})
+}
+
+func (b *batchOutput) OutputBatch(events []*plugin.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var batch []int
+	for _, event := range events {
+		batch = append(batch, event.Payload().(int))
+	}
+	b.batches = append(b.batches, batch)
+	return nil
+}
+
+func (b *batchOutput) outputs() ([][]int, []int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var all []int
+	for _, batch := range b.batches {
+		all = append(all, batch...)
+	}
+	return b.batches, all
+}
+
+func runParallelPipeline(t *testing.T, ctx plugin.Context, n int, wait time.Duration) *batchOutput {
+	in, out := newChanInput(), &batchOutput{}
+	p := &ParallelPipeline{}
+	p.SetContext(ctx)
+	p.SetInput(in)
+	p.SetFilter(&slowFilter{})
+	p.SetOutput(out)
+	p.Install()
+	defer p.Uninstall()
+	for i := 0; i < n; i++ {
+		in.events <- plugin.NewEvent(i)
+	}
+	time.Sleep(wait)
+	if metrics := p.Metrics(); metrics.Processed != uint64(n) {
+		t.Errorf("want %d processed, got %+v", n, metrics)
+	}
+	return out
+}
+
+func TestParallelPipelineOrdered(t *testing.T) {
+	ctx := plugin.Context{"workers": "4", "ordered": "true", "batchSize": "3", "flushInterval": "20ms"}
+	batches, all := runParallelPipeline(t, ctx, 10, 100*time.Millisecond).outputs()
+	for i, v := range all {
+		if v != i {
+			t.Fatalf("want ordered outputs, got %v", all)
+		}
+	}
+	if len(all) != 10 {
+		t.Fatalf("want 10 outputs, got %v", all)
+	}
+	for _, batch := range batches {
+		if len(batch) > 3 {
+			t.Errorf("want batch size <= 3, got %v", batches)
+		}
+	}
+}
+
+func TestParallelPipelineUnordered(t *testing.T) {
+	// 批大小大于事件数，依靠flushInterval输出
+	ctx := plugin.Context{"workers": "10", "batchSize": "100", "flushInterval": "30ms"}
+	batches, all := runParallelPipeline(t, ctx, 10, 100*time.Millisecond).outputs()
+	if len(batches) == 0 || len(all) != 10 {
+		t.Fatalf("want 10 outputs flushed by interval, got %v", batches)
+	}
+	if sort.IntsAreSorted(all) {
+		t.Errorf("want outputs in completion order, got %v", all)
+	}
+	sort.Ints(all)
+	for i, v := range all {
+		if v != i {
+			t.Fatalf("want all events output, got %v", all)
+		}
+	}
+}
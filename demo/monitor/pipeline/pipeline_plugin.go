@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"demo/monitor/config"
 	"demo/monitor/filter"
 	"demo/monitor/input"
@@ -8,7 +9,7 @@ import (
 	"demo/monitor/plugin"
 	"fmt"
 	"reflect"
-	"sync/atomic"
+	"sync"
 )
 
 /*
@@ -30,6 +31,12 @@ type Plugin interface {
 	SetInput(input input.Plugin)
 	SetFilter(filter filter.Plugin)
 	SetOutput(output output.Plugin)
+	// SetFailureHandler 设置pipeline失败时的回调，在pipeline的goroutine中调用
+	SetFailureHandler(handler func(err error))
+	Status() Status
+	// Err 导致pipeline失败的错误
+	Err() error
+	Metrics() Metrics
 }
 
 /*
@@ -81,14 +88,25 @@ func NewPlugin(config config.Pipeline) (Plugin, error) {
 }
 
 type pipelineTemplate struct {
-	input   input.Plugin
-	filter  filter.Plugin
-	output  output.Plugin
-	isClose uint32
-	run     func()
+	input     input.Plugin
+	filter    filter.Plugin
+	output    output.Plugin
+	policy    errorPolicy
+	ctx       context.Context
+	cancel    context.CancelFunc
+	run       func()
+	mu        sync.Mutex
+	status    Status
+	err       error
+	metrics   Metrics
+	onFailure func(err error)
 }
 
 func (p *pipelineTemplate) Install() {
+	p.mu.Lock()
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.status, p.err = Running, nil
+	p.mu.Unlock()
 	p.output.Install()
 	p.filter.Install()
 	p.input.Install()
@@ -96,10 +114,15 @@ func (p *pipelineTemplate) Install() {
 }
 
 func (p *pipelineTemplate) Uninstall() {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.status = Stopped
+	p.mu.Unlock()
 	p.input.Uninstall()
 	p.filter.Uninstall()
 	p.output.Uninstall()
-	atomic.StoreUint32(&p.isClose, 1)
 }
 
 func (p *pipelineTemplate) SetInput(input input.Plugin) {
@@ -114,19 +137,137 @@ func (p *pipelineTemplate) SetOutput(output output.Plugin) {
 	p.output = output
 }
 
+func (p *pipelineTemplate) SetFailureHandler(handler func(err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onFailure = handler
+}
+
+func (p *pipelineTemplate) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+func (p *pipelineTemplate) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *pipelineTemplate) Metrics() Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.metrics
+}
+
+// configure 解析pipeline公共的context配置，由各pipeline的SetContext调用
+func (p *pipelineTemplate) configure(ctx plugin.Context) {
+	p.policy = newErrorPolicy(ctx)
+}
+
 func (p *pipelineTemplate) doRun() {
-	for atomic.LoadUint32(&p.isClose) != 1 {
-		event, err := p.input.Input()
-		if err != nil {
-			fmt.Printf("pipeline input err %s\n", err.Error())
-			atomic.StoreUint32(&p.isClose, 1)
-			break
+	acker, isAcker := p.input.(input.Acker)
+	for p.ctx.Err() == nil {
+		event, ok := p.read()
+		if !ok {
+			return
+		}
+		if event == nil {
+			continue
+		}
+		events := p.filter.Filter(event)
+		if len(events) == 0 {
+			p.add(&p.metrics.Dropped, 1)
+		} else if !p.write(events) {
+			if isAcker {
+				acker.Nack(event)
+			}
+			return
+		}
+		if isAcker {
+			acker.Ack(event)
+		}
+	}
+}
+
+// read 从input读取事件，出错时按照错误策略处理，跳过时返回nil，返回false表示pipeline需要停止运行
+func (p *pipelineTemplate) read() (*plugin.Event, bool) {
+	event, err := p.input.Input()
+	if err == plugin.ErrPluginUninstalled {
+		return nil, false
+	}
+	if err != nil {
+		retry := func() error {
+			var retryErr error
+			event, retryErr = p.input.Input()
+			return retryErr
+		}
+		if _, ok := p.handleError(nil, err, retry); !ok {
+			return nil, false
+		}
+	}
+	return event, true
+}
+
+// write 输出事件，出错时按照错误策略处理，返回false表示pipeline需要停止运行
+func (p *pipelineTemplate) write(events []*plugin.Event) bool {
+	err := output.OutputBatch(p.output, events)
+	if err != nil {
+		retry := func() error {
+			return output.OutputBatch(p.output, events)
+		}
+		result, ok := p.handleError(events, err, retry)
+		if !ok {
+			return false
 		}
-		event = p.filter.Filter(event)
-		if err = p.output.Output(event); err != nil {
-			fmt.Printf("pipeline output err %s\n", err.Error())
-			atomic.StoreUint32(&p.isClose, 1)
-			break
+		if result != recovered {
+			return true
 		}
 	}
+	p.add(&p.metrics.Processed, len(events))
+	return true
+}
+
+// handleError 按照错误策略处理错误，返回false表示pipeline需要停止运行
+func (p *pipelineTemplate) handleError(events []*plugin.Event, err error, retry func() error) (outcome, bool) {
+	p.add(&p.metrics.Errors, 1)
+	result, err := p.policy.handle(p.ctx, events, err, retry)
+	if p.ctx.Err() != nil {
+		return result, false
+	}
+	if err != nil {
+		p.fail(err)
+		return result, false
+	}
+	switch result {
+	case skipped:
+		p.add(&p.metrics.Skipped, len(events))
+	case deadLettered:
+		p.add(&p.metrics.DeadLetters, len(events))
+	}
+	return result, true
+}
+
+// fail pipeline失败，停止运行并通知监控系统，多个goroutine同时失败时只通知一次
+func (p *pipelineTemplate) fail(err error) {
+	p.mu.Lock()
+	if p.status != Running {
+		p.mu.Unlock()
+		return
+	}
+	fmt.Printf("pipeline failed: %s\n", err.Error())
+	p.status, p.err = Failed, err
+	p.cancel()
+	onFailure := p.onFailure
+	p.mu.Unlock()
+	if onFailure != nil {
+		onFailure(err)
+	}
+}
+
+func (p *pipelineTemplate) add(counter *uint64, n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*counter += uint64(n)
 }
@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"demo/monitor/filter"
+	"demo/monitor/plugin"
+	"demo/mq"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errOutput = errors.New("output failed")
+
+// chanInput 从channel读取事件的测试input
+type chanInput struct {
+	events chan *plugin.Event
+	done   chan struct{}
+}
+
+func newChanInput() *chanInput {
+	return &chanInput{events: make(chan *plugin.Event, 10), done: make(chan struct{})}
+}
+
+func (c *chanInput) Install()                      {}
+func (c *chanInput) Uninstall()                    { close(c.done) }
+func (c *chanInput) SetContext(ctx plugin.Context) {}
+
+func (c *chanInput) Input() (*plugin.Event, error) {
+	select {
+	case event := <-c.events:
+		return event, nil
+	case <-c.done:
+		return nil, plugin.ErrPluginUninstalled
+	}
+}
+
+// flakyOutput 前failures次输出失败的测试output，failures为-1时一直失败
+type flakyOutput struct {
+	mu       sync.Mutex
+	failures int
+	outputs  []*plugin.Event
+}
+
+func (f *flakyOutput) Install()                      {}
+func (f *flakyOutput) Uninstall()                    {}
+func (f *flakyOutput) SetContext(ctx plugin.Context) {}
+
+func (f *flakyOutput) Output(event *plugin.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures != 0 {
+		f.failures--
+		return errOutput
+	}
+	f.outputs = append(f.outputs, event)
+	return nil
+}
+
+func (f *flakyOutput) events() []*plugin.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*plugin.Event(nil), f.outputs...)
+}
+
+func newTestPipeline(ctx plugin.Context, in *chanInput, out *flakyOutput) *SimplePipeline {
+	p := &SimplePipeline{}
+	p.SetContext(ctx)
+	p.SetInput(in)
+	p.SetFilter(filter.NewChain(nil))
+	p.SetOutput(out)
+	return p
+}
+
+func TestPipelineRetryPolicy(t *testing.T) {
+	ctx := plugin.Context{"errorPolicy": "retry", "maxRetries": "3", "retryBackoff": "1ms"}
+	in, out := newChanInput(), &flakyOutput{failures: 2}
+	p := newTestPipeline(ctx, in, out)
+	p.Install()
+	defer p.Uninstall()
+	in.events <- plugin.NewEvent("hello")
+	time.Sleep(50 * time.Millisecond)
+	if p.Status() != Running || len(out.events()) != 1 {
+		t.Fatalf("want running with 1 output, got %s with %d", p.Status(), len(out.events()))
+	}
+	if metrics := p.Metrics(); metrics.Processed != 1 || metrics.Errors != 1 {
+		t.Errorf("metrics got %+v", metrics)
+	}
+
+	// 重试次数耗尽后失败
+	out.mu.Lock()
+	out.failures = -1
+	out.mu.Unlock()
+	in.events <- plugin.NewEvent("hello")
+	time.Sleep(50 * time.Millisecond)
+	if p.Status() != Failed || !errors.Is(p.Err(), errOutput) {
+		t.Errorf("want failed with errOutput, got %s with %v", p.Status(), p.Err())
+	}
+}
+
+func TestPipelineSkipPolicy(t *testing.T) {
+	in, out := newChanInput(), &flakyOutput{failures: 1}
+	p := newTestPipeline(plugin.Context{"errorPolicy": "skip"}, in, out)
+	p.Install()
+	defer p.Uninstall()
+	in.events <- plugin.NewEvent("skipped")
+	in.events <- plugin.NewEvent("hello")
+	time.Sleep(50 * time.Millisecond)
+	if outputs := out.events(); p.Status() != Running || len(outputs) != 1 || outputs[0].Payload() != "hello" {
+		t.Fatalf("want running with output hello, got %s with %v", p.Status(), outputs)
+	}
+	if metrics := p.Metrics(); metrics.Processed != 1 || metrics.Skipped != 1 {
+		t.Errorf("metrics got %+v", metrics)
+	}
+}
+
+func TestPipelineDeadLetterPolicy(t *testing.T) {
+	defer mq.MemoryMqInstance().Clear()
+	ctx := plugin.Context{"errorPolicy": "dead_letter", "deadLetterTopic": "pipeline_test.dlq"}
+	in, out := newChanInput(), &flakyOutput{failures: 1}
+	p := newTestPipeline(ctx, in, out)
+	p.Install()
+	defer p.Uninstall()
+	in.events <- plugin.NewEvent("dead").AddHeader("type", "recv_req")
+	msg, err := mq.MemoryMqInstance().ConsumeN("pipeline_test.dlq", 1, time.Second)
+	if err != nil || len(msg) != 1 {
+		t.Fatalf("want 1 dead letter, got %v, %v", msg, err)
+	}
+	reason, _ := msg[0].Header(ErrorHeader)
+	typ, _ := msg[0].Header("type")
+	if msg[0].Payload() != "dead" || reason != errOutput.Error() || typ != "recv_req" {
+		t.Errorf("dead letter got %s, error %s, type %s", msg[0].Payload(), reason, typ)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if metrics := p.Metrics(); p.Status() != Running || metrics.DeadLetters != 1 {
+		t.Errorf("want running with 1 dead letter, got %s with %+v", p.Status(), metrics)
+	}
+}
+
+func TestPipelineStopPolicy(t *testing.T) {
+	in, out := newChanInput(), &flakyOutput{failures: 1}
+	p := newTestPipeline(plugin.EmptyContext(), in, out)
+	failed := make(chan error, 1)
+	p.SetFailureHandler(func(err error) {
+		failed <- err
+	})
+	p.Install()
+	in.events <- plugin.NewEvent("hello")
+	select {
+	case err := <-failed:
+		if err != errOutput || p.Status() != Failed {
+			t.Errorf("want failed with errOutput, got %s with %v", p.Status(), err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("failure handler not called")
+	}
+	p.Uninstall()
+	if p.Status() != Stopped {
+		t.Errorf("want stopped after uninstall, got %s", p.Status())
+	}
+}
+
+// splitFilter 丢弃payload为空的事件，将其他事件按字符拆分
+type splitFilter struct {
+}
+
+func (s *splitFilter) Install()                      {}
+func (s *splitFilter) Uninstall()                    {}
+func (s *splitFilter) SetContext(ctx plugin.Context) {}
+
+func (s *splitFilter) Filter(event *plugin.Event) []*plugin.Event {
+	var events []*plugin.Event
+	for _, c := range event.Payload().(string) {
+		events = append(events, event.Derive(string(c)))
+	}
+	return events
+}
+
+func TestPipelineDropAndSplit(t *testing.T) {
+	in, out := newChanInput(), &flakyOutput{}
+	p := newTestPipeline(plugin.EmptyContext(), in, out)
+	p.SetFilter(filter.NewChain([]filter.Plugin{&splitFilter{}}))
+	p.Install()
+	defer p.Uninstall()
+	in.events <- plugin.NewEvent("")
+	in.events <- plugin.NewEvent("ab")
+	time.Sleep(50 * time.Millisecond)
+	if outputs := out.events(); len(outputs) != 2 || outputs[0].Payload() != "a" || outputs[1].Payload() != "b" {
+		t.Fatalf("want outputs [a b], got %v", outputs)
+	}
+	if metrics := p.Metrics(); metrics.Processed != 2 || metrics.Dropped != 1 {
+		t.Errorf("metrics got %+v", metrics)
+	}
+}
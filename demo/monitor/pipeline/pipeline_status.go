@@ -0,0 +1,32 @@
+package pipeline
+
+// Status pipeline运行状态
+type Status uint8
+
+const (
+	Stopped Status = iota // 未安装或已卸载
+	Running               // 运行中
+	Failed                // 出错停止，可由监控系统重启
+)
+
+func (s Status) String() string {
+	switch s {
+	case Stopped:
+		return "stopped"
+	case Running:
+		return "running"
+	case Failed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Metrics pipeline处理事件的统计数据
+type Metrics struct {
+	Processed   uint64 // 成功输出的事件数，filter拆分出的事件分别计数
+	Dropped     uint64 // 被filter丢弃的事件数
+	Errors      uint64 // input或output出错的次数，包括重试失败的次数
+	Skipped     uint64 // 按照错误策略跳过的事件数
+	DeadLetters uint64 // 投递到死信主题的事件数
+}
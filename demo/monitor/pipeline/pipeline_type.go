@@ -11,4 +11,5 @@ var Type = make(plugin.Types)
 func init() {
 	Type["simple"] = reflect.TypeOf(SimplePipeline{})
 	Type["pool"] = reflect.TypeOf(PoolPipeline{})
+	Type["parallel"] = reflect.TypeOf(ParallelPipeline{})
 }
@@ -14,6 +14,7 @@ type PoolPipeline struct {
 }
 
 func (p *PoolPipeline) SetContext(ctx plugin.Context) {
+	p.configure(ctx)
 	p.run = func() {
 		if err := pool.Submit(p.doRun); err != nil {
 			fmt.Printf("PoolPipeine run error %s", err.Error())
@@ -8,6 +8,7 @@ type SimplePipeline struct {
 }
 
 func (s *SimplePipeline) SetContext(ctx plugin.Context) {
+	s.configure(ctx)
 	s.run = func() {
 		go func() {
 			s.doRun()
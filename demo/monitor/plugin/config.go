@@ -3,6 +3,7 @@ package plugin
 import (
 	"reflect"
 	"strconv"
+	"time"
 )
 
 // Config 插件配置抽象接口
@@ -38,3 +39,26 @@ func (c Context) GetInt(key string) (int, bool) {
 	}
 	return 0, false
 }
+
+// GetDuration 获取时间配置，格式与time.ParseDuration一致，如100ms、1s
+func (c Context) GetDuration(key string) (time.Duration, bool) {
+	val, ok := c[key]
+	if !ok {
+		return 0, false
+	}
+	if dVal, err := time.ParseDuration(val); err == nil {
+		return dVal, true
+	}
+	return 0, false
+}
+
+func (c Context) GetBool(key string) (bool, bool) {
+	val, ok := c[key]
+	if !ok {
+		return false, false
+	}
+	if bVal, err := strconv.ParseBool(val); err == nil {
+		return bVal, true
+	}
+	return false, false
+}
@@ -10,6 +10,9 @@ type Plugin interface {
 	SetContext(ctx Context)
 }
 
+// RouteHeader 事件的路由标签，由filter设置，后续的filter和output可以按照路由分别处理
+const RouteHeader = "route"
+
 // Event 插件间通信事件
 type Event struct {
 	headers map[string]string
@@ -32,6 +35,20 @@ func (e *Event) Payload() interface{} {
 	return e.payload
 }
 
+// Derive 以payload创建新事件，并继承当前事件的所有header
+func (e *Event) Derive(payload interface{}) *Event {
+	return &Event{headers: e.Headers(), payload: payload}
+}
+
+// Headers 返回所有header的副本
+func (e *Event) Headers() map[string]string {
+	headers := make(map[string]string, len(e.headers))
+	for key, value := range e.headers {
+		headers[key] = value
+	}
+	return headers
+}
+
 func (e *Event) Header(key string) (string, bool) {
 	val, ok := e.headers[key]
 	return val, ok
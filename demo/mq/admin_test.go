@@ -0,0 +1,223 @@
+package mq
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryMqAdmin(t *testing.T) {
+	mmq := newMemoryMq(DefaultTopicConfig())
+	defer mmq.Clear()
+	conf := DefaultTopicConfig()
+	conf.Capacity = 2
+	conf.ProduceTimeout = 0
+	if err := mmq.CreateTopic("order.timeout", conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := mmq.CreateTopic("order.timeout", conf); err != ErrTopicExists {
+		t.Errorf("create twice want ErrTopicExists, got %v", err)
+	}
+	if err := mmq.CreateTopic("..", conf); err != ErrInvalidTopic {
+		t.Errorf("create .. want ErrInvalidTopic, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := mmq.Produce(NewMessage("order.timeout", "order")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mmq.Produce(NewMessage("order.timeout", "order")); err != ErrTopicFull {
+		t.Errorf("produce over capacity want ErrTopicFull, got %v", err)
+	}
+	if _, err := mmq.Consume("order.timeout"); err != nil {
+		t.Fatal(err)
+	}
+	delivery, err := mmq.ConsumeGroup("g1", "order.timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := mmq.Stats("order.timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Config.Capacity != 2 || stats.EndOffset != 2 || stats.Lag != 1 || stats.Depth != 2 {
+		t.Errorf("stats got %+v", stats)
+	}
+	if stats.ProduceRate != 2.0/rateWindow || stats.ConsumeRate != 1.0/rateWindow {
+		t.Errorf("rate got produce %v consume %v", stats.ProduceRate, stats.ConsumeRate)
+	}
+	if len(stats.Groups) != 1 || stats.Groups[0].Lag != 2 || stats.Groups[0].Inflight != 1 {
+		t.Errorf("group stats got %+v", stats.Groups)
+	}
+	delivery.Ack()
+	stats, _ = mmq.Stats("order.timeout")
+	if stats.Groups[0].Lag != 1 || stats.Groups[0].Inflight != 0 || stats.Depth != 1 {
+		t.Errorf("group stats after ack got %+v, depth %d", stats.Groups[0], stats.Depth)
+	}
+
+	// 删除主题后阻塞的消费者返回ErrMqClosed，再次使用时按默认配置重新创建
+	done := make(chan error)
+	go func() {
+		_, err := mmq.ConsumeGroup("g1", "order.timeout")
+		_, err = mmq.ConsumeGroup("g1", "order.timeout")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := mmq.DeleteTopic("order.timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != ErrMqClosed {
+		t.Errorf("consume on deleted topic want ErrMqClosed, got %v", err)
+	}
+	if err := mmq.DeleteTopic("order.timeout"); err != ErrTopicNotFound {
+		t.Errorf("delete twice want ErrTopicNotFound, got %v", err)
+	}
+	if _, err := mmq.Stats("order.timeout"); err != ErrTopicNotFound {
+		t.Errorf("stats of deleted topic want ErrTopicNotFound, got %v", err)
+	}
+	mmq.Produce(NewMessage("order.timeout", "order"))
+	topics, _ := mmq.Topics()
+	if len(topics) != 1 || topics[0].Config.Capacity != DefaultTopicConfig().Capacity || topics[0].EndOffset != 1 {
+		t.Errorf("topics got %+v", topics)
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	mmq := newMemoryMq(DefaultTopicConfig())
+	defer mmq.Clear()
+	conf := DefaultTopicConfig()
+	conf.Capacity = 2
+	conf.ProduceTimeout = 0
+	if err := mmq.CreateTopic("order.timeout", conf); err != nil {
+		t.Fatal(err)
+	}
+	// 消费组加入时没有消息，之后不再消费
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mmq.ConsumeGroupContext(ctx, "idle", "order.timeout"); err != context.Canceled {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	mmq.Produce(NewMessage("order.timeout", "1"))
+	mmq.Produce(NewMessage("order.timeout", "2"))
+	mmq.ConsumeN("order.timeout", 2, 0)
+	// 不再消费的消费组阻止消息释放，删除后可以继续生产
+	if err := mmq.Produce(NewMessage("order.timeout", "3")); err != ErrTopicFull {
+		t.Errorf("want ErrTopicFull, got %v", err)
+	}
+	if err := mmq.DeleteGroup("order.timeout", "idle"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mmq.Produce(NewMessage("order.timeout", "3")); err != nil {
+		t.Errorf("want produce after delete group, got %v", err)
+	}
+	if stats, _ := mmq.Stats("order.timeout"); len(stats.Groups) != 0 {
+		t.Errorf("want no group, got %+v", stats.Groups)
+	}
+	if err := mmq.DeleteGroup("order.timeout", "idle"); err != ErrGroupNotFound {
+		t.Errorf("delete twice want ErrGroupNotFound, got %v", err)
+	}
+	if err := mmq.DeleteGroup("unknown", "idle"); err != ErrTopicNotFound {
+		t.Errorf("delete group of unknown topic want ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestFileMqAdmin(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := DefaultTopicConfig()
+	conf.Capacity = 5
+	conf.RetentionTime = time.Hour
+	if err := fileMq.CreateTopic("order.timeout", conf); err != nil {
+		t.Fatal(err)
+	}
+	fileMq.Produce(NewMessage("order.timeout", "order"))
+	fileMq.Produce(NewMessage("access_log.topic", "log"))
+	fileMq.Close()
+
+	// 重启后保留创建时的配置
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	topics, err := fileMq.Topics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) != 2 || topics[0].Name != "access_log.topic" || topics[1].Name != "order.timeout" {
+		t.Fatalf("topics got %+v", topics)
+	}
+	if topics[1].Config != conf || topics[1].Depth != 1 {
+		t.Errorf("order.timeout stats got %+v", topics[1])
+	}
+	if err := fileMq.CreateTopic("order.timeout", conf); err != ErrTopicExists {
+		t.Errorf("create twice want ErrTopicExists, got %v", err)
+	}
+
+	// 删除后消息和配置都不存在
+	if err := fileMq.DeleteTopic("order.timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileMq.TryConsume("order.timeout"); err != ErrNoMessage {
+		t.Errorf("consume deleted topic want ErrNoMessage, got %v", err)
+	}
+	stats, _ := fileMq.Stats("order.timeout")
+	if stats.Config.Capacity != DefaultTopicConfig().Capacity {
+		t.Errorf("recreated topic want default config, got %+v", stats.Config)
+	}
+
+	// 不合法的主题名不会删除存储目录之外的文件
+	console := NewConsole(fileMq)
+	for _, name := range []string{"..", "."} {
+		if out := console.Exec("delete " + name); out != ErrInvalidTopic.Error() {
+			t.Errorf("delete %s got %s", name, out)
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("mq dir removed: %v", err)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	mmq := newMemoryMq(DefaultTopicConfig())
+	defer mmq.Clear()
+	console := NewConsole(mmq)
+	if out := console.Exec("create order.timeout capacity=100 retention-time=1h"); out != "topic order.timeout created" {
+		t.Fatalf("create got %s", out)
+	}
+	if out := console.Exec("create order.timeout"); out != ErrTopicExists.Error() {
+		t.Errorf("create twice got %s", out)
+	}
+	if out := console.Exec("create bad capacity=x"); !strings.Contains(out, "invalid option") {
+		t.Errorf("create with bad option got %s", out)
+	}
+	mmq.Produce(NewMessage("order.timeout", "order"))
+	mmq.ConsumeGroup("g1", "order.timeout")
+	if out := console.Exec("topics"); !strings.Contains(out, "order.timeout") {
+		t.Errorf("topics got %s", out)
+	}
+	if out := console.Exec("stats order.timeout"); !strings.Contains(out, "g1") || !strings.Contains(out, "100") {
+		t.Errorf("stats got %s", out)
+	}
+	if out := console.Exec("delete-group order.timeout g1"); out != "group g1 of topic order.timeout deleted" {
+		t.Errorf("delete-group got %s", out)
+	}
+	if out := console.Exec("delete-group order.timeout g1"); out != ErrGroupNotFound.Error() {
+		t.Errorf("delete-group twice got %s", out)
+	}
+	if out := console.Exec("delete order.timeout"); out != "topic order.timeout deleted" {
+		t.Errorf("delete got %s", out)
+	}
+	if out := console.Exec("stats order.timeout"); out != ErrTopicNotFound.Error() {
+		t.Errorf("stats of deleted topic got %s", out)
+	}
+	if out := console.Exec("unknown"); !strings.Contains(out, "unknown command") {
+		t.Errorf("unknown command got %s", out)
+	}
+}
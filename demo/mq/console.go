@@ -0,0 +1,204 @@
+package mq
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+命令模式，每条控制台命令封装为consoleCommand，Console只负责解析和分发
+*/
+
+// consoleCommand 控制台命令，args不包括命令名
+type consoleCommand struct {
+	usage string
+	exec  func(args []string) (string, error)
+}
+
+// Console 消息队列管理控制台，查看主题的积压、速率和消费延迟，创建、删除主题，删除不再使用的消费组
+type Console struct {
+	admin    Admin
+	commands map[string]*consoleCommand
+}
+
+func NewConsole(admin Admin) *Console {
+	c := &Console{admin: admin}
+	c.commands = map[string]*consoleCommand{
+		"topics":       {usage: "topics", exec: c.topics},
+		"stats":        {usage: "stats <topic>", exec: c.stats},
+		"create":       {usage: "create <topic> [capacity=N] [partitions=N] [retention-time=1h] [retention-bytes=N] [visibility-timeout=30s] [max-deliveries=N]", exec: c.create},
+		"delete":       {usage: "delete <topic>", exec: c.delete},
+		"delete-group": {usage: "delete-group <topic> <group>", exec: c.deleteGroup},
+		"help":         {usage: "help", exec: c.help},
+	}
+	return c
+}
+
+func (c *Console) Start() {
+	fmt.Println("welcome to Demo MQ, enter help to list commands, exit to end!")
+	fmt.Print("> ")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "exit" {
+			break
+		}
+		fmt.Println(c.Exec(line))
+		fmt.Print("> ")
+	}
+}
+
+// Exec 执行一行命令，返回输出内容，出错时返回错误信息
+func (c *Console) Exec(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	command, ok := c.commands[fields[0]]
+	if !ok {
+		return fmt.Sprintf("unknown command %s, enter help to list commands", fields[0])
+	}
+	output, err := command.exec(fields[1:])
+	if err != nil {
+		return err.Error()
+	}
+	return output
+}
+
+func (c *Console) topics(args []string) (string, error) {
+	stats, err := c.admin.Topics()
+	if err != nil {
+		return "", err
+	}
+	var rows [][]string
+	for _, s := range stats {
+		rows = append(rows, []string{string(s.Name), fmt.Sprint(s.Depth), fmt.Sprint(s.StartOffset),
+			fmt.Sprint(s.EndOffset), formatRate(s.ProduceRate), formatRate(s.ConsumeRate), formatLag(s.Lag),
+			fmt.Sprint(len(s.Groups))})
+	}
+	return renderTable([]string{"topic", "depth", "start", "end", "produce/s", "consume/s", "lag", "groups"}, rows), nil
+}
+
+func (c *Console) stats(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: %s", c.commands["stats"].usage)
+	}
+	s, err := c.admin.Stats(Topic(args[0]))
+	if err != nil {
+		return "", err
+	}
+	summary := renderTable([]string{"topic", "depth", "start", "end", "produce/s", "consume/s", "lag", "capacity", "partitions"},
+		[][]string{{string(s.Name), fmt.Sprint(s.Depth), fmt.Sprint(s.StartOffset), fmt.Sprint(s.EndOffset),
+			formatRate(s.ProduceRate), formatRate(s.ConsumeRate), formatLag(s.Lag),
+			fmt.Sprint(s.Config.Capacity), fmt.Sprint(s.Config.Partitions)}})
+	if len(s.Groups) == 0 {
+		return summary, nil
+	}
+	var rows [][]string
+	for _, g := range s.Groups {
+		rows = append(rows, []string{g.Name, fmt.Sprint(g.Committed), fmt.Sprint(g.Lag), fmt.Sprint(g.Inflight)})
+	}
+	return summary + renderTable([]string{"group", "committed", "lag", "inflight"}, rows), nil
+}
+
+func (c *Console) create(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: %s", c.commands["create"].usage)
+	}
+	conf, err := parseTopicConfig(args[1:])
+	if err != nil {
+		return "", err
+	}
+	if err := c.admin.CreateTopic(Topic(args[0]), conf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("topic %s created", args[0]), nil
+}
+
+func (c *Console) delete(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: %s", c.commands["delete"].usage)
+	}
+	if err := c.admin.DeleteTopic(Topic(args[0])); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("topic %s deleted", args[0]), nil
+}
+
+func (c *Console) deleteGroup(args []string) (string, error) {
+	if len(args) != 2 {
+		return "", fmt.Errorf("usage: %s", c.commands["delete-group"].usage)
+	}
+	if err := c.admin.DeleteGroup(Topic(args[0]), args[1]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("group %s of topic %s deleted", args[1], args[0]), nil
+}
+
+func (c *Console) help(args []string) (string, error) {
+	var usages []string
+	for _, command := range c.commands {
+		usages = append(usages, command.usage)
+	}
+	sort.Strings(usages)
+	return strings.Join(append(usages, "exit"), "\n"), nil
+}
+
+// parseTopicConfig 解析key=value形式的主题配置，未配置的项使用DefaultTopicConfig
+func parseTopicConfig(options []string) (TopicConfig, error) {
+	conf := DefaultTopicConfig()
+	for _, option := range options {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return conf, fmt.Errorf("invalid option %s, want key=value", option)
+		}
+		var err error
+		switch kv[0] {
+		case "capacity":
+			conf.Capacity, err = strconv.Atoi(kv[1])
+		case "partitions":
+			conf.Partitions, err = strconv.Atoi(kv[1])
+		case "max-deliveries":
+			conf.MaxDeliveries, err = strconv.Atoi(kv[1])
+		case "retention-bytes":
+			conf.RetentionBytes, err = strconv.ParseInt(kv[1], 10, 64)
+		case "retention-time":
+			conf.RetentionTime, err = time.ParseDuration(kv[1])
+		case "visibility-timeout":
+			conf.VisibilityTimeout, err = time.ParseDuration(kv[1])
+		default:
+			return conf, fmt.Errorf("unknown option %s", kv[0])
+		}
+		if err != nil {
+			return conf, fmt.Errorf("invalid option %s: %v", option, err)
+		}
+	}
+	return conf, nil
+}
+
+func renderTable(header []string, rows [][]string) string {
+	builder := &strings.Builder{}
+	table := tablewriter.NewWriter(builder)
+	table.SetHeader(header)
+	table.AppendBulk(rows)
+	table.Render()
+	return builder.String()
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', 1, 64)
+}
+
+// formatLag 默认消费者没有消费过时显示为-
+func formatLag(lag int64) string {
+	if lag < 0 {
+		return "-"
+	}
+	return fmt.Sprint(lag)
+}
@@ -0,0 +1,98 @@
+package mq
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConsumerGroupFanOut(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	for i := 0; i < 3; i++ {
+		m.Produce(NewMessage("test", strconv.Itoa(i)))
+	}
+	// 不同消费组各自消费全量消息
+	for i := 0; i < 3; i++ {
+		for _, group := range []string{"group0", "group1"} {
+			delivery, err := m.ConsumeGroup(group, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delivery.Message().Payload() != strconv.Itoa(i) {
+				t.Errorf("group %s want %d, got %s", group, i, delivery.Message().Payload())
+			}
+			delivery.Ack()
+		}
+	}
+}
+
+func TestConsumerGroupNackAndDeadLetter(t *testing.T) {
+	conf := DefaultTopicConfig()
+	conf.MaxDeliveries = 2
+	m := newMemoryMq(conf)
+	m.Produce(NewMessage("test", "hello"))
+	m.Produce(NewMessage("test", "world"))
+
+	delivery, _ := m.ConsumeGroup("group", "test")
+	delivery.Nack()
+	// Nack后立即重新投递
+	delivery, _ = m.ConsumeGroup("group", "test")
+	if delivery.Message().Payload() != "hello" || delivery.Attempts() != 2 {
+		t.Errorf("want hello with 2 attempts, got %s with %d", delivery.Message().Payload(), delivery.Attempts())
+	}
+	// 超过最大投递次数后进入死信主题
+	delivery.Nack()
+	delivery, _ = m.ConsumeGroup("group", "test")
+	if delivery.Message().Payload() != "world" {
+		t.Errorf("want world, got %s", delivery.Message().Payload())
+	}
+	delivery.Ack()
+	dead, err := m.Consume("test" + DeadLetterSuffix)
+	if err != nil || dead.Payload() != "hello" {
+		t.Errorf("want dead letter hello, got %v, %v", dead, err)
+	}
+}
+
+func TestConsumerGroupVisibilityTimeout(t *testing.T) {
+	conf := DefaultTopicConfig()
+	conf.VisibilityTimeout = 50 * time.Millisecond
+	m := newMemoryMq(conf)
+	m.Produce(NewMessage("test", "hello"))
+	first, _ := m.ConsumeGroup("group", "test")
+	// 未Ack的消息超时后重新投递给组内其他消费者
+	start := time.Now()
+	second, _ := m.ConsumeGroup("group", "test")
+	if second.Offset() != first.Offset() || second.Attempts() != 2 {
+		t.Errorf("want redelivery of offset %d, got %d with %d attempts", first.Offset(), second.Offset(), second.Attempts())
+	}
+	if time.Since(start) < conf.VisibilityTimeout/2 {
+		t.Error("redelivered before visibility timeout")
+	}
+	second.Ack()
+	first.Ack()
+}
+
+func TestConsumerGroupRedeliverAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMq.Produce(NewMessage("test", "hello"))
+	fileMq.Produce(NewMessage("test", "world"))
+	delivery, _ := fileMq.ConsumeGroup("group", "test")
+	delivery.Ack()
+	// 模拟崩溃，处理中的消息未Ack
+	fileMq.ConsumeGroup("group", "test")
+	fileMq.Close()
+
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	delivery, err = fileMq.ConsumeGroup("group", "test")
+	if err != nil || delivery.Message().Payload() != "world" {
+		t.Errorf("want world redelivered, got %v, %v", delivery, err)
+	}
+}
@@ -0,0 +1,51 @@
+package mq
+
+// Delivery 消费组投递的消息，处理完成后需要调用Ack，处理失败时调用Nack
+// 超过VisibilityTimeout未Ack的消息会被重新投递，因此消息可能被处理多次（at-least-once）
+type Delivery struct {
+	message  *Message
+	offset   int64
+	attempts int
+	group    string
+	ack      func() error
+	nack     func() error
+}
+
+// NewDelivery 创建投递，ack和nack为确认方法，用于远程消息队列客户端等Mq实现
+func NewDelivery(message *Message, group string, offset int64, attempts int, ack, nack func() error) *Delivery {
+	return &Delivery{
+		message:  message,
+		offset:   offset,
+		attempts: attempts,
+		group:    group,
+		ack:      ack,
+		nack:     nack,
+	}
+}
+
+func (d *Delivery) Message() *Message {
+	return d.message
+}
+
+func (d *Delivery) Offset() int64 {
+	return d.offset
+}
+
+// Attempts 消息的投递次数，从1开始
+func (d *Delivery) Attempts() int {
+	return d.attempts
+}
+
+func (d *Delivery) Group() string {
+	return d.group
+}
+
+// Ack 确认消息已处理完成，重复Ack无副作用
+func (d *Delivery) Ack() error {
+	return d.ack()
+}
+
+// Nack 消息处理失败，立即重新投递给消费组，投递次数达到MaxDeliveries时投递到死信主题
+func (d *Delivery) Nack() error {
+	return d.nack()
+}
@@ -0,0 +1,14 @@
+package mq
+
+import "errors"
+
+var (
+	ErrTopicFull = errors.New("topic is full")
+	ErrMqClosed  = errors.New("mq is closed")
+	ErrNoMessage = errors.New("no message in topic")
+
+	ErrTopicExists   = errors.New("topic already exists")
+	ErrTopicNotFound = errors.New("topic not found")
+	ErrInvalidTopic  = errors.New("invalid topic name")
+	ErrGroupNotFound = errors.New("consumer group not found")
+)
@@ -0,0 +1,366 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMq 持久化消息队列，每个topic对应dir下的一个目录，消息以segment文件的形式保存在本地磁盘
+// 生产的消息在返回前落盘，重启后未消费的消息和消费位置都会被恢复
+type FileMq struct {
+	dir      string
+	conf     TopicConfig
+	mu       sync.Mutex
+	topics   map[Topic]*topic
+	subs     *subscriptions
+	sched    *scheduler
+	isClosed bool
+}
+
+const (
+	// 定时消息日志文件名
+	scheduleJournalFile = "scheduled.journal"
+	// 主题配置文件名，通过CreateTopic创建的主题重启后仍使用创建时的配置
+	topicConfigFile = "config.json"
+)
+
+// NewFileMq 打开dir下的持久化消息队列，加载已存在的topic和未到期的定时消息，conf作用于所有topic
+func NewFileMq(dir string, conf TopicConfig) (*FileMq, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &FileMq{dir: dir, conf: conf, topics: make(map[Topic]*topic), subs: newSubscriptions()}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name, err := url.PathUnescape(entry.Name())
+		if err != nil || Topic(name).validate() != nil {
+			continue
+		}
+		if _, err := f.topicOf(Topic(name)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	journal, pending, err := openScheduleJournal(filepath.Join(dir, scheduleJournalFile))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.sched = newScheduler(f.Produce, journal, pending)
+	return f, nil
+}
+
+func (f *FileMq) Consume(topic Topic) (*Message, error) {
+	return f.ConsumeContext(context.Background(), topic)
+}
+
+func (f *FileMq) ConsumeContext(ctx context.Context, topic Topic) (*Message, error) {
+	t, err := f.topicOf(topic)
+	if err != nil {
+		return nil, err
+	}
+	return t.consume(ctx)
+}
+
+func (f *FileMq) TryConsume(topic Topic) (*Message, error) {
+	t, err := f.topicOf(topic)
+	if err != nil {
+		return nil, err
+	}
+	return t.tryConsume()
+}
+
+func (f *FileMq) ConsumeN(topic Topic, n int, wait time.Duration) ([]*Message, error) {
+	t, err := f.topicOf(topic)
+	if err != nil {
+		return nil, err
+	}
+	return t.consumeWithin(n, wait)
+}
+
+// ConsumeGroup 以消费组的方式消费消息，处理完成后需要调用Delivery.Ack，未Ack的消息重启后会被重新投递
+func (f *FileMq) ConsumeGroup(group string, topic Topic) (*Delivery, error) {
+	return f.ConsumeGroupContext(context.Background(), group, topic)
+}
+
+func (f *FileMq) ConsumeGroupContext(ctx context.Context, group string, topic Topic) (*Delivery, error) {
+	t, err := f.topicOf(topic)
+	if err != nil {
+		return nil, err
+	}
+	return t.consumeGroup(ctx, group)
+}
+
+func (f *FileMq) Produce(message *Message) error {
+	t, err := f.topicOf(message.Topic())
+	if err != nil {
+		return err
+	}
+	return t.produce(message)
+}
+
+// ProduceAt 定时消息持久化在定时消息日志中，重启后继续调度，重启期间到期的消息在重启后立即生产
+func (f *FileMq) ProduceAt(message *Message, at time.Time) error {
+	if err := message.Topic().validate(); err != nil {
+		return err
+	}
+	if !at.After(time.Now()) {
+		return f.Produce(message)
+	}
+	f.mu.Lock()
+	isClosed := f.isClosed
+	f.mu.Unlock()
+	if isClosed {
+		return ErrMqClosed
+	}
+	return f.sched.schedule(message, at)
+}
+
+func (f *FileMq) ProduceAfter(message *Message, delay time.Duration) error {
+	return f.ProduceAt(message, time.Now().Add(delay))
+}
+
+func (f *FileMq) ProduceBatch(messages ...*Message) error {
+	return f.ProduceBatchContext(context.Background(), messages...)
+}
+
+func (f *FileMq) ProduceBatchContext(ctx context.Context, messages ...*Message) error {
+	return produceBatch(ctx, messages, f.topicOf)
+}
+
+// Subscribe 订阅匹配pattern的所有topic，包括之后新建的topic
+func (f *FileMq) Subscribe(pattern Topic, handler Handler) (*Subscription, error) {
+	f.mu.Lock()
+	isClosed := f.isClosed
+	f.mu.Unlock()
+	if isClosed {
+		return nil, ErrMqClosed
+	}
+	return f.subs.subscribe(pattern, handler, func() []*topic {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		topics := make([]*topic, 0, len(f.topics))
+		for _, t := range f.topics {
+			topics = append(topics, t)
+		}
+		return topics
+	}), nil
+}
+
+// Close 关闭所有订阅和topic的segment文件，之后的Consume和Produce返回ErrMqClosed
+func (f *FileMq) Close() error {
+	f.mu.Lock()
+	if f.isClosed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.isClosed = true
+	f.mu.Unlock()
+	var result error
+	if f.sched != nil {
+		result = f.sched.close()
+	}
+	f.subs.closeAll()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.topics {
+		if err := t.close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	f.topics = make(map[Topic]*topic)
+	return result
+}
+
+// CreateTopic 创建主题并持久化conf
+func (f *FileMq) CreateTopic(name Topic, conf TopicConfig) error {
+	f.mu.Lock()
+	if f.isClosed {
+		f.mu.Unlock()
+		return ErrMqClosed
+	}
+	if _, ok := f.topics[name]; ok {
+		f.mu.Unlock()
+		return ErrTopicExists
+	}
+	dir, err := f.topicDir(name)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	if err := saveTopicConfig(dir, conf); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	t, err := f.openTopic(name)
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	f.subs.onTopic(t)
+	return nil
+}
+
+// DeleteTopic 删除主题的目录，包括所有segment文件、消费位置和配置
+// 删除前已调度的定时消息到期后仍会生产到该主题，并按默认配置重新创建主题
+func (f *FileMq) DeleteTopic(name Topic) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.isClosed {
+		return ErrMqClosed
+	}
+	dir, err := f.topicDir(name)
+	if err != nil {
+		return err
+	}
+	t, ok := f.topics[name]
+	if !ok {
+		return ErrTopicNotFound
+	}
+	delete(f.topics, name)
+	f.subs.onDelete(t)
+	if err := t.close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+func (f *FileMq) Topics() ([]*TopicStats, error) {
+	f.mu.Lock()
+	if f.isClosed {
+		f.mu.Unlock()
+		return nil, ErrMqClosed
+	}
+	topics := make([]*topic, 0, len(f.topics))
+	for _, t := range f.topics {
+		topics = append(topics, t)
+	}
+	f.mu.Unlock()
+	stats := make([]*TopicStats, 0, len(topics))
+	for _, t := range topics {
+		stats = append(stats, t.stats())
+	}
+	return sortStats(stats), nil
+}
+
+func (f *FileMq) Stats(name Topic) (*TopicStats, error) {
+	f.mu.Lock()
+	if f.isClosed {
+		f.mu.Unlock()
+		return nil, ErrMqClosed
+	}
+	t, ok := f.topics[name]
+	f.mu.Unlock()
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	return t.stats(), nil
+}
+
+func (f *FileMq) DeleteGroup(name Topic, group string) error {
+	f.mu.Lock()
+	if f.isClosed {
+		f.mu.Unlock()
+		return ErrMqClosed
+	}
+	t, ok := f.topics[name]
+	f.mu.Unlock()
+	if !ok {
+		return ErrTopicNotFound
+	}
+	return t.deleteGroup(group)
+}
+
+// topicOf 返回topic，不存在时创建
+func (f *FileMq) topicOf(name Topic) (*topic, error) {
+	f.mu.Lock()
+	if f.isClosed {
+		f.mu.Unlock()
+		return nil, ErrMqClosed
+	}
+	if t, ok := f.topics[name]; ok {
+		f.mu.Unlock()
+		return t, nil
+	}
+	t, err := f.openTopic(name)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	f.subs.onTopic(t)
+	return t, nil
+}
+
+// openTopic 打开主题，有配置文件时使用主题自己的配置，调用方需持有f.mu
+func (f *FileMq) openTopic(name Topic) (*topic, error) {
+	dir, err := f.topicDir(name)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := loadTopicConfig(dir, f.conf)
+	if err != nil {
+		return nil, err
+	}
+	log, err := openSegmentLog(dir, name, conf)
+	if err != nil {
+		return nil, err
+	}
+	t := newTopic(name, conf, log)
+	t.deadLetter = f.Produce
+	f.topics[name] = t
+	return t, nil
+}
+
+// topicDir 主题的目录，只允许是f.dir的直接子目录，主题名不合法时返回ErrInvalidTopic
+func (f *FileMq) topicDir(name Topic) (string, error) {
+	if err := name.validate(); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(f.dir, url.PathEscape(string(name)))
+	if filepath.Dir(dir) != filepath.Clean(f.dir) {
+		return "", ErrInvalidTopic
+	}
+	return dir, nil
+}
+
+// loadTopicConfig 读取主题配置，没有配置文件时返回def
+func loadTopicConfig(dir string, def TopicConfig) (TopicConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, topicConfigFile))
+	if os.IsNotExist(err) {
+		return def, nil
+	}
+	if err != nil {
+		return def, err
+	}
+	conf := def
+	err = json.Unmarshal(data, &conf)
+	return conf, err
+}
+
+// saveTopicConfig 先写临时文件再重命名，避免崩溃时文件损坏
+func saveTopicConfig(dir string, conf TopicConfig) error {
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, topicConfigFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,171 @@
+package mq
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestFileMqDurable(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := fileMq.Produce(NewMessage("access_log.topic", "log"+strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msg, err := fileMq.Consume("access_log.topic")
+	if err != nil || msg.Payload() != "log0" {
+		t.Fatalf("consume got %v, %v", msg, err)
+	}
+	if err := fileMq.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileMq.Produce(NewMessage("access_log.topic", "log3")); err != ErrMqClosed {
+		t.Errorf("produce after close got %v", err)
+	}
+
+	// 重启后从上次的消费位置继续消费
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	for _, want := range []string{"log1", "log2"} {
+		msg, err := fileMq.Consume("access_log.topic")
+		if err != nil || msg.Payload() != want {
+			t.Errorf("consume want %s, got %v, %v", want, msg, err)
+		}
+		if msg.Topic() != "access_log.topic" {
+			t.Errorf("topic want access_log.topic, got %s", msg.Topic())
+		}
+	}
+}
+
+func TestFileMqRetention(t *testing.T) {
+	dir := t.TempDir()
+	conf := TopicConfig{SegmentBytes: 100, RetentionBytes: 200}
+	fileMq, err := NewFileMq(dir, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	for i := 0; i < 20; i++ {
+		if err := fileMq.Produce(NewMessage("test", "message"+strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "test", "*"+segmentSuffix))
+	if len(segments) > 3 {
+		t.Errorf("retention not applied, %d segments left", len(segments))
+	}
+	// 过期的消息被删除，从最老的可用消息开始消费
+	msg, err := fileMq.Consume("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Payload() == "message0" {
+		t.Error("message0 should be removed by retention")
+	}
+}
+
+func TestFileMqTruncateBrokenRecord(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMq.Produce(NewMessage("test", "hello"))
+	fileMq.Close()
+	// 模拟写入时崩溃，末尾留下不完整的记录
+	file, _ := os.OpenFile(segmentPath(filepath.Join(dir, "test"), 0), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"offset":1,"payl`)
+	file.Close()
+
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	fileMq.Produce(NewMessage("test", "world"))
+	for _, want := range []string{"hello", "world"} {
+		msg, err := fileMq.Consume("test")
+		if err != nil || msg.Payload() != want {
+			t.Errorf("consume want %s, got %v, %v", want, msg, err)
+		}
+	}
+}
+
+func TestFileMqProduceBatchAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 一批内有无法编码的消息时，整批都不写入
+	if err := fileMq.ProduceBatch(NewMessage("test", "1"), NewObjectMessage("test", make(chan int))); err == nil {
+		t.Error("want error for unmarshalable payload")
+	}
+	fileMq.Produce(NewMessage("test", "hello"))
+	fileMq.Close()
+	// 模拟一批写入失败后残留的完整记录，offset与前面的记录不连续
+	file, _ := os.OpenFile(segmentPath(filepath.Join(dir, "test"), 0), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"offset":5,"partition":0,"payload":"stale"}` + "\n")
+	file.Close()
+
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	fileMq.Produce(NewMessage("test", "world"))
+	for _, want := range []string{"hello", "world"} {
+		msg, err := fileMq.Consume("test")
+		if err != nil || msg.Payload() != want {
+			t.Errorf("consume want %s, got %v, %v", want, msg, err)
+		}
+	}
+}
+
+func TestTopicFull(t *testing.T) {
+	fileMq, err := NewFileMq(t.TempDir(), TopicConfig{Capacity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	fileMq.Produce(NewMessage("test", "1"))
+	fileMq.Produce(NewMessage("test", "2"))
+	if err := fileMq.Produce(NewMessage("test", "3")); err != ErrTopicFull {
+		t.Errorf("want ErrTopicFull, got %v", err)
+	}
+	fileMq.Consume("test")
+	if err := fileMq.Produce(NewMessage("test", "3")); err != nil {
+		t.Errorf("want nil, got %v", err)
+	}
+}
+
+func TestFileMqInvalidTopic(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "mq")
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	for _, topic := range []Topic{"", ".", "..", "a/b", `a\b`} {
+		if err := fileMq.Produce(NewMessage(topic, "log")); err != ErrInvalidTopic {
+			t.Errorf("produce to %q want ErrInvalidTopic, got %v", topic, err)
+		}
+		if err := fileMq.CreateTopic(topic, DefaultTopicConfig()); err != ErrInvalidTopic {
+			t.Errorf("create %q want ErrInvalidTopic, got %v", topic, err)
+		}
+	}
+	// 不会在存储目录之外写入文件
+	if entries, _ := os.ReadDir(parent); len(entries) != 1 {
+		t.Errorf("want only mq dir in parent, got %v", entries)
+	}
+}
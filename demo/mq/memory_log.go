@@ -0,0 +1,71 @@
+package mq
+
+import "fmt"
+
+// memoryLog 基于内存的messageLog实现，所有消费者都已消费过的消息只保留最近的retain条，其余的会被释放
+// 因此新加入的消费组只能从尚未释放的消息开始消费
+type memoryLog struct {
+	topic    Topic
+	retain   int
+	start    int64
+	messages []*Message
+	offsets  map[string]int64
+}
+
+func newMemoryLog(topic Topic, retain int) *memoryLog {
+	return &memoryLog{topic: topic, retain: retain, offsets: make(map[string]int64)}
+}
+
+func (m *memoryLog) append(messages []*Message) (int64, error) {
+	offset := m.endOffset()
+	m.messages = append(m.messages, messages...)
+	return offset, nil
+}
+
+func (m *memoryLog) read(offset int64) (*Message, error) {
+	if offset < m.start || offset >= m.endOffset() {
+		return nil, fmt.Errorf("offset %d out of range [%d, %d)", offset, m.start, m.endOffset())
+	}
+	return m.messages[offset-m.start], nil
+}
+
+func (m *memoryLog) startOffset() int64 {
+	return m.start
+}
+
+func (m *memoryLog) endOffset() int64 {
+	return m.start + int64(len(m.messages))
+}
+
+func (m *memoryLog) commit(consumer string, offset int64) error {
+	m.offsets[consumer] = offset
+	return nil
+}
+
+func (m *memoryLog) remove(consumer string) error {
+	delete(m.offsets, consumer)
+	return nil
+}
+
+// trim 释放offset之前的消息，保留最近的retain条
+func (m *memoryLog) trim(offset int64) {
+	if keep := m.endOffset() - int64(m.retain); keep < offset {
+		offset = keep
+	}
+	if offset > m.start {
+		m.messages = append([]*Message(nil), m.messages[offset-m.start:]...)
+		m.start = offset
+	}
+}
+
+func (m *memoryLog) committed() map[string]int64 {
+	offsets := make(map[string]int64, len(m.offsets))
+	for consumer, offset := range m.offsets {
+		offsets[consumer] = offset
+	}
+	return offsets
+}
+
+func (m *memoryLog) close() error {
+	return nil
+}
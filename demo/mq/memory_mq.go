@@ -1,8 +1,9 @@
 package mq
 
 import (
-	"errors"
+	"context"
 	"sync"
+	"time"
 )
 
 /*
@@ -12,47 +13,184 @@ import (
 var once = &sync.Once{}
 var memoryMqInstance *memoryMq
 
-// memoryMq 内存消息队列，通过channel模式
+// memoryMq 内存消息队列，重启后消息会丢失，需要持久化时使用FileMq
 type memoryMq struct {
-	queues sync.Map // key为Topic，value为chan *Message，每个topic单独一个队列
+	conf   TopicConfig
+	mu     sync.RWMutex // 保护topics和sched，Clear时整体替换
+	topics *sync.Map    // key为Topic，value为*topic，每个topic单独一个队列
+	subs   *subscriptions
+	sched  *scheduler
 }
 
 func MemoryMqInstance() *memoryMq {
 	once.Do(func() {
-		memoryMqInstance = &memoryMq{queues: sync.Map{}}
+		memoryMqInstance = newMemoryMq(DefaultTopicConfig())
 	})
 	return memoryMqInstance
 }
 
+func newMemoryMq(conf TopicConfig) *memoryMq {
+	m := &memoryMq{conf: conf, topics: &sync.Map{}, subs: newSubscriptions()}
+	m.sched = newScheduler(m.Produce, nil, nil)
+	return m
+}
+
+// Clear 关闭所有订阅，并清空所有topic和定时消息，阻塞中的消费者返回ErrMqClosed
+// 先关闭topic唤醒阻塞中的生产者，再停止调度goroutine，之后重新创建调度器，Clear后可以继续使用
 func (m *memoryMq) Clear() {
-	m.queues = sync.Map{}
+	m.subs.closeAll()
+	topics, sched := m.state()
+	topics.Range(func(key, value interface{}) bool {
+		value.(*topic).close()
+		return true
+	})
+	sched.close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topics = &sync.Map{}
+	m.sched = newScheduler(m.Produce, nil, nil)
+}
+
+// state 返回当前的topic表和调度器
+func (m *memoryMq) state() (*sync.Map, *scheduler) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.topics, m.sched
+}
+
+func (m *memoryMq) topicMap() *sync.Map {
+	topics, _ := m.state()
+	return topics
 }
 
 func (m *memoryMq) Consume(topic Topic) (*Message, error) {
-	record, ok := m.queues.Load(topic)
-	if !ok {
-		q := make(chan *Message, 10000)
-		m.queues.Store(topic, q)
-		record = q
+	return m.ConsumeContext(context.Background(), topic)
+}
+
+func (m *memoryMq) ConsumeContext(ctx context.Context, topic Topic) (*Message, error) {
+	return m.topicOf(topic).consume(ctx)
+}
+
+func (m *memoryMq) TryConsume(topic Topic) (*Message, error) {
+	return m.topicOf(topic).tryConsume()
+}
+
+func (m *memoryMq) ConsumeN(topic Topic, n int, wait time.Duration) ([]*Message, error) {
+	return m.topicOf(topic).consumeWithin(n, wait)
+}
+
+// ConsumeGroup 以消费组的方式消费消息，处理完成后需要调用Delivery.Ack
+func (m *memoryMq) ConsumeGroup(group string, topic Topic) (*Delivery, error) {
+	return m.ConsumeGroupContext(context.Background(), group, topic)
+}
+
+func (m *memoryMq) ConsumeGroupContext(ctx context.Context, group string, topic Topic) (*Delivery, error) {
+	return m.topicOf(topic).consumeGroup(ctx, group)
+}
+
+// Produce 生产消息，topic积压满时最多等待ProduceTimeout，超时返回ErrTopicFull
+func (m *memoryMq) Produce(message *Message) error {
+	return m.topicOf(message.Topic()).produce(message)
+}
+
+// ProduceAt 定时消息只保存在内存中，重启后丢失
+func (m *memoryMq) ProduceAt(message *Message, at time.Time) error {
+	if err := message.Topic().validate(); err != nil {
+		return err
+	}
+	if !at.After(time.Now()) {
+		return m.Produce(message)
 	}
-	queue, ok := record.(chan *Message)
+	_, sched := m.state()
+	return sched.schedule(message, at)
+}
+
+func (m *memoryMq) ProduceAfter(message *Message, delay time.Duration) error {
+	return m.ProduceAt(message, time.Now().Add(delay))
+}
+
+func (m *memoryMq) ProduceBatch(messages ...*Message) error {
+	return m.ProduceBatchContext(context.Background(), messages...)
+}
+
+func (m *memoryMq) ProduceBatchContext(ctx context.Context, messages ...*Message) error {
+	return produceBatch(ctx, messages, func(name Topic) (*topic, error) {
+		return m.topicOf(name), nil
+	})
+}
+
+// Subscribe 订阅匹配pattern的所有topic，包括之后新建的topic
+func (m *memoryMq) Subscribe(pattern Topic, handler Handler) (*Subscription, error) {
+	return m.subs.subscribe(pattern, handler, func() []*topic {
+		var topics []*topic
+		m.topicMap().Range(func(key, value interface{}) bool {
+			topics = append(topics, value.(*topic))
+			return true
+		})
+		return topics
+	}), nil
+}
+
+func (m *memoryMq) CreateTopic(name Topic, conf TopicConfig) error {
+	if err := name.validate(); err != nil {
+		return err
+	}
+	if _, loaded := m.loadOrCreate(name, conf); loaded {
+		return ErrTopicExists
+	}
+	return nil
+}
+
+func (m *memoryMq) DeleteTopic(name Topic) error {
+	record, ok := m.topicMap().LoadAndDelete(name)
 	if !ok {
-		return nil, errors.New("model's type is not chan *Message")
+		return ErrTopicNotFound
 	}
-	return <-queue, nil
+	t := record.(*topic)
+	m.subs.onDelete(t)
+	return t.close()
 }
 
-func (m *memoryMq) Produce(message *Message) error {
-	record, ok := m.queues.Load(message.Topic())
+func (m *memoryMq) Topics() ([]*TopicStats, error) {
+	var stats []*TopicStats
+	m.topicMap().Range(func(key, value interface{}) bool {
+		stats = append(stats, value.(*topic).stats())
+		return true
+	})
+	return sortStats(stats), nil
+}
+
+func (m *memoryMq) Stats(name Topic) (*TopicStats, error) {
+	record, ok := m.topicMap().Load(name)
 	if !ok {
-		q := make(chan *Message, 10000)
-		m.queues.Store(message.Topic(), q)
-		record = q
+		return nil, ErrTopicNotFound
 	}
-	queue, ok := record.(chan *Message)
+	return record.(*topic).stats(), nil
+}
+
+func (m *memoryMq) DeleteGroup(name Topic, group string) error {
+	record, ok := m.topicMap().Load(name)
 	if !ok {
-		return errors.New("model's type is not chan *Message")
+		return ErrTopicNotFound
 	}
-	queue <- message
-	return nil
+	return record.(*topic).deleteGroup(group)
+}
+
+func (m *memoryMq) topicOf(name Topic) *topic {
+	if record, ok := m.topicMap().Load(name); ok {
+		return record.(*topic)
+	}
+	t, _ := m.loadOrCreate(name, m.conf)
+	return t
+}
+
+// loadOrCreate 返回已存在的topic，不存在时按照conf创建，loaded表示topic是否已存在
+func (m *memoryMq) loadOrCreate(name Topic, conf TopicConfig) (*topic, bool) {
+	t := newTopic(name, conf, newMemoryLog(name, conf.Capacity))
+	t.deadLetter = m.Produce
+	record, loaded := m.topicMap().LoadOrStore(name, t)
+	if !loaded {
+		m.subs.onTopic(t)
+	}
+	return record.(*topic), loaded
 }
@@ -1,6 +1,11 @@
 package mq
 
-import "testing"
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestMemoryMq(t *testing.T) {
 	msg := NewMessage("test", "hello world")
@@ -17,3 +22,73 @@ func TestMemoryMq(t *testing.T) {
 	}
 	MemoryMqInstance().Clear()
 }
+
+func TestMemoryMqConsumeContext(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	if _, err := m.TryConsume("test"); err != ErrNoMessage {
+		t.Errorf("want ErrNoMessage, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.ConsumeContext(ctx, "test"); err != context.DeadlineExceeded {
+		t.Errorf("want DeadlineExceeded, got %v", err)
+	}
+	err := m.ProduceBatch(NewMessage("test", "1"), NewMessage("test", "2"), NewMessage("other", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := m.TryConsume("other")
+	if err != nil || msg.Payload() != "3" {
+		t.Errorf("want 3, got %v, %v", msg, err)
+	}
+	// 不足n条时等待超时，返回已有的消息
+	start := time.Now()
+	messages, err := m.ConsumeN("test", 3, 20*time.Millisecond)
+	if err != nil || len(messages) != 2 {
+		t.Errorf("want 2 messages, got %d, %v", len(messages), err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("ConsumeN returned before wait")
+	}
+}
+
+func TestMemoryMqProduceBatchFull(t *testing.T) {
+	m := newMemoryMq(TopicConfig{Capacity: 2})
+	defer m.Clear()
+	if err := m.ProduceBatch(NewMessage("test", "1"), NewMessage("test", "2"), NewMessage("test", "3")); err != ErrTopicFull {
+		t.Errorf("want ErrTopicFull, got %v", err)
+	}
+	if _, err := m.TryConsume("test"); err != ErrNoMessage {
+		t.Errorf("batch should not be partially produced, got %v", err)
+	}
+}
+
+// 使用-race运行，Clear与生产、消费并发时不会有数据竞争
+func TestMemoryMqClearConcurrently(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Produce(NewMessage("test", "hello"))
+				m.ProduceAfter(NewMessage("test", "later"), time.Millisecond)
+				m.TryConsume("test")
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		m.Clear()
+	}
+	wg.Wait()
+	m.Clear()
+	if err := m.Produce(NewMessage("test", "hello")); err != nil {
+		t.Fatalf("want produce after clear, got %v", err)
+	}
+	if msg, err := m.TryConsume("test"); err != nil || msg.Payload() != "hello" {
+		t.Errorf("want hello, got %v, %v", msg, err)
+	}
+}
@@ -0,0 +1,181 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 消息payload的内容类型
+const (
+	ContentTypeText  = "text/plain"
+	ContentTypeBytes = "application/octet-stream"
+	ContentTypeJson  = "application/json"
+)
+
+// Message 消息队列中消息定义
+// key相同的消息会被分配到同一个分区，消费组按分区顺序投递；timestamp和partition在生产时填充
+type Message struct {
+	topic       Topic
+	key         string
+	headers     map[string]string
+	payload     interface{}
+	contentType string
+	timestamp   time.Time
+	partition   int
+}
+
+// NewMessage 创建文本消息
+func NewMessage(topic Topic, payload string) *Message {
+	return &Message{
+		topic:       topic,
+		headers:     make(map[string]string),
+		payload:     payload,
+		contentType: ContentTypeText,
+	}
+}
+
+// NewBytesMessage 创建二进制消息
+func NewBytesMessage(topic Topic, payload []byte) *Message {
+	return &Message{
+		topic:       topic,
+		headers:     make(map[string]string),
+		payload:     payload,
+		contentType: ContentTypeBytes,
+	}
+}
+
+// NewObjectMessage 创建任意类型的消息，持久化时以json格式保存，消费时可通过Decode解析
+func NewObjectMessage(topic Topic, payload interface{}) *Message {
+	return &Message{
+		topic:       topic,
+		headers:     make(map[string]string),
+		payload:     payload,
+		contentType: ContentTypeJson,
+	}
+}
+
+func (m *Message) WithKey(key string) *Message {
+	m.key = key
+	return m
+}
+
+func (m *Message) WithContentType(contentType string) *Message {
+	m.contentType = contentType
+	return m
+}
+
+// WithTimestamp 设置消息时间，未设置时为生产时间
+func (m *Message) WithTimestamp(timestamp time.Time) *Message {
+	m.timestamp = timestamp
+	return m
+}
+
+func (m *Message) AddHeader(key, value string) *Message {
+	m.headers[key] = value
+	return m
+}
+
+func (m Message) Topic() Topic {
+	return m.topic
+}
+
+func (m Message) Key() string {
+	return m.key
+}
+
+func (m Message) Header(key string) (string, bool) {
+	val, ok := m.headers[key]
+	return val, ok
+}
+
+// Headers 返回所有header的拷贝
+func (m Message) Headers() map[string]string {
+	headers := make(map[string]string, len(m.headers))
+	for k, v := range m.headers {
+		headers[k] = v
+	}
+	return headers
+}
+
+func (m Message) ContentType() string {
+	return m.contentType
+}
+
+func (m Message) Timestamp() time.Time {
+	return m.timestamp
+}
+
+func (m Message) Partition() int {
+	return m.partition
+}
+
+// Value 返回原始的payload，持久化后的json消息为json.RawMessage
+func (m Message) Value() interface{} {
+	return m.payload
+}
+
+// Payload 返回文本形式的payload，二进制消息直接转换，其他类型的消息转换为json
+func (m Message) Payload() string {
+	switch payload := m.payload.(type) {
+	case string:
+		return payload
+	case []byte:
+		return string(payload)
+	case json.RawMessage:
+		return string(payload)
+	default:
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Sprint(payload)
+		}
+		return string(data)
+	}
+}
+
+// Bytes 返回二进制形式的payload
+func (m Message) Bytes() []byte {
+	if payload, ok := m.payload.([]byte); ok {
+		return payload
+	}
+	return []byte(m.Payload())
+}
+
+// Decode 将json消息解析到v中
+func (m Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Bytes(), v)
+}
+
+// clone 拷贝消息，生产时使用，避免修改生产者持有的消息
+func (m *Message) clone() *Message {
+	message := *m
+	message.headers = m.Headers()
+	return &message
+}
+
+// messageJson 消息的json格式，用于消息在网络上传输
+type messageJson struct {
+	Topic Topic `json:"topic"`
+	*logRecord
+}
+
+func (m *Message) MarshalJSON() ([]byte, error) {
+	record, err := recordOf(0, m)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(messageJson{Topic: m.topic, logRecord: record})
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	mj := messageJson{logRecord: &logRecord{}}
+	if err := json.Unmarshal(data, &mj); err != nil {
+		return err
+	}
+	message, err := mj.message(mj.Topic)
+	if err != nil {
+		return err
+	}
+	*m = *message
+	return nil
+}
@@ -0,0 +1,112 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type order struct {
+	Id    int    `json:"id"`
+	State string `json:"state"`
+}
+
+func TestMessagePersistence(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fileMq.ProduceBatch(
+		NewMessage("test", "hello").WithKey("k1").AddHeader("trace-id", "t1").WithTimestamp(timestamp),
+		NewBytesMessage("test", []byte{0, 1, 2}),
+		NewObjectMessage("test", order{Id: 1, State: "created"}),
+	)
+	fileMq.Close()
+
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	messages, err := fileMq.ConsumeN("test", 3, time.Second)
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("want 3 messages, got %d, %v", len(messages), err)
+	}
+	text := messages[0]
+	if traceId, _ := text.Header("trace-id"); traceId != "t1" || text.Key() != "k1" {
+		t.Errorf("want header t1 and key k1, got %s and %s", traceId, text.Key())
+	}
+	if !text.Timestamp().Equal(timestamp) || text.ContentType() != ContentTypeText {
+		t.Errorf("want timestamp %v and text, got %v and %s", timestamp, text.Timestamp(), text.ContentType())
+	}
+	if bytes := messages[1].Bytes(); len(bytes) != 3 || bytes[2] != 2 || messages[1].ContentType() != ContentTypeBytes {
+		t.Errorf("want bytes [0 1 2], got %v", bytes)
+	}
+	var o order
+	if err := messages[2].Decode(&o); err != nil || o.State != "created" {
+		t.Errorf("want created order, got %v, %v", o, err)
+	}
+}
+
+func TestPartitionOrdering(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	m.ProduceBatch(
+		NewMessage("order", "created").WithKey("order-1"),
+		NewMessage("order", "paid").WithKey("order-1"),
+	)
+	first, _ := m.ConsumeGroup("group", "order")
+	if first.Message().Payload() != "created" {
+		t.Fatalf("want created, got %s", first.Message().Payload())
+	}
+	// 同一key的消息在前一条Ack之前不会被投递
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.ConsumeGroupContext(ctx, "group", "order"); err != context.DeadlineExceeded {
+		t.Errorf("want DeadlineExceeded, got %v", err)
+	}
+	first.Ack()
+	second, _ := m.ConsumeGroup("group", "order")
+	if second.Message().Payload() != "paid" || second.Message().Partition() != first.Message().Partition() {
+		t.Errorf("want paid in same partition, got %s", second.Message().Payload())
+	}
+}
+
+func TestPartitionNotBlockingOthers(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	m.ProduceBatch(
+		NewMessage("order", "created").WithKey("order-1"),
+		NewMessage("order", "paid").WithKey("order-1"),
+		NewMessage("order", "created").WithKey("order-2"),
+		NewMessage("order", "paid").WithKey("order-2"),
+	)
+	first, _ := m.ConsumeGroup("group", "order")
+	// order-1的消息未Ack时，其他分区的消息继续按顺序投递
+	second, _ := m.ConsumeGroup("group", "order")
+	if second.Message().Key() != "order-2" || second.Message().Payload() != "created" {
+		t.Fatalf("want created of order-2, got %s of %s", second.Message().Payload(), second.Message().Key())
+	}
+	second.Ack()
+	third, _ := m.ConsumeGroup("group", "order")
+	if third.Message().Key() != "order-2" || third.Message().Payload() != "paid" {
+		t.Fatalf("want paid of order-2, got %s of %s", third.Message().Payload(), third.Message().Key())
+	}
+	third.Ack()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.ConsumeGroupContext(ctx, "group", "order"); err != context.DeadlineExceeded {
+		t.Errorf("want DeadlineExceeded, got %v", err)
+	}
+	first.Ack()
+	fourth, _ := m.ConsumeGroup("group", "order")
+	if fourth.Message().Key() != "order-1" || fourth.Message().Payload() != "paid" {
+		t.Errorf("want paid of order-1, got %s of %s", fourth.Message().Payload(), fourth.Message().Key())
+	}
+	fourth.Ack()
+	if stats, _ := m.Stats("order"); stats.Groups[0].Committed != 4 {
+		t.Errorf("want committed 4, got %d", stats.Groups[0].Committed)
+	}
+}
@@ -1,5 +1,11 @@
 package mq
 
+import (
+	"context"
+	"strings"
+	"time"
+)
+
 /**
  * 接口隔离原则（ISP）：一个模块不应该强迫客户程序依赖它们不想使用的接口，模块间的关系应该建立在最小的接口集上。
  * 实现ISP的关键是将大接口拆分成小接口，而拆分的关键就是接口粒度的把握。接口隔离可以减少模块间耦合，提升系统稳定性。
@@ -11,39 +17,71 @@ package mq
 
 // Consumable 消费接口，从消息队列中消费数据
 type Consumable interface {
+	// Consume 消费一条消息，没有消息时一直阻塞
 	Consume(topic Topic) (*Message, error)
+	// ConsumeContext 消费一条消息，没有消息时阻塞，直到ctx结束
+	ConsumeContext(ctx context.Context, topic Topic) (*Message, error)
+	// TryConsume 消费一条消息，没有消息时立即返回ErrNoMessage
+	TryConsume(topic Topic) (*Message, error)
+	// ConsumeN 批量消费n条消息，最多等待wait时间，超时时返回已消费的消息
+	ConsumeN(topic Topic, n int, wait time.Duration) ([]*Message, error)
 }
 
 // Producible 生产接口，向消息队列生产消费数据
 type Producible interface {
 	Produce(message *Message) error
+	// ProduceBatch 按顺序批量生产消息，相邻的同一topic的消息作为一批追加，一批内的消息要么全部生产成功，要么全部失败；
+	// 涉及多个topic时按批依次生产，某一批失败时停止，之前的批已生产成功
+	ProduceBatch(messages ...*Message) error
+	// ProduceBatchContext 同ProduceBatch，topic积压满时最多等待到ctx结束或ProduceTimeout，超时返回ErrTopicFull
+	ProduceBatchContext(ctx context.Context, messages ...*Message) error
 }
 
-// Mq 消息队列接口，继承了Consumable和Producible，同时又consume和produce两种行为
-type Mq interface {
-	Consumable
-	Producible
+// GroupConsumable 消费组接口，同一消费组内的消费者分摊topic中的消息，不同消费组各自消费全量消息
+type GroupConsumable interface {
+	ConsumeGroup(group string, topic Topic) (*Delivery, error)
+	// ConsumeGroupContext 消费组消费一条消息，没有消息时阻塞，直到ctx结束
+	ConsumeGroupContext(ctx context.Context, group string, topic Topic) (*Delivery, error)
 }
 
-type Topic string
-
-// Message 消息队列中消息定义
-type Message struct {
-	topic   Topic
-	payload string
+// Schedulable 定时消息接口，消息到期后才会生产到topic中，对消费者可见
+type Schedulable interface {
+	// ProduceAt 在at时刻生产消息，at已过去时立即生产
+	ProduceAt(message *Message, at time.Time) error
+	// ProduceAfter 在delay之后生产消息
+	ProduceAfter(message *Message, delay time.Duration) error
 }
 
-func NewMessage(topic Topic, payload string) *Message {
-	return &Message{
-		topic:   topic,
-		payload: payload,
-	}
+// Admin 主题管理接口，不属于Mq，由运维工具依赖
+type Admin interface {
+	// CreateTopic 按照conf创建主题，主题已存在时返回ErrTopicExists
+	// 未通过CreateTopic创建的主题在首次生产或消费时按照消息队列的默认配置自动创建
+	CreateTopic(name Topic, conf TopicConfig) error
+	// DeleteTopic 删除主题的所有消息和消费位置，阻塞在该主题上的生产者和消费者返回ErrMqClosed
+	DeleteTopic(name Topic) error
+	// Topics 所有主题的统计信息，按名称排序
+	Topics() ([]*TopicStats, error)
+	// Stats 单个主题的统计信息，主题不存在时返回ErrTopicNotFound
+	Stats(name Topic) (*TopicStats, error)
+	// DeleteGroup 删除不再使用的消费组及其消费位置，使其不再阻止日志释放消息，未Ack的消息不再投递
+	// 消费组不存在时返回ErrGroupNotFound，仍在消费的消费者会重新创建消费组，从最老的可用消息开始消费
+	DeleteGroup(name Topic, group string) error
 }
 
-func (m Message) Topic() Topic {
-	return m.topic
+// Mq 消息队列接口，继承了Consumable、GroupConsumable、Producible和Schedulable，同时又consume和produce两种行为
+type Mq interface {
+	Consumable
+	GroupConsumable
+	Producible
+	Schedulable
 }
 
-func (m Message) Payload() string {
-	return m.payload
+type Topic string
+
+// validate 主题名不能为空、.或..，也不能包含路径分隔符，避免持久化时逃逸出存储目录
+func (t Topic) validate() error {
+	if t == "" || t == "." || t == ".." || strings.ContainsAny(string(t), `/\`) {
+		return ErrInvalidTopic
+	}
+	return nil
 }
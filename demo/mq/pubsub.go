@@ -0,0 +1,213 @@
+package mq
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+/*
+观察者模式
+*/
+
+// 订阅使用的临时消费组前缀，订阅关闭后消费组会被删除
+const subscriptionGroupPrefix = "subscription-"
+
+// Handler 订阅消息的处理方法，返回错误时消息会被重新投递，超过MaxDeliveries后进入死信主题
+type Handler func(message *Message) error
+
+// Subscribable 发布订阅接口，每个订阅都会收到订阅之后发布到匹配主题上的全部消息
+// pattern以.分隔，*匹配一段，#匹配零或多段，如 access_log.* 匹配 access_log.topic
+// 死信主题只有pattern同样以.dlq结尾时才会匹配，如 #.dlq
+type Subscribable interface {
+	Subscribe(pattern Topic, handler Handler) (*Subscription, error)
+}
+
+// MatchTopic 判断topic是否匹配pattern
+func MatchTopic(pattern, topic Topic) bool {
+	return matchSegments(strings.Split(string(pattern), "."), strings.Split(string(topic), "."))
+}
+
+func matchSegments(patterns, segments []string) bool {
+	if len(patterns) == 0 {
+		return len(segments) == 0
+	}
+	if patterns[0] == "#" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(patterns[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 || (patterns[0] != "*" && patterns[0] != segments[0]) {
+		return false
+	}
+	return matchSegments(patterns[1:], segments[1:])
+}
+
+// Subscription 订阅，每个匹配的主题上都有一个临时消费组，在独立的goroutine中推送消息给handler
+type Subscription struct {
+	group   string
+	pattern Topic
+	handler Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	topics  map[Topic]*topic
+	onClose func()
+}
+
+func newSubscription(pattern Topic, handler Handler) *Subscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Subscription{
+		group:   subscriptionGroupPrefix + uuid.NewString(),
+		pattern: pattern,
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+		topics:  make(map[Topic]*topic),
+	}
+}
+
+func (s *Subscription) Pattern() Topic {
+	return s.pattern
+}
+
+// Close 取消订阅，等待正在处理的消息完成，并删除临时消费组
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.mu.Lock()
+	topics, onClose := s.topics, s.onClose
+	s.topics, s.onClose = make(map[Topic]*topic), nil
+	s.mu.Unlock()
+	for _, t := range topics {
+		t.leaveGroup(s.group)
+	}
+	if onClose != nil {
+		onClose()
+	}
+}
+
+// attach 开始推送主题t的消息，offset为临时消费组的起始消费位置，重复attach同一主题无副作用
+func (s *Subscription) attach(t *topic, offset int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.topics[t.name]; ok || s.ctx.Err() != nil || !s.matches(t.name) {
+		return
+	}
+	s.topics[t.name] = t
+	t.mu.Lock()
+	t.joinGroup(s.group, offset)
+	t.mu.Unlock()
+	s.wg.Add(1)
+	go s.push(t)
+}
+
+// matches 订阅是否匹配主题，死信主题只有pattern也以死信后缀结尾时才匹配
+// 避免#这类通配订阅处理失败的消息进入死信主题后再被自己订阅，不断产生x.dlq.dlq
+func (s *Subscription) matches(name Topic) bool {
+	if isDeadLetter(name) && !isDeadLetter(s.pattern) {
+		return false
+	}
+	return MatchTopic(s.pattern, name)
+}
+
+func isDeadLetter(name Topic) bool {
+	return strings.HasSuffix(string(name), DeadLetterSuffix)
+}
+
+// detach 主题被删除时停止推送，之后重建的同名主题可以重新attach
+func (s *Subscription) detach(t *topic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.topics[t.name] == t {
+		delete(s.topics, t.name)
+	}
+}
+
+func (s *Subscription) push(t *topic) {
+	defer s.wg.Done()
+	for {
+		delivery, err := t.consumeGroup(s.ctx, s.group)
+		if err != nil {
+			return
+		}
+		if err := s.handler(delivery.Message()); err != nil {
+			delivery.Nack()
+			continue
+		}
+		delivery.Ack()
+	}
+}
+
+// subscriptions 管理一个消息队列上的所有订阅，新建主题时为匹配的订阅开始推送
+type subscriptions struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{subs: make(map[*Subscription]struct{})}
+}
+
+// subscribe 新增订阅，已存在的主题只推送订阅之后发布的消息
+// 先注册订阅再获取已存在的主题，期间新建的主题由onTopic推送，不会遗漏，重复attach无副作用
+func (s *subscriptions) subscribe(pattern Topic, handler Handler, existing func() []*topic) *Subscription {
+	sub := newSubscription(pattern, handler)
+	sub.onClose = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subs, sub)
+	}
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	for _, t := range existing() {
+		t.mu.Lock()
+		end := t.log.endOffset()
+		t.mu.Unlock()
+		sub.attach(t, end)
+	}
+	return sub
+}
+
+// onTopic 新建主题时回调
+func (s *subscriptions) onTopic(t *topic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		t.mu.Lock()
+		start := t.log.startOffset()
+		t.mu.Unlock()
+		sub.attach(t, start)
+	}
+}
+
+// onDelete 删除主题时回调，主题关闭后推送goroutine会自行退出
+func (s *subscriptions) onDelete(t *topic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		sub.detach(t)
+	}
+}
+
+// closeAll 关闭所有订阅
+func (s *subscriptions) closeAll() {
+	s.mu.Lock()
+	subs := make([]*Subscription, 0, len(s.subs))
+	for sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.mu.Unlock()
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
@@ -0,0 +1,161 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		pattern, topic Topic
+		want           bool
+	}{
+		{"access_log.topic", "access_log.topic", true},
+		{"access_log.*", "access_log.topic", true},
+		{"access_log.*", "access_log", false},
+		{"access_log.*", "access_log.topic.dlq", false},
+		{"access_log.#", "access_log.topic.dlq", true},
+		{"access_log.#", "access_log", true},
+		{"*.topic", "access_log.topic", true},
+		{"#", "any.topic", true},
+	}
+	for _, c := range cases {
+		if got := MatchTopic(c.pattern, c.topic); got != c.want {
+			t.Errorf("match %s with %s want %v, got %v", c.pattern, c.topic, c.want, got)
+		}
+	}
+}
+
+func receive(t *testing.T, ch chan *Message) *Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("receive message timeout")
+		return nil
+	}
+}
+
+func TestSubscribeFanOut(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	ch0, ch1 := make(chan *Message, 10), make(chan *Message, 10)
+	sub0, _ := m.Subscribe("access_log.*", func(message *Message) error {
+		ch0 <- message
+		return nil
+	})
+	sub1, _ := m.Subscribe("access_log.topic", func(message *Message) error {
+		ch1 <- message
+		return nil
+	})
+	m.Produce(NewMessage("access_log.topic", "hello"))
+	// 每个订阅都收到全部消息
+	if msg := receive(t, ch0); msg.Payload() != "hello" {
+		t.Errorf("sub0 want hello, got %s", msg.Payload())
+	}
+	if msg := receive(t, ch1); msg.Payload() != "hello" {
+		t.Errorf("sub1 want hello, got %s", msg.Payload())
+	}
+	// 通配符订阅匹配之后新建的topic
+	m.Produce(NewMessage("access_log.other", "world"))
+	if msg := receive(t, ch0); msg.Topic() != "access_log.other" {
+		t.Errorf("sub0 want access_log.other, got %s", msg.Topic())
+	}
+	// 取消订阅后不再收到消息，点对点消费不受影响
+	sub0.Close()
+	sub1.Close()
+	m.Produce(NewMessage("access_log.topic", "again"))
+	select {
+	case msg := <-ch1:
+		t.Errorf("closed subscription receive %s", msg.Payload())
+	case <-time.After(50 * time.Millisecond):
+	}
+	if msg, _ := m.Consume("access_log.topic"); msg.Payload() != "hello" {
+		t.Errorf("consume want hello, got %s", msg.Payload())
+	}
+}
+
+func TestSubscribeRedeliver(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	ch := make(chan *Message, 10)
+	failed := false
+	m.Subscribe("test", func(message *Message) error {
+		if !failed {
+			failed = true
+			return errors.New("handle failed")
+		}
+		ch <- message
+		return nil
+	})
+	m.Produce(NewMessage("test", "hello"))
+	// 处理失败的消息会被重新推送
+	if msg := receive(t, ch); msg.Payload() != "hello" {
+		t.Errorf("want hello, got %s", msg.Payload())
+	}
+}
+
+func TestSubscribeDeadLetter(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	topics := make(chan Topic, 10)
+	m.Subscribe("#", func(message *Message) error {
+		topics <- message.Topic()
+		return errors.New("handle failed")
+	})
+	dead := make(chan *Message, 10)
+	m.Subscribe("test.dlq", func(message *Message) error {
+		dead <- message
+		return nil
+	})
+	m.Produce(NewMessage("test", "hello"))
+	// 多次处理失败的消息进入死信主题，只有显式订阅死信主题才会收到
+	if msg := receive(t, dead); msg.Payload() != "hello" {
+		t.Errorf("want hello, got %s", msg.Payload())
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(topics)
+	for topic := range topics {
+		if topic != "test" {
+			t.Errorf("wildcard subscription receive %s", topic)
+		}
+	}
+	if _, err := m.Stats("test.dlq.dlq"); err != ErrTopicNotFound {
+		t.Errorf("want ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestSubscribeConcurrentCreate(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	const n = 50
+	created := make(chan struct{})
+	go func() {
+		defer close(created)
+		for i := 0; i < n; i++ {
+			m.Produce(NewMessage(Topic(fmt.Sprintf("race.%d", i)), "before"))
+		}
+	}()
+	ch := make(chan *Message, 2*n)
+	sub, _ := m.Subscribe("race.*", func(message *Message) error {
+		if message.Payload() == "after" {
+			ch <- message
+		}
+		return nil
+	})
+	defer sub.Close()
+	<-created
+	// 订阅期间新建的主题不会被遗漏，订阅之后发布的消息都能收到
+	for i := 0; i < n; i++ {
+		m.Produce(NewMessage(Topic(fmt.Sprintf("race.%d", i)), "after"))
+	}
+	received := make(map[Topic]bool)
+	for i := 0; i < n; i++ {
+		received[receive(t, ch).Topic()] = true
+	}
+	if len(received) != n {
+		t.Errorf("want %d topics, got %d", n, len(received))
+	}
+}
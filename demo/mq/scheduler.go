@@ -0,0 +1,313 @@
+package mq
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 定时消息到期后生产失败（如topic积压满）时的重试间隔
+const scheduleRetryInterval = time.Second
+
+// scheduledMessage 等待到期的定时消息
+type scheduledMessage struct {
+	id      uint64
+	due     time.Time
+	message *Message
+	index   int
+}
+
+// scheduleHeap 按到期时间排序的小顶堆，到期时间相同时按id排序
+type scheduleHeap []*scheduledMessage
+
+func (h scheduleHeap) Len() int {
+	return len(h)
+}
+
+func (h scheduleHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].id < h[j].id
+	}
+	return h[i].due.Before(h[j].due)
+}
+
+func (h scheduleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduleHeap) Push(x interface{}) {
+	item := x.(*scheduledMessage)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *scheduleHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// scheduler 定时消息调度器，消息到期后才会生产到topic中，对消费者可见
+type scheduler struct {
+	mu      sync.Mutex
+	queue   scheduleHeap
+	nextId  uint64
+	produce func(message *Message) error
+	journal *scheduleJournal // 持久化定时消息，为nil时重启后定时消息丢失
+	err     error            // 写journal失败的第一个错误，close时返回
+	wakeup  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newScheduler(produce func(message *Message) error, journal *scheduleJournal, pending []*scheduledMessage) *scheduler {
+	s := &scheduler{
+		produce: produce,
+		journal: journal,
+		wakeup:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, item := range pending {
+		heap.Push(&s.queue, item)
+		if item.id >= s.nextId {
+			s.nextId = item.id + 1
+		}
+	}
+	go s.run()
+	return s
+}
+
+// schedule 增加定时消息，due时刻到期
+func (s *scheduler) schedule(message *Message, due time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := &scheduledMessage{id: s.nextId, due: due, message: message.clone()}
+	if s.journal != nil {
+		if err := s.journal.add(item); err != nil {
+			return err
+		}
+	}
+	s.nextId++
+	heap.Push(&s.queue, item)
+	s.notify()
+	return nil
+}
+
+// close 停止调度goroutine，未到期的定时消息保留在journal中，重启后继续调度
+// 返回调度期间写journal失败的错误，此时已生产的定时消息重启后可能被重复生产
+func (s *scheduler) close() error {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	if s.journal != nil {
+		if closeErr := s.journal.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) run() {
+	defer close(s.done)
+	for {
+		item, wait := s.next()
+		if item != nil {
+			s.deliver(item)
+			continue
+		}
+		if !s.wait(wait) {
+			return
+		}
+	}
+}
+
+// wait 等待最近的消息到期或有新增的定时消息，wait为0时只等待新增，停止时返回false
+func (s *scheduler) wait(wait time.Duration) bool {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-timeout:
+	case <-s.wakeup:
+	case <-s.stop:
+		return false
+	}
+	return true
+}
+
+// next 取出已到期的消息，没有到期消息时返回距离最近到期的时间，为0表示没有定时消息
+func (s *scheduler) next() (*scheduledMessage, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, 0
+	}
+	if wait := time.Until(s.queue[0].due); wait > 0 {
+		return nil, wait
+	}
+	return heap.Pop(&s.queue).(*scheduledMessage), 0
+}
+
+// deliver 生产到期的消息，失败时稍后重试；主题非法或不存在等无法恢复的错误直接丢弃消息
+func (s *scheduler) deliver(item *scheduledMessage) {
+	err := s.produce(item.message)
+	if err == ErrMqClosed {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil && !isPermanent(err) {
+		item.due = time.Now().Add(scheduleRetryInterval)
+		heap.Push(&s.queue, item)
+		return
+	}
+	if err != nil {
+		fmt.Printf("scheduler drop message %d of topic %s: %s\n", item.id, item.message.Topic(), err.Error())
+	}
+	if s.journal != nil {
+		if err := s.journal.done(item.id); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+}
+
+// isPermanent 重试也无法成功的生产错误
+func isPermanent(err error) bool {
+	return err == ErrInvalidTopic || err == ErrTopicNotFound
+}
+
+// journalRecord 定时消息日志记录，新增定时消息和消息到期各记录一条
+type journalRecord struct {
+	Id      uint64     `json:"id"`
+	Due     int64      `json:"due,omitempty"`
+	Topic   Topic      `json:"topic,omitempty"`
+	Message *logRecord `json:"message,omitempty"`
+	Done    bool       `json:"done,omitempty"`
+}
+
+// scheduleJournal 定时消息的持久化日志，只追加写入，打开时压缩掉已到期的记录
+type scheduleJournal struct {
+	file *os.File
+}
+
+// openScheduleJournal 打开定时消息日志，返回未到期的定时消息
+func openScheduleJournal(path string) (*scheduleJournal, []*scheduledMessage, error) {
+	pending, err := loadScheduleJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 只保留未到期的记录，先写临时文件再重命名
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, nil, err
+	}
+	j := &scheduleJournal{file: tmp}
+	for _, item := range pending {
+		if err := j.add(item); err != nil {
+			tmp.Close()
+			return nil, nil, err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &scheduleJournal{file: file}, pending, nil
+}
+
+func loadScheduleJournal(path string) ([]*scheduledMessage, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	pending := make(map[uint64]*scheduledMessage)
+	var ids []uint64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var record journalRecord
+		// 末尾不完整的记录（写入时进程崩溃）直接忽略
+		if json.Unmarshal(line, &record) != nil {
+			break
+		}
+		if record.Done {
+			delete(pending, record.Id)
+			continue
+		}
+		message, err := record.Message.message(record.Topic)
+		if err != nil {
+			return nil, err
+		}
+		pending[record.Id] = &scheduledMessage{id: record.Id, due: time.Unix(0, record.Due), message: message}
+		ids = append(ids, record.Id)
+	}
+	var result []*scheduledMessage
+	for _, id := range ids {
+		if item, ok := pending[id]; ok {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (j *scheduleJournal) add(item *scheduledMessage) error {
+	record, err := recordOf(0, item.message)
+	if err != nil {
+		return err
+	}
+	return j.write(&journalRecord{Id: item.id, Due: item.due.UnixNano(), Topic: item.message.topic, Message: record})
+}
+
+func (j *scheduleJournal) done(id uint64) error {
+	return j.write(&journalRecord{Id: id, Done: true})
+}
+
+func (j *scheduleJournal) write(record *journalRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+func (j *scheduleJournal) close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,125 @@
+package mq
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProduceAfter(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	m.ProduceAfter(NewMessage("order.timeout", "order-2"), 60*time.Millisecond)
+	m.ProduceAfter(NewMessage("order.timeout", "order-1"), 30*time.Millisecond)
+	// 未到期的消息对消费者不可见
+	if _, err := m.TryConsume("order.timeout"); err != ErrNoMessage {
+		t.Errorf("want ErrNoMessage, got %v", err)
+	}
+	messages, err := m.ConsumeN("order.timeout", 2, time.Second)
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("want 2 messages, got %d, %v", len(messages), err)
+	}
+	// 按到期时间顺序生产
+	if messages[0].Payload() != "order-1" || messages[1].Payload() != "order-2" {
+		t.Errorf("want order-1, order-2, got %s, %s", messages[0].Payload(), messages[1].Payload())
+	}
+}
+
+func TestProduceAtPersistence(t *testing.T) {
+	dir := t.TempDir()
+	fileMq, err := NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMq.ProduceAfter(NewMessage("retry", "due soon"), 20*time.Millisecond)
+	fileMq.ProduceAt(NewMessage("retry", "due later").AddHeader("attempt", "2"), time.Now().Add(150*time.Millisecond))
+	msg, err := fileMq.Consume("retry")
+	if err != nil || msg.Payload() != "due soon" {
+		t.Fatalf("want due soon, got %v, %v", msg, err)
+	}
+	fileMq.Close()
+
+	// 重启后未到期的定时消息继续调度，已生产的不会重复生产
+	fileMq, err = NewFileMq(dir, DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	if _, err := fileMq.TryConsume("retry"); err != ErrNoMessage {
+		t.Errorf("want ErrNoMessage, got %v", err)
+	}
+	messages, err := fileMq.ConsumeN("retry", 2, 500*time.Millisecond)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("want 1 message, got %d, %v", len(messages), err)
+	}
+	if attempt, _ := messages[0].Header("attempt"); messages[0].Payload() != "due later" || attempt != "2" {
+		t.Errorf("want due later with attempt 2, got %s with %s", messages[0].Payload(), attempt)
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	m.ProduceAfter(NewMessage("order.timeout", "order-1"), 30*time.Millisecond)
+	sched := m.sched
+	m.Clear()
+	// Clear后调度goroutine退出，未到期的定时消息被丢弃
+	select {
+	case <-sched.done:
+	default:
+		t.Fatal("want scheduler stopped")
+	}
+	defer m.Clear()
+	m.ProduceAfter(NewMessage("order.timeout", "order-2"), 10*time.Millisecond)
+	messages, err := m.ConsumeN("order.timeout", 2, 100*time.Millisecond)
+	if err != nil || len(messages) != 1 || messages[0].Payload() != "order-2" {
+		t.Errorf("want order-2, got %v, %v", messages, err)
+	}
+}
+
+func TestSchedulerJournalError(t *testing.T) {
+	fileMq, err := NewFileMq(t.TempDir(), DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMq.ProduceAfter(NewMessage("retry", "due soon"), 10*time.Millisecond)
+	// 消息到期后记录journal失败，关闭时返回错误
+	fileMq.sched.journal.file.Close()
+	if msg, err := fileMq.Consume("retry"); err != nil || msg.Payload() != "due soon" {
+		t.Fatalf("want due soon, got %v, %v", msg, err)
+	}
+	if err := fileMq.Close(); err == nil {
+		t.Error("want journal error, got nil")
+	}
+}
+
+func TestSchedulerInvalidTopic(t *testing.T) {
+	m := newMemoryMq(DefaultTopicConfig())
+	defer m.Clear()
+	if err := m.ProduceAfter(NewMessage("../retry", "escape"), time.Second); err != ErrInvalidTopic {
+		t.Errorf("want ErrInvalidTopic, got %v", err)
+	}
+	fileMq, err := NewFileMq(t.TempDir(), DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileMq.Close()
+	if err := fileMq.ProduceAfter(NewMessage("..", "escape"), time.Second); err != ErrInvalidTopic {
+		t.Errorf("want ErrInvalidTopic, got %v", err)
+	}
+
+	// 无法恢复的生产错误不再重试，消息被丢弃
+	var attempts int32
+	sched := newScheduler(func(message *Message) error {
+		atomic.AddInt32(&attempts, 1)
+		return ErrTopicNotFound
+	}, nil, nil)
+	defer sched.close()
+	sched.schedule(NewMessage("deleted", "hello"), time.Now().Add(10*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+	sched.mu.Lock()
+	queued := len(sched.queue)
+	sched.mu.Unlock()
+	if atomic.LoadInt32(&attempts) != 1 || queued != 0 {
+		t.Errorf("want 1 attempt and empty queue, got %d, %d", attempts, queued)
+	}
+}
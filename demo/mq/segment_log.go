@@ -0,0 +1,395 @@
+package mq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	segmentSuffix = ".log"
+	offsetsFile   = "offsets.json"
+)
+
+// payload在logRecord中的编码方式
+const (
+	encodingText   = ""
+	encodingBase64 = "base64"
+	encodingJson   = "json"
+)
+
+// logRecord segment文件中的一条消息记录，每条记录占一行
+type logRecord struct {
+	Offset      int64             `json:"offset"`
+	Timestamp   int64             `json:"timestamp"` // 消息时间，unix纳秒
+	Key         string            `json:"key,omitempty"`
+	Partition   int               `json:"partition"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Payload     json.RawMessage   `json:"payload"`
+}
+
+func recordOf(offset int64, message *Message) (*logRecord, error) {
+	record := &logRecord{
+		Offset:      offset,
+		Key:         message.key,
+		Partition:   message.partition,
+		Headers:     message.headers,
+		ContentType: message.contentType,
+	}
+	if !message.timestamp.IsZero() {
+		record.Timestamp = message.timestamp.UnixNano()
+	}
+	switch message.payload.(type) {
+	case string:
+		record.Encoding = encodingText
+	case []byte:
+		record.Encoding = encodingBase64
+	default:
+		record.Encoding = encodingJson
+	}
+	payload, err := json.Marshal(message.payload)
+	if err != nil {
+		return nil, err
+	}
+	record.Payload = payload
+	return record, nil
+}
+
+func (r *logRecord) message(topic Topic) (*Message, error) {
+	message := &Message{
+		topic:       topic,
+		key:         r.Key,
+		headers:     r.Headers,
+		contentType: r.ContentType,
+		partition:   r.Partition,
+	}
+	if r.Timestamp != 0 {
+		message.timestamp = time.Unix(0, r.Timestamp)
+	}
+	if message.headers == nil {
+		message.headers = make(map[string]string)
+	}
+	switch r.Encoding {
+	case encodingText:
+		var payload string
+		if err := json.Unmarshal(r.Payload, &payload); err != nil {
+			return nil, err
+		}
+		message.payload = payload
+	case encodingBase64:
+		var payload []byte
+		if err := json.Unmarshal(r.Payload, &payload); err != nil {
+			return nil, err
+		}
+		message.payload = payload
+	default:
+		message.payload = r.Payload
+	}
+	return message, nil
+}
+
+// segment 日志分段文件，文件名为第一条消息的offset
+type segment struct {
+	baseOffset    int64
+	path          string
+	file          *os.File
+	positions     []int64 // 每条记录在文件中的起始位置
+	size          int64
+	lastTimestamp int64
+}
+
+func segmentPath(dir string, baseOffset int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", baseOffset, segmentSuffix))
+}
+
+// openSegment 打开segment文件并重建索引，末尾不完整或offset不连续的记录（写入失败时的残留）会被截断
+func openSegment(dir string, baseOffset int64) (*segment, error) {
+	path := segmentPath(dir, baseOffset)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &segment{baseOffset: baseOffset, path: path, file: file}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		var record logRecord
+		if json.Unmarshal(line, &record) != nil || record.Offset != s.endOffset() {
+			break
+		}
+		s.positions = append(s.positions, s.size)
+		s.size += int64(len(line))
+		s.lastTimestamp = record.Timestamp
+	}
+	if err := file.Truncate(s.size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *segment) endOffset() int64 {
+	return s.baseOffset + int64(len(s.positions))
+}
+
+// append 批量写入记录并落盘，失败时截断到写入前的大小，不留下部分写入的记录
+func (s *segment) append(records []*logRecord) error {
+	var buf bytes.Buffer
+	positions := make([]int64, 0, len(records))
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		positions = append(positions, s.size+int64(buf.Len()))
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	_, err := s.file.WriteAt(buf.Bytes(), s.size)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// 截断失败时，残留的数据会被下一次写入覆盖，重启时也会被截断
+		s.file.Truncate(s.size)
+		return err
+	}
+	s.positions = append(s.positions, positions...)
+	s.size += int64(buf.Len())
+	s.lastTimestamp = records[len(records)-1].Timestamp
+	return nil
+}
+
+func (s *segment) read(offset int64) (*logRecord, error) {
+	i := offset - s.baseOffset
+	end := s.size
+	if i+1 < int64(len(s.positions)) {
+		end = s.positions[i+1]
+	}
+	data := make([]byte, end-s.positions[i])
+	if _, err := s.file.ReadAt(data, s.positions[i]); err != nil {
+		return nil, err
+	}
+	record := &logRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *segment) remove() error {
+	s.file.Close()
+	return os.Remove(s.path)
+}
+
+// segmentLog 基于本地文件的messageLog实现，消息追加写入segment文件，超过SegmentBytes后滚动生成新的segment
+// 按照RetentionBytes和RetentionTime删除最老的segment，当前写入的segment不会被删除
+type segmentLog struct {
+	topic    Topic
+	dir      string
+	conf     TopicConfig
+	segments []*segment
+	offsets  map[string]int64
+}
+
+func openSegmentLog(dir string, topic Topic, conf TopicConfig) (*segmentLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var baseOffsets []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		var baseOffset int64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, segmentSuffix), "%d", &baseOffset); err != nil {
+			continue
+		}
+		baseOffsets = append(baseOffsets, baseOffset)
+	}
+	sort.Slice(baseOffsets, func(i, j int) bool { return baseOffsets[i] < baseOffsets[j] })
+	if len(baseOffsets) == 0 {
+		baseOffsets = append(baseOffsets, 0)
+	}
+	l := &segmentLog{topic: topic, dir: dir, conf: conf, offsets: make(map[string]int64)}
+	for _, baseOffset := range baseOffsets {
+		s, err := openSegment(dir, baseOffset)
+		if err != nil {
+			l.close()
+			return nil, err
+		}
+		l.segments = append(l.segments, s)
+	}
+	if err := l.loadOffsets(); err != nil {
+		l.close()
+		return nil, err
+	}
+	if err := l.retain(); err != nil {
+		l.close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *segmentLog) active() *segment {
+	return l.segments[len(l.segments)-1]
+}
+
+// append 一批消息写入同一个segment并在返回前落盘，返回成功后进程或系统崩溃都不会丢失这批消息
+// 落盘完成前崩溃时，这批消息可能只有前面的一部分保存了下来，此时生产者没有收到成功的返回
+func (l *segmentLog) append(messages []*Message) (int64, error) {
+	offset := l.endOffset()
+	records := make([]*logRecord, 0, len(messages))
+	for i, message := range messages {
+		record, err := recordOf(offset+int64(i), message)
+		if err != nil {
+			return 0, err
+		}
+		records = append(records, record)
+	}
+	if err := l.active().append(records); err != nil {
+		return 0, err
+	}
+	l.roll()
+	return offset, nil
+}
+
+// roll 当前segment超过SegmentBytes时滚动生成新的segment，并按保留策略删除老的segment
+// 此时消息已经写入成功，失败只打印日志，下一次追加时会重试
+func (l *segmentLog) roll() {
+	if l.conf.SegmentBytes > 0 && l.active().size >= l.conf.SegmentBytes {
+		s, err := openSegment(l.dir, l.endOffset())
+		if err != nil {
+			fmt.Printf("topic %s roll segment failed: %s\n", l.topic, err)
+		} else {
+			l.segments = append(l.segments, s)
+		}
+	}
+	if err := l.retain(); err != nil {
+		fmt.Printf("topic %s retain segments failed: %s\n", l.topic, err)
+	}
+}
+
+func (l *segmentLog) read(offset int64) (*Message, error) {
+	if offset < l.startOffset() || offset >= l.endOffset() {
+		return nil, fmt.Errorf("offset %d out of range [%d, %d)", offset, l.startOffset(), l.endOffset())
+	}
+	i := sort.Search(len(l.segments), func(i int) bool { return l.segments[i].endOffset() > offset })
+	record, err := l.segments[i].read(offset)
+	if err != nil {
+		return nil, err
+	}
+	return record.message(l.topic)
+}
+
+func (l *segmentLog) startOffset() int64 {
+	return l.segments[0].baseOffset
+}
+
+func (l *segmentLog) endOffset() int64 {
+	return l.active().endOffset()
+}
+
+// commit 保存消费位置
+func (l *segmentLog) commit(consumer string, offset int64) error {
+	l.offsets[consumer] = offset
+	return l.saveOffsets()
+}
+
+func (l *segmentLog) remove(consumer string) error {
+	if _, ok := l.offsets[consumer]; !ok {
+		return nil
+	}
+	delete(l.offsets, consumer)
+	return l.saveOffsets()
+}
+
+// saveOffsets 先写临时文件再重命名，避免崩溃时文件损坏
+func (l *segmentLog) saveOffsets() error {
+	data, err := json.Marshal(l.offsets)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(l.dir, offsetsFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// trim 持久化日志只按照保留策略删除消息，与消费位置无关
+func (l *segmentLog) trim(offset int64) {
+}
+
+func (l *segmentLog) committed() map[string]int64 {
+	offsets := make(map[string]int64, len(l.offsets))
+	for consumer, offset := range l.offsets {
+		offsets[consumer] = offset
+	}
+	return offsets
+}
+
+func (l *segmentLog) close() error {
+	var result error
+	for _, s := range l.segments {
+		if err := s.file.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+func (l *segmentLog) loadOffsets() error {
+	data, err := os.ReadFile(filepath.Join(l.dir, offsetsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &l.offsets)
+}
+
+// retain 按照保留策略删除最老的segment
+func (l *segmentLog) retain() error {
+	var total int64
+	for _, s := range l.segments {
+		total += s.size
+	}
+	for len(l.segments) > 1 {
+		oldest := l.segments[0]
+		expired := l.conf.RetentionTime > 0 &&
+			time.Since(time.Unix(0, oldest.lastTimestamp)) > l.conf.RetentionTime
+		oversize := l.conf.RetentionBytes > 0 && total > l.conf.RetentionBytes
+		if !expired && !oversize {
+			return nil
+		}
+		if err := oldest.remove(); err != nil {
+			return err
+		}
+		total -= oldest.size
+		l.segments = l.segments[1:]
+	}
+	return nil
+}
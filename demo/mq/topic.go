@@ -0,0 +1,598 @@
+package mq
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// messageLog 主题的消息日志，消息按offset顺序追加，不同的存储方式有不同的实现
+type messageLog interface {
+	// append 追加一批消息，返回第一条消息的offset，一批内的消息要么全部追加成功，要么全部失败
+	append(messages []*Message) (int64, error)
+	// read 读取offset处的消息
+	read(offset int64) (*Message, error)
+	// startOffset 第一条可读消息的offset，保留策略删除消息后会增大
+	startOffset() int64
+	// endOffset 下一条追加消息的offset
+	endOffset() int64
+	// commit 保存消费者的消费位置
+	commit(consumer string, offset int64) error
+	// committed 返回所有消费者已保存的消费位置
+	committed() map[string]int64
+	// remove 删除消费者的消费位置
+	remove(consumer string) error
+	// trim 通知日志所有消费者都已消费完offset之前的消息，日志可以按需释放
+	trim(offset int64)
+	close() error
+}
+
+// 点对点消费模式下的默认消费者
+const defaultConsumer = ""
+
+// topic 基于messageLog实现的主题，负责消费位置的维护，以及生产者、消费者的阻塞与唤醒
+type topic struct {
+	name       Topic
+	conf       TopicConfig
+	log        messageLog
+	mu         sync.Mutex
+	cursor     int64 // 默认消费者的消费位置
+	hasCursor  bool  // 默认消费者是否消费过
+	groups     map[string]*consumerGroup
+	roundRobin int // 没有key的消息的分区计数器
+	isClosed   bool
+	produced   chan struct{} // 有新消息、需要重新投递的消息或分区解除阻塞时关闭并重建，用于唤醒阻塞的消费者
+	consumed   chan struct{} // 有消息被消费时关闭并重建，用于唤醒阻塞的生产者
+	deadLetter func(message *Message) error
+	// 生产、消费速率统计，消费组的消息在Ack时计为消费
+	producedRate rateMeter
+	consumedRate rateMeter
+}
+
+func newTopic(name Topic, conf TopicConfig, log messageLog) *topic {
+	t := &topic{
+		name:     name,
+		conf:     conf,
+		log:      log,
+		groups:   make(map[string]*consumerGroup),
+		produced: make(chan struct{}),
+		consumed: make(chan struct{}),
+	}
+	for consumer, offset := range log.committed() {
+		if consumer == defaultConsumer {
+			t.cursor, t.hasCursor = offset, true
+			continue
+		}
+		// 订阅使用的临时消费组不需要恢复
+		if strings.HasPrefix(consumer, subscriptionGroupPrefix) {
+			continue
+		}
+		t.groups[consumer] = newConsumerGroup(consumer, offset)
+	}
+	return t
+}
+
+// produce 按顺序追加消息，积压不足以容纳所有消息时按照ProduceTimeout阻塞等待
+func (t *topic) produce(messages ...*Message) error {
+	return t.produceContext(context.Background(), messages...)
+}
+
+// produceContext 积压满时最多等待到ctx结束或ProduceTimeout
+func (t *topic) produceContext(ctx context.Context, messages ...*Message) error {
+	var timeout <-chan time.Time
+	for {
+		t.mu.Lock()
+		if t.isClosed {
+			t.mu.Unlock()
+			return ErrMqClosed
+		}
+		if t.conf.Capacity <= 0 || t.backlog()+int64(len(messages)) <= int64(t.conf.Capacity) {
+			err := t.append(messages)
+			t.mu.Unlock()
+			return err
+		}
+		consumed := t.consumed
+		t.mu.Unlock()
+		if t.conf.ProduceTimeout <= 0 || len(messages) > t.conf.Capacity {
+			return ErrTopicFull
+		}
+		if timeout == nil {
+			timeout = time.After(t.conf.ProduceTimeout)
+		}
+		select {
+		case <-consumed:
+		case <-timeout:
+			return ErrTopicFull
+		case <-ctx.Done():
+			return ErrTopicFull
+		}
+	}
+}
+
+// append 追加消息，填充消息的生产时间和分区
+func (t *topic) append(messages []*Message) error {
+	stored := make([]*Message, 0, len(messages))
+	for _, message := range messages {
+		m := message.clone()
+		m.topic = t.name
+		if m.timestamp.IsZero() {
+			m.timestamp = time.Now()
+		}
+		m.partition = t.partitionOf(m)
+		stored = append(stored, m)
+	}
+	if _, err := t.log.append(stored); err != nil {
+		return err
+	}
+	t.producedRate.mark(len(stored), time.Now())
+	broadcast(&t.produced)
+	return nil
+}
+
+// partitionOf key相同的消息分配到同一分区，没有key时轮流分配
+func (t *topic) partitionOf(message *Message) int {
+	if t.conf.Partitions <= 1 {
+		return 0
+	}
+	if message.key == "" {
+		t.roundRobin++
+		return t.roundRobin % t.conf.Partitions
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(message.key))
+	return int(hash.Sum32() % uint32(t.conf.Partitions))
+}
+
+// consume 默认消费者消费一条消息，没有消息时阻塞等待，直到ctx结束
+func (t *topic) consume(ctx context.Context) (*Message, error) {
+	messages, err := t.consumeN(ctx, 1)
+	if len(messages) == 0 {
+		return nil, err
+	}
+	return messages[0], err
+}
+
+// tryConsume 默认消费者消费一条消息，没有消息时返回ErrNoMessage
+func (t *topic) tryConsume() (*Message, error) {
+	messages, _, err := t.take(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrNoMessage
+	}
+	return messages[0], nil
+}
+
+// consumeN 默认消费者消费n条消息，不足n条时阻塞等待，ctx结束时返回已消费的消息
+func (t *topic) consumeN(ctx context.Context, n int) ([]*Message, error) {
+	var messages []*Message
+	for {
+		batch, produced, err := t.take(n - len(messages))
+		messages = append(messages, batch...)
+		if err != nil || len(messages) >= n {
+			return messages, err
+		}
+		select {
+		case <-produced:
+		case <-ctx.Done():
+			return messages, ctx.Err()
+		}
+	}
+}
+
+// consumeWithin 默认消费者在wait时间内消费n条消息，超时时返回已消费的消息
+func (t *topic) consumeWithin(n int, wait time.Duration) ([]*Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	messages, err := t.consumeN(ctx, n)
+	if err == context.DeadlineExceeded {
+		return messages, nil
+	}
+	return messages, err
+}
+
+// take 默认消费者按顺序读取最多n条消息，读取即提交，同时返回用于等待新消息的channel
+func (t *topic) take(n int) ([]*Message, <-chan struct{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.isClosed {
+		return nil, t.produced, ErrMqClosed
+	}
+	t.hasCursor = true
+	if t.cursor < t.log.startOffset() {
+		t.cursor = t.log.startOffset()
+	}
+	var messages []*Message
+	for len(messages) < n && t.cursor < t.log.endOffset() {
+		message, err := t.log.read(t.cursor)
+		if err != nil {
+			return messages, t.produced, err
+		}
+		messages = append(messages, message)
+		t.cursor++
+	}
+	if len(messages) == 0 {
+		return nil, t.produced, nil
+	}
+	t.consumedRate.mark(len(messages), time.Now())
+	err := t.log.commit(defaultConsumer, t.cursor)
+	t.log.trim(t.lowWatermark())
+	broadcast(&t.consumed)
+	return messages, t.produced, err
+}
+
+// consumeGroup 消费组消费消息，优先重新投递超时或Nack的消息，没有可投递的消息时阻塞等待，直到ctx结束
+func (t *topic) consumeGroup(ctx context.Context, name string) (*Delivery, error) {
+	for {
+		t.mu.Lock()
+		group := t.joinGroup(name, t.log.startOffset())
+		delivery, deadLetters, wait, err := t.poll(group)
+		produced := t.produced
+		t.mu.Unlock()
+		t.sendDeadLetters(deadLetters)
+		if delivery != nil || err != nil {
+			return delivery, err
+		}
+		if wait <= 0 {
+			select {
+			case <-produced:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-produced:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// close 关闭日志，并唤醒所有阻塞的生产者和消费者
+func (t *topic) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.isClosed {
+		return nil
+	}
+	t.isClosed = true
+	broadcast(&t.produced)
+	broadcast(&t.consumed)
+	return t.log.close()
+}
+
+// joinGroup 返回消费组，不存在时创建，新消费组从offset开始消费
+func (t *topic) joinGroup(name string, offset int64) *consumerGroup {
+	group, ok := t.groups[name]
+	if !ok {
+		group = newConsumerGroup(name, offset)
+		t.groups[name] = group
+	}
+	return group
+}
+
+// leaveGroup 删除消费组及其消费位置，未Ack的消息不再投递
+func (t *topic) leaveGroup(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.groups, name)
+	err := t.log.remove(name)
+	t.log.trim(t.lowWatermark())
+	broadcast(&t.consumed)
+	return err
+}
+
+// deleteGroup 删除已存在的消费组，不存在时返回ErrGroupNotFound
+func (t *topic) deleteGroup(name string) error {
+	t.mu.Lock()
+	_, ok := t.groups[name]
+	t.mu.Unlock()
+	if !ok {
+		return ErrGroupNotFound
+	}
+	return t.leaveGroup(name)
+}
+
+// poll 查找消费组下一条可投递的消息，没有时返回距离最近一条消息可见的等待时间，为0表示只能等待新消息
+func (t *topic) poll(group *consumerGroup) (*Delivery, []*Message, time.Duration, error) {
+	if t.isClosed {
+		return nil, nil, 0, ErrMqClosed
+	}
+	group.skipTo(t.log.startOffset())
+	now := time.Now()
+	var deadLetters []*Message
+	var wait time.Duration
+	for _, offset := range group.inflightOffsets() {
+		record := group.inflight[offset]
+		if record.deadline.IsZero() {
+			continue
+		}
+		if left := record.deadline.Sub(now); left > 0 {
+			if wait == 0 || left < wait {
+				wait = left
+			}
+			continue
+		}
+		message, err := t.log.read(offset)
+		if err != nil {
+			return nil, deadLetters, 0, err
+		}
+		if t.isExhausted(record) {
+			deadLetters = append(deadLetters, message)
+			if err := t.ack(group, offset); err != nil {
+				return nil, deadLetters, 0, err
+			}
+			continue
+		}
+		record.attempts++
+		record.deadline = t.deadline(now)
+		return t.delivery(group, offset, message, record.attempts), deadLetters, 0, nil
+	}
+	// 同一分区的前一条消息未Ack时，该分区后续的消息需要等待，保证分区内顺序投递，其他分区的消息继续投递
+	blocked := group.blockedPartitions()
+	for offset := group.next; offset < t.log.endOffset(); offset++ {
+		if group.isDelivered(offset) {
+			continue
+		}
+		message, err := t.log.read(offset)
+		if err != nil {
+			return nil, deadLetters, 0, err
+		}
+		if blocked[message.partition] {
+			continue
+		}
+		group.inflight[offset] = &inflightMessage{
+			attempts:  1,
+			deadline:  t.deadline(now),
+			partition: message.partition,
+		}
+		group.advance()
+		return t.delivery(group, offset, message, 1), deadLetters, 0, nil
+	}
+	return nil, deadLetters, wait, nil
+}
+
+func (t *topic) delivery(group *consumerGroup, offset int64, message *Message, attempts int) *Delivery {
+	ack := func() error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.ack(group, offset)
+	}
+	nack := func() error {
+		return t.nack(group, offset)
+	}
+	return NewDelivery(message, group.name, offset, attempts, ack, nack)
+}
+
+// deadline 消息的可见性超时时间，VisibilityTimeout为0时不会超时
+func (t *topic) deadline(now time.Time) time.Time {
+	if t.conf.VisibilityTimeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(t.conf.VisibilityTimeout)
+}
+
+// isExhausted 消息的投递次数是否已达上限
+func (t *topic) isExhausted(record *inflightMessage) bool {
+	return t.conf.MaxDeliveries > 0 && record.attempts >= t.conf.MaxDeliveries
+}
+
+// ack 确认消息，并推进消费组的提交位置
+func (t *topic) ack(group *consumerGroup, offset int64) error {
+	if t.isClosed {
+		return ErrMqClosed
+	}
+	_, isInflight := group.inflight[offset]
+	advanced := group.ack(offset)
+	if isInflight {
+		t.consumedRate.mark(1, time.Now())
+		// 分区解除阻塞，唤醒等待的消费者
+		broadcast(&t.produced)
+	}
+	if !advanced {
+		return nil
+	}
+	err := t.log.commit(group.name, group.committed)
+	t.log.trim(t.lowWatermark())
+	broadcast(&t.consumed)
+	return err
+}
+
+// nack 处理失败，消息立即重新投递，投递次数达上限时投递到死信主题
+func (t *topic) nack(group *consumerGroup, offset int64) error {
+	t.mu.Lock()
+	if t.isClosed {
+		t.mu.Unlock()
+		return ErrMqClosed
+	}
+	record, ok := group.inflight[offset]
+	if !ok {
+		t.mu.Unlock()
+		return nil
+	}
+	if !t.isExhausted(record) {
+		record.deadline = time.Now()
+		broadcast(&t.produced)
+		t.mu.Unlock()
+		return nil
+	}
+	message, err := t.log.read(offset)
+	if err == nil {
+		err = t.ack(group, offset)
+	}
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return t.sendDeadLetters([]*Message{message})
+}
+
+func (t *topic) sendDeadLetters(messages []*Message) error {
+	if t.deadLetter == nil {
+		return nil
+	}
+	for _, message := range messages {
+		dead := message.clone()
+		dead.topic = t.name + DeadLetterSuffix
+		if err := t.deadLetter(dead); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backlog 未被所有消费者消费的消息数
+func (t *topic) backlog() int64 {
+	return t.log.endOffset() - t.lowWatermark()
+}
+
+// lowWatermark 所有消费者中最小的消费位置，没有消费者时为第一条可读消息
+func (t *topic) lowWatermark() int64 {
+	start := t.log.startOffset()
+	if !t.hasCursor && len(t.groups) == 0 {
+		return start
+	}
+	low := t.log.endOffset()
+	if t.hasCursor && t.cursor < low {
+		low = t.cursor
+	}
+	for _, group := range t.groups {
+		if group.committed < low {
+			low = group.committed
+		}
+	}
+	if low < start {
+		return start
+	}
+	return low
+}
+
+// produceBatch 将消息按topic分批，相邻的同topic消息一次追加
+func produceBatch(ctx context.Context, messages []*Message, topicOf func(name Topic) (*topic, error)) error {
+	for start := 0; start < len(messages); {
+		end := start + 1
+		for end < len(messages) && messages[end].Topic() == messages[start].Topic() {
+			end++
+		}
+		t, err := topicOf(messages[start].Topic())
+		if err != nil {
+			return err
+		}
+		if err := t.produceContext(ctx, messages[start:end]...); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// broadcast 关闭并重建channel，唤醒所有等待者
+func broadcast(ch *chan struct{}) {
+	close(*ch)
+	*ch = make(chan struct{})
+}
+
+// inflightMessage 已投递但未Ack的消息
+type inflightMessage struct {
+	attempts  int
+	deadline  time.Time // 超过deadline未Ack时重新投递，为零值时不会超时
+	partition int
+}
+
+// consumerGroup 消费组的消费状态
+type consumerGroup struct {
+	name      string
+	committed int64 // 小于committed的消息都已Ack
+	next      int64 // 小于next的消息都已投递过，其他分区不被阻塞时，next之后的消息可能先投递
+	inflight  map[int64]*inflightMessage
+	acked     map[int64]bool // 已Ack但大于等于committed的消息
+}
+
+func newConsumerGroup(name string, committed int64) *consumerGroup {
+	return &consumerGroup{
+		name:      name,
+		committed: committed,
+		next:      committed,
+		inflight:  make(map[int64]*inflightMessage),
+		acked:     make(map[int64]bool),
+	}
+}
+
+// skipTo 跳过已被保留策略删除的消息
+func (g *consumerGroup) skipTo(start int64) {
+	if g.committed >= start {
+		return
+	}
+	for offset := range g.inflight {
+		if offset < start {
+			delete(g.inflight, offset)
+		}
+	}
+	for offset := range g.acked {
+		if offset < start {
+			delete(g.acked, offset)
+		}
+	}
+	g.committed = start
+	if g.next < start {
+		g.next = start
+	}
+}
+
+func (g *consumerGroup) inflightOffsets() []int64 {
+	offsets := make([]int64, 0, len(g.inflight))
+	for offset := range g.inflight {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets
+}
+
+// blockedPartitions 有未Ack消息的分区
+func (g *consumerGroup) blockedPartitions() map[int]bool {
+	blocked := make(map[int]bool)
+	for _, record := range g.inflight {
+		blocked[record.partition] = true
+	}
+	return blocked
+}
+
+// isDelivered 消息是否已经投递过，已投递的消息在Ack前由inflight重新投递
+func (g *consumerGroup) isDelivered(offset int64) bool {
+	if offset < g.committed {
+		return true
+	}
+	_, isInflight := g.inflight[offset]
+	return isInflight || g.acked[offset]
+}
+
+// advance 跳过已经投递过的消息，推进下一条首次投递的位置
+func (g *consumerGroup) advance() {
+	for g.isDelivered(g.next) {
+		g.next++
+	}
+}
+
+// ack 确认消息，返回提交位置是否推进
+func (g *consumerGroup) ack(offset int64) bool {
+	delete(g.inflight, offset)
+	if offset < g.committed {
+		return false
+	}
+	g.acked[offset] = true
+	committed := g.committed
+	for g.acked[g.committed] {
+		delete(g.acked, g.committed)
+		g.committed++
+	}
+	return g.committed != committed
+}
@@ -0,0 +1,38 @@
+package mq
+
+import "time"
+
+// DeadLetterSuffix 死信主题的后缀，消费组多次处理失败的消息会被投递到topic+DeadLetterSuffix
+const DeadLetterSuffix = ".dlq"
+
+// TopicConfig 主题配置
+type TopicConfig struct {
+	// Capacity 主题最多积压的未消费消息数，为0时不限制
+	Capacity int
+	// ProduceTimeout 主题积压满时，生产者最多阻塞等待的时间，为0时立即返回ErrTopicFull
+	ProduceTimeout time.Duration
+	// SegmentBytes 持久化主题单个segment文件的大小上限，超过后滚动生成新的segment
+	SegmentBytes int64
+	// RetentionBytes 持久化主题最多保留的数据量，超过后删除最老的segment，为0时不限制
+	RetentionBytes int64
+	// RetentionTime 持久化主题中消息最长保留时间，超过后删除最老的segment，为0时不限制
+	RetentionTime time.Duration
+	// VisibilityTimeout 消费组投递的消息在该时间内未Ack时，会被重新投递
+	VisibilityTimeout time.Duration
+	// MaxDeliveries 消费组中消息的最大投递次数，超过后投递到死信主题，为0时不限制
+	MaxDeliveries int
+	// Partitions 分区数，key相同的消息分配到同一分区，没有key的消息轮流分配，为0时只有一个分区
+	// 消费组中同一分区的消息按顺序投递，前一条消息Ack后才会投递下一条
+	Partitions int
+}
+
+func DefaultTopicConfig() TopicConfig {
+	return TopicConfig{
+		Capacity:          10000,
+		ProduceTimeout:    3 * time.Second,
+		SegmentBytes:      1024 * 1024,
+		VisibilityTimeout: 30 * time.Second,
+		MaxDeliveries:     3,
+		Partitions:        8,
+	}
+}
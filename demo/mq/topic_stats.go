@@ -0,0 +1,101 @@
+package mq
+
+import (
+	"sort"
+	"time"
+)
+
+// TopicStats 主题的统计信息
+type TopicStats struct {
+	Name   Topic
+	Config TopicConfig
+	// Depth 积压深度，即未被所有消费者消费的消息数
+	Depth       int64
+	StartOffset int64
+	EndOffset   int64
+	// ProduceRate、ConsumeRate 最近rateWindow秒内平均每秒生产、消费的消息数
+	ProduceRate float64
+	ConsumeRate float64
+	// Lag 点对点模式下默认消费者未消费的消息数，没有消费过时为-1
+	Lag    int64
+	Groups []*GroupStats
+}
+
+// GroupStats 消费组的统计信息
+type GroupStats struct {
+	Name      string
+	Committed int64
+	// Lag 消费组未Ack的消息数，包括已投递未Ack的消息
+	Lag      int64
+	Inflight int
+}
+
+// 计算速率的时间窗口，单位为秒
+const rateWindow = 10
+
+// rateMeter 按秒分桶的计数器，用于统计最近rateWindow秒的平均速率，非并发安全
+type rateMeter struct {
+	counts  [rateWindow]int64
+	seconds [rateWindow]int64 // 每个桶对应的unix秒
+}
+
+func (r *rateMeter) mark(n int, now time.Time) {
+	second := now.Unix()
+	i := second % rateWindow
+	if r.seconds[i] != second {
+		r.seconds[i] = second
+		r.counts[i] = 0
+	}
+	r.counts[i] += int64(n)
+}
+
+func (r *rateMeter) rate(now time.Time) float64 {
+	second := now.Unix()
+	var total int64
+	for i := range r.counts {
+		if age := second - r.seconds[i]; age >= 0 && age < rateWindow {
+			total += r.counts[i]
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// stats 主题当前的统计信息
+func (t *topic) stats() *TopicStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	end := t.log.endOffset()
+	stats := &TopicStats{
+		Name:        t.name,
+		Config:      t.conf,
+		Depth:       t.backlog(),
+		StartOffset: t.log.startOffset(),
+		EndOffset:   end,
+		ProduceRate: t.producedRate.rate(now),
+		ConsumeRate: t.consumedRate.rate(now),
+		Lag:         -1,
+	}
+	if t.hasCursor {
+		stats.Lag = end - t.cursor
+		if stats.Lag < 0 {
+			stats.Lag = 0
+		}
+	}
+	for _, group := range t.groups {
+		stats.Groups = append(stats.Groups, &GroupStats{
+			Name:      group.name,
+			Committed: group.committed,
+			Lag:       end - group.committed,
+			Inflight:  len(group.inflight),
+		})
+	}
+	sort.Slice(stats.Groups, func(i, j int) bool { return stats.Groups[i].Name < stats.Groups[j].Name })
+	return stats
+}
+
+// sortStats 按照主题名称排序
+func sortStats(stats []*TopicStats) []*TopicStats {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
@@ -0,0 +1,116 @@
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+观察者模式
+*/
+
+// PacketHook 网络报文钩子，network每发送一个报文都会回调，配置了拓扑时只回调路由成功的报文，地址为NAT转换后的地址
+type PacketHook interface {
+	OnPacket(packet *Packet)
+}
+
+// Summarizer 报文负载可实现该接口，用于在抓包记录中输出简要信息
+type Summarizer interface {
+	Summary() string
+}
+
+// CaptureRecord 抓包记录，以JSON Lines的形式保存，每行一条记录
+type CaptureRecord struct {
+	Timestamp   time.Time       `json:"timestamp"`
+	Src         Endpoint        `json:"src"`
+	Dest        Endpoint        `json:"dest"`
+	PayloadType string          `json:"payloadType"`
+	Summary     string          `json:"summary"`
+	Payload     json.RawMessage `json:"payload,omitempty"` // 负载可序列化为JSON时保存，用于回放
+}
+
+// PacketCapture 抓包器，将报文记录写入writer中
+type PacketCapture struct {
+	mu      sync.Mutex
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+func NewPacketCapture(writer io.Writer) *PacketCapture {
+	return &PacketCapture{
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+// NewFileCapture 将抓包记录写入path文件中，文件已存在时追加写
+func NewFileCapture(path string) (*PacketCapture, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewPacketCapture(file), nil
+}
+
+func (p *PacketCapture) OnPacket(packet *Packet) {
+	record := CaptureRecord{
+		Timestamp:   time.Now(),
+		Src:         packet.Src(),
+		Dest:        packet.Dest(),
+		PayloadType: fmt.Sprintf("%T", packet.Payload()),
+	}
+	if summarizer, ok := packet.Payload().(Summarizer); ok {
+		record.Summary = summarizer.Summary()
+	} else {
+		record.Summary = fmt.Sprintf("%v", packet.Payload())
+	}
+	if payload, err := json.Marshal(packet.Payload()); err == nil {
+		record.Payload = payload
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.encoder.Encode(record); err != nil {
+		fmt.Printf("capture packet failed: %s\n", err.Error())
+	}
+}
+
+// Close 关闭底层的writer，如文件
+func (p *PacketCapture) Close() error {
+	if closer, ok := p.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ReadCapture 从reader中读取抓包记录
+func ReadCapture(reader io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// ReadCaptureFile 从path文件中读取抓包记录
+func ReadCaptureFile(path string) ([]CaptureRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCapture(file)
+}
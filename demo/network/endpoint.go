@@ -1,6 +1,10 @@
 package network
 
-import "strconv"
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
 
 // Endpoint 值对象，其中ip和port属性为不可变，如果需要变更，需要整对象替换
 type Endpoint struct {
@@ -35,3 +39,23 @@ func (e Endpoint) Port() int {
 func (e Endpoint) String() string {
 	return e.ip + ":" + strconv.Itoa(e.port)
 }
+
+// MarshalText 序列化为ip:port的形式
+func (e Endpoint) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText 从ip:port的形式反序列化
+func (e *Endpoint) UnmarshalText(text []byte) error {
+	idx := strings.LastIndex(string(text), ":")
+	if idx < 0 {
+		return errors.New("invalid endpoint " + string(text))
+	}
+	port, err := strconv.Atoi(string(text[idx+1:]))
+	if err != nil {
+		return err
+	}
+	e.ip = string(text[:idx])
+	e.port = port
+	return nil
+}
@@ -5,4 +5,7 @@ import "errors"
 var (
 	ErrEndpointAlreadyListened = errors.New("endpoint already listened")
 	ErrConnectionRefuse        = errors.New("connection refuse")
+	ErrNetworkUnreachable      = errors.New("network unreachable")
+	ErrAclDenied               = errors.New("denied by acl")
+	ErrReceiveQueueFull        = errors.New("receive queue is full")
 )
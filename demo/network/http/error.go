@@ -0,0 +1,11 @@
+package http
+
+import "errors"
+
+var (
+	ErrCircuitBreakerOpen  = errors.New("circuit breaker is open")
+	ErrClientPortExhausted = errors.New("no available client port")
+	ErrStreamTimeout       = errors.New("stream timeout")
+	ErrStreamClosed        = errors.New("stream closed")
+	ErrShutdownTimeout     = errors.New("server graceful shutdown timeout")
+)
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// body类型注册表，key为类型名称，value为reflect.Type，用于抓包回放时将body反序列化为原始类型
+var bodyTypes sync.Map
+
+// RegisterBodyType 注册Request/Response body的类型，如http.RegisterBodyType(new(model.ServiceProfile))
+func RegisterBodyType(body interface{}) {
+	bodyTypes.Store(fmt.Sprintf("%T", body), reflect.TypeOf(body))
+}
+
+func encodeBody(body interface{}) (string, json.RawMessage) {
+	if body == nil {
+		return "", nil
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprintf("%T", body), nil
+	}
+	return fmt.Sprintf("%T", body), raw
+}
+
+func decodeBody(bodyType string, raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	record, ok := bodyTypes.Load(bodyType)
+	if !ok {
+		var body interface{}
+		err := json.Unmarshal(raw, &body)
+		return body, err
+	}
+	typ := record.(reflect.Type)
+	if typ.Kind() == reflect.Ptr {
+		body := reflect.New(typ.Elem())
+		err := json.Unmarshal(raw, body.Interface())
+		return body.Interface(), err
+	}
+	body := reflect.New(typ)
+	err := json.Unmarshal(raw, body.Interface())
+	return body.Elem().Interface(), err
+}
+
+type requestJson struct {
+	ReqId       ReqId             `json:"reqId"`
+	Method      Method            `json:"method"`
+	Uri         Uri               `json:"uri"`
+	QueryParams map[string]string `json:"queryParams,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	BodyType    string            `json:"bodyType,omitempty"`
+	Body        json.RawMessage   `json:"body,omitempty"`
+}
+
+func (r *Request) MarshalJSON() ([]byte, error) {
+	bodyType, body := encodeBody(r.body)
+	return json.Marshal(requestJson{
+		ReqId:       r.reqId,
+		Method:      r.method,
+		Uri:         r.uri,
+		QueryParams: r.queryParams,
+		Headers:     r.headers,
+		BodyType:    bodyType,
+		Body:        body,
+	})
+}
+
+func (r *Request) UnmarshalJSON(data []byte) error {
+	var rj requestJson
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	body, err := decodeBody(rj.BodyType, rj.Body)
+	if err != nil {
+		return err
+	}
+	*r = *EmptyRequest()
+	r.reqId = rj.ReqId
+	r.AddMethod(rj.Method).AddUri(rj.Uri).AddQueryParams(rj.QueryParams).
+		AddHeaders(rj.Headers).AddBody(body)
+	return nil
+}
+
+// Summary 抓包记录中的简要信息
+func (r *Request) Summary() string {
+	return fmt.Sprintf("%s %s", r.method, r.uri)
+}
+
+type responseJson struct {
+	ReqId          ReqId             `json:"reqId"`
+	StatusCode     StatusCode        `json:"statusCode"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	BodyType       string            `json:"bodyType,omitempty"`
+	Body           json.RawMessage   `json:"body,omitempty"`
+	ProblemDetails string            `json:"problemDetails,omitempty"`
+}
+
+func (r *Response) MarshalJSON() ([]byte, error) {
+	bodyType, body := encodeBody(r.body)
+	return json.Marshal(responseJson{
+		ReqId:          r.reqId,
+		StatusCode:     r.statusCode,
+		Headers:        r.headers,
+		BodyType:       bodyType,
+		Body:           body,
+		ProblemDetails: r.problemDetails,
+	})
+}
+
+func (r *Response) UnmarshalJSON(data []byte) error {
+	var rj responseJson
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	body, err := decodeBody(rj.BodyType, rj.Body)
+	if err != nil {
+		return err
+	}
+	*r = *ResponseOfId(rj.ReqId)
+	r.AddStatusCode(rj.StatusCode).AddHeaders(rj.Headers).AddBody(body).
+		AddProblemDetails(rj.ProblemDetails)
+	return nil
+}
+
+// Summary 抓包记录中的简要信息
+func (r *Response) Summary() string {
+	return fmt.Sprintf("%d %s", r.statusCode.Code, r.statusCode.Details)
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"demo/network"
+	"sync"
+	"time"
+)
+
+/*
+状态模式
+*/
+
+// BreakerState 熔断器状态
+type BreakerState uint8
+
+const (
+	BreakerClosed   BreakerState = iota // 关闭状态，正常放行请求
+	BreakerOpen                         // 打开状态，拒绝所有请求
+	BreakerHalfOpen                     // 半开状态，只放行一个探测请求
+)
+
+func (b BreakerState) String() string {
+	switch b {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker 熔断器，连续失败failureThreshold次后打开，经过openTimeout后进入半开状态进行探测，
+// 探测成功则关闭，失败则重新打开
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	state            BreakerState
+	failures         int
+	failureThreshold int
+	openTimeout      time.Duration
+	openedAt         time.Time
+	probing          bool
+	onStateChange    func(from, to BreakerState)
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		state:            BreakerClosed,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+}
+
+func (c *CircuitBreaker) State() BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Allow 判断当前是否放行请求
+func (c *CircuitBreaker) Allow() bool {
+	c.mu.Lock()
+	from := c.state
+	allow := c.allow()
+	to := c.state
+	c.mu.Unlock()
+	c.notify(from, to)
+	return allow
+}
+
+// OnSuccess 记录一次成功的请求
+func (c *CircuitBreaker) OnSuccess() {
+	c.mu.Lock()
+	from := c.state
+	c.failures = 0
+	c.probing = false
+	c.state = BreakerClosed
+	c.mu.Unlock()
+	c.notify(from, BreakerClosed)
+}
+
+// OnFailure 记录一次失败的请求
+func (c *CircuitBreaker) OnFailure() {
+	c.mu.Lock()
+	from := c.state
+	c.probing = false
+	switch c.state {
+	case BreakerHalfOpen:
+		c.open()
+	case BreakerClosed:
+		c.failures++
+		if c.failures >= c.failureThreshold {
+			c.open()
+		}
+	}
+	to := c.state
+	c.mu.Unlock()
+	c.notify(from, to)
+}
+
+func (c *CircuitBreaker) allow() bool {
+	switch c.state {
+	case BreakerOpen:
+		if time.Since(c.openedAt) < c.openTimeout {
+			return false
+		}
+		c.state = BreakerHalfOpen
+		c.probing = true
+		return true
+	case BreakerHalfOpen:
+		// 半开状态下同一时间只允许一个探测请求
+		if c.probing {
+			return false
+		}
+		c.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (c *CircuitBreaker) open() {
+	c.failures = 0
+	c.openedAt = time.Now()
+	c.state = BreakerOpen
+}
+
+// notify 状态发生变化时通知，在锁外调用，避免监听者回调中访问熔断器导致死锁
+func (c *CircuitBreaker) notify(from, to BreakerState) {
+	if from != to && c.onStateChange != nil {
+		c.onStateChange(from, to)
+	}
+}
+
+// BreakerListener 熔断器状态变化监听者，用于对外暴露熔断状态，供监控使用
+type BreakerListener func(dest network.Endpoint, from, to BreakerState)
+
+// CircuitBreakers 以对端endpoint为粒度维护的熔断器集合，可被多个ResilientClient共享
+type CircuitBreakers struct {
+	mu               sync.RWMutex
+	breakers         map[network.Endpoint]*CircuitBreaker
+	listenerMu       sync.RWMutex
+	listeners        []BreakerListener
+	failureThreshold int
+	openTimeout      time.Duration
+}
+
+func NewCircuitBreakers(failureThreshold int, openTimeout time.Duration) *CircuitBreakers {
+	return &CircuitBreakers{
+		breakers:         make(map[network.Endpoint]*CircuitBreaker),
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+}
+
+// DefaultCircuitBreakers 默认连续失败5次后熔断，熔断5s后进行探测
+func DefaultCircuitBreakers() *CircuitBreakers {
+	return NewCircuitBreakers(5, 5*time.Second)
+}
+
+// Of 返回dest对应的熔断器，不存在时新建
+func (c *CircuitBreakers) Of(dest network.Endpoint) *CircuitBreaker {
+	c.mu.RLock()
+	breaker, ok := c.breakers[dest]
+	c.mu.RUnlock()
+	if ok {
+		return breaker
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if breaker, ok = c.breakers[dest]; ok {
+		return breaker
+	}
+	breaker = NewCircuitBreaker(c.failureThreshold, c.openTimeout)
+	breaker.onStateChange = func(from, to BreakerState) {
+		c.notify(dest, from, to)
+	}
+	c.breakers[dest] = breaker
+	return breaker
+}
+
+// States 返回所有对端的熔断状态
+func (c *CircuitBreakers) States() map[network.Endpoint]BreakerState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	states := make(map[network.Endpoint]BreakerState, len(c.breakers))
+	for dest, breaker := range c.breakers {
+		states[dest] = breaker.State()
+	}
+	return states
+}
+
+// AddListener 增加熔断状态变化监听者
+func (c *CircuitBreakers) AddListener(listener BreakerListener) {
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *CircuitBreakers) notify(dest network.Endpoint, from, to BreakerState) {
+	c.listenerMu.RLock()
+	listeners := c.listeners
+	c.listenerMu.RUnlock()
+	for _, listener := range listeners {
+		listener(dest, from, to)
+	}
+}
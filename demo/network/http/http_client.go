@@ -4,22 +4,36 @@ import (
 	"demo/network"
 	"errors"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 type Client struct {
 	socket        network.Socket
 	localEndpoint network.Endpoint
-	respChan      chan *Response // 用于同步阻塞等待Http响应
+	respChan      chan *Response // 用于同步阻塞等待Http响应，只投递正在等待的请求的响应
+	done          chan struct{}  // Close时关闭，唤醒等待响应的Send
+	closeOnce     sync.Once
+	mu            sync.Mutex
+	waiting       ReqId // 正在等待响应的请求id
+	isWaiting     bool
+	streams       *streamManager
 }
 
 func NewClient(socket network.Socket, ip string) (*Client, error) {
 	// 随机端口，从10000 ～ 19999
 	endpoint := network.EndpointOf(ip, int(rand.Uint32()%10000+10000))
+	return NewClientOf(socket, endpoint)
+}
+
+// NewClientOf 在指定的本地endpoint上创建Client
+func NewClientOf(socket network.Socket, endpoint network.Endpoint) (*Client, error) {
 	client := &Client{
 		socket:        socket,
 		localEndpoint: endpoint,
-		respChan:      make(chan *Response),
+		respChan:      make(chan *Response, 1),
+		done:          make(chan struct{}),
+		streams:       newStreamManager(socket),
 	}
 	client.socket.AddListener(client)
 	if err := client.socket.Listen(endpoint); err != nil {
@@ -28,39 +42,100 @@ func NewClient(socket network.Socket, ip string) (*Client, error) {
 	return client, nil
 }
 
+func (c *Client) LocalEndpoint() network.Endpoint {
+	return c.localEndpoint
+}
+
+// Close 关闭Client，respChan不会被关闭，避免与Handle中的投递并发时panic
 func (c *Client) Close() {
-	c.socket.Close(c.localEndpoint)
-	close(c.respChan)
+	c.closeOnce.Do(func() {
+		c.socket.Close(c.localEndpoint)
+		close(c.done)
+	})
 }
 
 func (c *Client) Send(dest network.Endpoint, req *Request) (*Response, error) {
+	c.wait(req.ReqId(), true)
+	defer c.wait(req.ReqId(), false)
 	packet := network.NewPacket(c.localEndpoint, dest, req)
 	err := c.socket.Send(packet)
 	if err != nil {
 		return nil, err
 	}
-	// 发送请求后同步阻塞等待响应
-	select {
-	case resp, ok := <-c.respChan:
-		if ok {
+	if body, ok := req.Body().(*StreamBody); ok {
+		go c.streams.send(c.localEndpoint, dest, body)
+	}
+	// 发送请求后同步阻塞等待响应，超时时间为3s
+	timeout := time.After(time.Second * time.Duration(3))
+	for {
+		select {
+		case <-c.done:
+			errResp := ResponseOfId(req.ReqId()).AddStatusCode(StatusInternalServerError).
+				AddProblemDetails("connection is break")
+			return errResp, nil
+		case resp := <-c.respChan:
+			// 丢弃之前超时请求的迟到响应
+			if resp.ReqId() != req.ReqId() {
+				continue
+			}
+			return resp, nil
+		case <-timeout:
+			resp := ResponseOfId(req.ReqId()).AddStatusCode(StatusGatewayTimeout).
+				AddProblemDetails("http server response timeout")
 			return resp, nil
 		}
-		errResp := ResponseOfId(req.ReqId()).AddStatusCode(StatusInternalServerError).
-			AddProblemDetails("connection is break")
-		return errResp, nil
-	case <-time.After(time.Second * time.Duration(3)):
-		// 超时时间为3s
-		resp := ResponseOfId(req.ReqId()).AddStatusCode(StatusGatewayTimeout).
-			AddProblemDetails("http server response timeout")
-		return resp, nil
 	}
 }
 
 func (c *Client) Handle(packet *network.Packet) error {
+	if c.streams.receive(packet) {
+		return nil
+	}
 	resp, ok := packet.Payload().(*Response)
 	if !ok {
 		return errors.New("invalid packet, not http response")
 	}
-	c.respChan <- resp
+	if body, ok := resp.Body().(*StreamBody); ok {
+		resp = resp.Clone().AddBody(c.streams.reader(body.Id(), packet.Dest(), packet.Src()))
+	}
+	if !c.deliver(resp) {
+		closeStream(resp)
+	}
 	return nil
 }
+
+// closeStream 丢弃响应时关闭流式body的reader，丢弃后续的分片
+func closeStream(resp *Response) {
+	if reader, ok := resp.Body().(*StreamReader); ok {
+		reader.Close()
+	}
+}
+
+// wait 设置正在等待响应的请求，开始等待时清理上次请求超时后残留的响应
+func (c *Client) wait(reqId ReqId, isWaiting bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting, c.isWaiting = reqId, isWaiting
+	if isWaiting {
+		select {
+		case resp := <-c.respChan:
+			closeStream(resp)
+		default:
+		}
+	}
+}
+
+// deliver 投递响应，没有请求在等待该响应时（如超时后迟到的响应）直接丢弃并返回false，不会阻塞网络的投递goroutine
+func (c *Client) deliver(resp *Response) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.isWaiting || resp.ReqId() != c.waiting {
+		return false
+	}
+	select {
+	case c.respChan <- resp:
+		return true
+	default:
+		return false
+	}
+}
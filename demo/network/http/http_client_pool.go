@@ -0,0 +1,98 @@
+package http
+
+import (
+	"demo/network"
+	"errors"
+	"sync"
+)
+
+/*
+对象池模式
+*/
+
+const (
+	minClientPort = 10000
+	maxClientPort = 19999
+	// 每个ip最多缓存的空闲Client数
+	maxIdleClients = 8
+)
+
+// ClientPool Client连接池，以本地ip为key缓存空闲的Client，复用已监听的endpoint
+// 同一时刻一个Client只会被一个使用者持有，因此可以被多个handler并发使用
+type ClientPool struct {
+	mu            sync.Mutex
+	socketFactory func() network.Socket
+	idle          map[string][]*Client
+	nextPort      map[string]int
+	isClosed      bool
+}
+
+// NewClientPool 入参为socket工厂方法，一般为sidecar.Factory的Create方法
+func NewClientPool(socketFactory func() network.Socket) *ClientPool {
+	return &ClientPool{
+		socketFactory: socketFactory,
+		idle:          make(map[string][]*Client),
+		nextPort:      make(map[string]int),
+	}
+}
+
+// Get 获取本地ip为ip的Client，没有空闲Client时新建一个，使用完后需要调用Put归还
+func (p *ClientPool) Get(ip string) (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if clients := p.idle[ip]; len(clients) > 0 {
+		client := clients[len(clients)-1]
+		p.idle[ip] = clients[:len(clients)-1]
+		return client, nil
+	}
+	return p.newClient(ip)
+}
+
+// Put 归还Client，连接池已关闭或空闲Client过多时直接关闭
+func (p *ClientPool) Put(client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ip := client.LocalEndpoint().Ip()
+	if p.isClosed || len(p.idle[ip]) >= maxIdleClients {
+		client.Close()
+		return
+	}
+	p.idle[ip] = append(p.idle[ip], client)
+}
+
+// Close 关闭连接池中所有的空闲Client
+func (p *ClientPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.isClosed = true
+	for ip, clients := range p.idle {
+		for _, client := range clients {
+			client.Close()
+		}
+		delete(p.idle, ip)
+	}
+}
+
+// newClient 从上次分配的端口开始依次查找可用端口，跳过已被监听的端口
+func (p *ClientPool) newClient(ip string) (*Client, error) {
+	port, ok := p.nextPort[ip]
+	if !ok {
+		port = minClientPort
+	}
+	for i := 0; i <= maxClientPort-minClientPort; i++ {
+		endpoint := network.EndpointOf(ip, port)
+		port++
+		if port > maxClientPort {
+			port = minClientPort
+		}
+		client, err := NewClientOf(p.socketFactory(), endpoint)
+		if err == nil {
+			p.nextPort[ip] = port
+			return client, nil
+		}
+		if !errors.Is(err, network.ErrEndpointAlreadyListened) {
+			return nil, err
+		}
+	}
+	return nil, ErrClientPortExhausted
+}
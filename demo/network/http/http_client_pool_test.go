@@ -0,0 +1,81 @@
+package http
+
+import (
+	"demo/network"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientPool(t *testing.T) {
+	// 预先占用第一个端口，连接池应跳过该端口
+	occupied, err := NewClientOf(network.DefaultSocket(), network.EndpointOf("192.168.3.1", 10000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+
+	pool := NewClientPool(func() network.Socket { return network.DefaultSocket() })
+	defer pool.Close()
+	clients := make(chan *Client, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := pool.Get("192.168.3.1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			clients <- client
+		}()
+	}
+	wg.Wait()
+	close(clients)
+
+	ports := make(map[int]bool)
+	for client := range clients {
+		port := client.LocalEndpoint().Port()
+		if port == 10000 || ports[port] {
+			t.Errorf("port %d allocated repeatedly", port)
+		}
+		ports[port] = true
+		pool.Put(client)
+	}
+
+	client, err := pool.Get("192.168.3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ports[client.LocalEndpoint().Port()] {
+		t.Errorf("want reused client got new port %d", client.LocalEndpoint().Port())
+	}
+	pool.Put(client)
+}
+
+func TestClientPoolLateResponse(t *testing.T) {
+	pool := NewClientPool(func() network.Socket { return network.DefaultSocket() })
+	client, err := pool.Get("192.168.3.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(client)
+
+	// 空闲Client收到迟到的响应时直接丢弃，不阻塞网络的投递
+	late := network.NewPacket(network.EndpointOf("192.168.3.3", 80), client.LocalEndpoint(), ResponseOfId(1))
+	done := make(chan struct{})
+	go func() {
+		client.Handle(late)
+		client.Handle(late)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handle late response blocked")
+	}
+	// 关闭后再收到响应也不会panic
+	pool.Close()
+	client.Handle(late)
+}
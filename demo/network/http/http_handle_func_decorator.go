@@ -1,10 +1,8 @@
 package http
 
 import (
-    "context"
     "log"
     "net/http"
-    "time"
 )
 
 // 关键点1: 确定被装饰者接口，这里为原生的http.HandlerFunc
@@ -21,11 +19,6 @@ func Decorate(h http.HandlerFunc, decorators ...HttpHandlerFuncDecorator) http.H
     for _, decorator := range decorators {
         h = decorator(h)
     }
-
-    ctx := context.Background()
-    ctx, _ = context.WithCancel(ctx)
-    ctx, _ = context.WithTimeout(ctx, time.Duration(1))
-    ctx = context.WithValue(ctx, "key", "value")
     return h
 }
 
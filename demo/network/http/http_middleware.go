@@ -0,0 +1,107 @@
+package http
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+/*
+装饰器模式
+*/
+
+// Middleware HTTP中间件，对Handler进行装饰，入参和返回值都是Handler
+type Middleware func(next Handler) Handler
+
+// Chain 使用中间件装饰Handler，第一个中间件位于最外层，最先处理请求
+// 可用于对单个路由进行装饰，如 server.Get("/hello", Chain(hello, WithTimeout(time.Second)))
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// WithLogging 请求日志中间件，打印请求方法、uri、状态码和处理耗时
+func WithLogging(next Handler) Handler {
+	return func(req *Request) *Response {
+		start := time.Now()
+		resp := next(req)
+		fmt.Printf("http request %d method %s uri %s status %d cost %v\n",
+			req.ReqId(), req.Method(), req.Uri(), resp.StatusCode().Code, time.Since(start))
+		return resp
+	}
+}
+
+// WithRecovery panic恢复中间件，将Handler中的panic转换为500响应
+func WithRecovery(next Handler) Handler {
+	return func(req *Request) (resp *Response) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp = ResponseOfId(req.ReqId()).AddStatusCode(StatusInternalServerError).
+					AddProblemDetails(fmt.Sprintf("handler panic: %v", r))
+			}
+		}()
+		return next(req)
+	}
+}
+
+// RequestIdHeader 用于在服务间传递请求ID的header
+const RequestIdHeader = "request-id"
+
+// WithRequestId 请求ID传递中间件，请求中没有request-id时自动生成，并回填到响应header中
+func WithRequestId(next Handler) Handler {
+	return func(req *Request) *Response {
+		requestId, ok := req.Header(RequestIdHeader)
+		if !ok || requestId == "" {
+			requestId = uuid.NewString()
+			req.AddHeader(RequestIdHeader, requestId)
+		}
+		resp := next(req)
+		if _, ok := resp.Header(RequestIdHeader); !ok {
+			resp.AddHeader(RequestIdHeader, requestId)
+		}
+		return resp
+	}
+}
+
+// WithAuth 鉴权中间件，请求的header[key]不等于value时返回401
+func WithAuth(key, value string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) *Response {
+			if val, ok := req.Header(key); !ok || val != value {
+				return ResponseOfId(req.ReqId()).AddStatusCode(StatusUnauthorized).
+					AddProblemDetails("auth header " + key + " is invalid")
+			}
+			return next(req)
+		}
+	}
+}
+
+// WithTimeout 超时中间件，Handler在timeout时间内未返回时响应504，一般用于单个路由
+func WithTimeout(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) *Response {
+			respChan := make(chan *Response, 1)
+			panicChan := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						panicChan <- r
+					}
+				}()
+				respChan <- next(req)
+			}()
+			select {
+			case resp := <-respChan:
+				return resp
+			case r := <-panicChan:
+				// 在调用方goroutine中重新panic，以便外层的WithRecovery能够捕获
+				panic(r)
+			case <-time.After(timeout):
+				return ResponseOfId(req.ReqId()).AddStatusCode(StatusGatewayTimeout).
+					AddProblemDetails("handler timeout after " + timeout.String())
+			}
+		}
+	}
+}
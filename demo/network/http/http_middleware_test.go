@@ -0,0 +1,61 @@
+package http
+
+import (
+	"demo/network"
+	"testing"
+	"time"
+)
+
+func TestServerMiddleware(t *testing.T) {
+	server := NewServer(network.DefaultSocket()).Listen("192.168.1.1", 80).
+		Use(WithRequestId, WithRecovery, WithAuth("token", "pass")).
+		Get("/hello", func(req *Request) *Response {
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusNoContent)
+		}).
+		Get("/panic", func(req *Request) *Response {
+			panic("oops")
+		}).
+		Get("/slow", Chain(func(req *Request) *Response {
+			time.Sleep(500 * time.Millisecond)
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk)
+		}, WithTimeout(50*time.Millisecond)))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	client, err := NewClient(network.DefaultSocket(), "192.168.1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	dest := network.EndpointOf("192.168.1.1", 80)
+
+	resp, _ := client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/hello"))
+	if resp.StatusCode() != StatusUnauthorized {
+		t.Errorf("want StatusUnauthorized got %v", resp.StatusCode())
+	}
+
+	req := EmptyRequest().AddMethod(GET).AddUri("/hello").AddHeader("token", "pass").
+		AddHeader(RequestIdHeader, "req-1")
+	resp, _ = client.Send(dest, req)
+	if resp.StatusCode() != StatusNoContent {
+		t.Errorf("want StatusNoContent got %v", resp.StatusCode())
+	}
+	if requestId, _ := resp.Header(RequestIdHeader); requestId != "req-1" {
+		t.Errorf("want request-id req-1 got %s", requestId)
+	}
+
+	resp, _ = client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/panic").AddHeader("token", "pass"))
+	if resp.StatusCode() != StatusInternalServerError {
+		t.Errorf("want StatusInternalServerError got %v", resp.StatusCode())
+	}
+	if requestId, ok := resp.Header(RequestIdHeader); !ok || requestId == "" {
+		t.Error("want generated request-id in response")
+	}
+
+	resp, _ = client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/slow").AddHeader("token", "pass"))
+	if resp.StatusCode() != StatusGatewayTimeout {
+		t.Errorf("want StatusGatewayTimeout got %v", resp.StatusCode())
+	}
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"demo/network"
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	requestPayloadType  = fmt.Sprintf("%T", new(Request))
+	responsePayloadType = fmt.Sprintf("%T", new(Response))
+)
+
+// ReplayResult 单个请求的回放结果
+type ReplayResult struct {
+	Record   network.CaptureRecord
+	Request  *Request
+	Expected StatusCode // 抓包中对应响应的状态码，抓包中没有响应时为零值
+	Actual   StatusCode
+	Err      error
+}
+
+// IsMatched 回放的响应状态码与抓包中的一致时返回true
+func (r ReplayResult) IsMatched() bool {
+	return r.Err == nil && r.Expected == r.Actual
+}
+
+// Replayer 抓包回放器，将抓包中的HTTP请求重新发送到原目的地址，并与抓包中的响应进行比较，用于回归测试
+type Replayer struct {
+	client *Client
+	filter func(record network.CaptureRecord) bool
+}
+
+func NewReplayer(client *Client) *Replayer {
+	return &Replayer{
+		client: client,
+		filter: func(record network.CaptureRecord) bool { return true },
+	}
+}
+
+// WithFilter 只回放filter返回true的请求，比如只回放外部发往入口服务的请求，避免服务间的内部请求被重复回放
+func (r *Replayer) WithFilter(filter func(record network.CaptureRecord) bool) *Replayer {
+	r.filter = filter
+	return r
+}
+
+// Replay 按顺序回放records中的HTTP请求
+func (r *Replayer) Replay(records []network.CaptureRecord) []ReplayResult {
+	var results []ReplayResult
+	for i, record := range records {
+		if record.PayloadType != requestPayloadType || !r.filter(record) {
+			continue
+		}
+		result := ReplayResult{Record: record}
+		req := new(Request)
+		if result.Err = json.Unmarshal(record.Payload, req); result.Err != nil {
+			results = append(results, result)
+			continue
+		}
+		result.Request = req
+		result.Expected = r.expectedStatus(records[i+1:], record, req.ReqId())
+		resp, err := r.client.Send(record.Dest, req)
+		if err != nil {
+			result.Err = err
+		} else {
+			result.Actual = resp.StatusCode()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// expectedStatus 在抓包中查找请求对应的响应
+func (r *Replayer) expectedStatus(records []network.CaptureRecord, reqRecord network.CaptureRecord, reqId ReqId) StatusCode {
+	for _, record := range records {
+		if record.PayloadType != responsePayloadType ||
+			record.Src != reqRecord.Dest || record.Dest != reqRecord.Src {
+			continue
+		}
+		resp := new(Response)
+		if err := json.Unmarshal(record.Payload, resp); err != nil {
+			continue
+		}
+		if resp.ReqId() == reqId {
+			return resp.StatusCode()
+		}
+	}
+	return StatusCode{}
+}
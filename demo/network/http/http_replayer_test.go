@@ -0,0 +1,75 @@
+package http
+
+import (
+	"bytes"
+	"demo/network"
+	"testing"
+)
+
+func newReplayTestServer(echoStatus StatusCode) *Server {
+	return NewServer(network.DefaultSocket()).Listen("192.168.5.1", 80).
+		Get("/hello", func(req *Request) *Response {
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusNoContent)
+		}).
+		Post("/echo", func(req *Request) *Response {
+			return ResponseOfId(req.ReqId()).AddStatusCode(echoStatus).AddBody(req.Body())
+		})
+}
+
+func TestCaptureAndReplay(t *testing.T) {
+	server := newReplayTestServer(StatusOk)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	capture := network.NewPacketCapture(buf)
+	network.Instance().AddHook(capture)
+
+	client, err := NewClient(network.DefaultSocket(), "192.168.5.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := network.EndpointOf("192.168.5.1", 80)
+	client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/hello"))
+	resp, _ := client.Send(dest, EmptyRequest().AddMethod(POST).AddUri("/echo").AddBody("hello"))
+	if resp.Body() != "hello" {
+		t.Errorf("want hello got %v", resp.Body())
+	}
+	network.Instance().RemoveHook(capture)
+	client.Close()
+	server.Shutdown()
+
+	records, err := network.ReadCapture(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("want 4 records got %d", len(records))
+	}
+	if records[0].Summary != "GET /hello" || records[0].Dest != dest {
+		t.Errorf("want GET /hello to %s got %s to %s", dest, records[0].Summary, records[0].Dest)
+	}
+
+	// 对新启动的服务进行回放，/echo接口出现了回归
+	server = newReplayTestServer(StatusInternalServerError)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+	client, err = NewClient(network.DefaultSocket(), "192.168.5.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	results := NewReplayer(client).Replay(records)
+	if len(results) != 2 {
+		t.Fatalf("want 2 results got %d", len(results))
+	}
+	if !results[0].IsMatched() {
+		t.Errorf("want /hello matched got %+v", results[0])
+	}
+	if results[1].IsMatched() || results[1].Expected != StatusOk ||
+		results[1].Actual != StatusInternalServerError || results[1].Request.Body() != "hello" {
+		t.Errorf("want /echo mismatched got %+v", results[1])
+	}
+}
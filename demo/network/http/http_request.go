@@ -12,8 +12,32 @@ const (
 	POST
 	PUT
 	DELETE
+	PATCH
+	HEAD
+	OPTIONS
 )
 
+func (m Method) String() string {
+	switch m {
+	case GET:
+		return "GET"
+	case POST:
+		return "POST"
+	case PUT:
+		return "PUT"
+	case DELETE:
+		return "DELETE"
+	case PATCH:
+		return "PATCH"
+	case HEAD:
+		return "HEAD"
+	case OPTIONS:
+		return "OPTIONS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 type Uri string
 
 func (u Uri) Contains(other Uri) bool {
@@ -54,8 +78,15 @@ func (r *Request) Clone() *Request {
 	}
 }
 
+// withBody 复制Request并替换body，与Clone不同的是reqId保持不变
+func (r *Request) withBody(body interface{}) *Request {
+	replica := *r
+	replica.body = body
+	return &replica
+}
+
 func (r *Request) IsInValid() bool {
-	return r.method < 1 || r.method > 4 || r.uri == ""
+	return r.method < GET || r.method > OPTIONS || r.uri == ""
 }
 
 func (r *Request) AddMethod(method Method) *Request {
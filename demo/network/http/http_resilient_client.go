@@ -0,0 +1,180 @@
+package http
+
+import (
+	"demo/network"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// RetryAfterHeader 服务端告知客户端多少秒后重试的header，如流控时返回
+const RetryAfterHeader = "retry-after"
+
+// RetryPolicy 重试策略，采用带抖动的指数退避
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数，包括第一次请求
+	MaxAttempts int
+	// BaseBackoff 第一次重试的退避时间，之后每次翻倍
+	BaseBackoff time.Duration
+	// MaxBackoff 退避时间上限
+	MaxBackoff time.Duration
+	// RetryOn 需要重试的响应状态码
+	RetryOn []StatusCode
+	// RetryMethods 可以重试的请求方法，为空时只重试幂等的方法
+	RetryMethods []Method
+}
+
+// 幂等的请求方法，重复执行不会产生副作用
+var idempotentMethods = []Method{GET, HEAD, OPTIONS, PUT, DELETE}
+
+// DefaultRetryPolicy 默认最多尝试3次，只有幂等的请求在429、503、504时重试
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+		RetryOn:     []StatusCode{StatusTooManyRequest, StatusServiceUnavailable, StatusGatewayTimeout},
+	}
+}
+
+// canRetry 请求能否重试，流式body发送后已被读取，不能重试
+func (r RetryPolicy) canRetry(req *Request) bool {
+	if _, ok := req.Body().(*StreamBody); ok {
+		return false
+	}
+	methods := r.RetryMethods
+	if len(methods) == 0 {
+		methods = idempotentMethods
+	}
+	for _, method := range methods {
+		if req.Method() == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RetryPolicy) shouldRetry(resp *Response) bool {
+	for _, code := range r.RetryOn {
+		if resp.StatusCode() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 计算第attempt次重试前的等待时间，响应中带有retry-after时优先使用
+func (r RetryPolicy) backoff(attempt int, resp *Response) time.Duration {
+	if resp != nil {
+		if val, ok := resp.Header(RetryAfterHeader); ok {
+			if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 {
+				return r.limit(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	backoff := r.limit(r.BaseBackoff << (attempt - 1))
+	if backoff <= 0 {
+		return 0
+	}
+	// 抖动，避免大量客户端在同一时刻重试
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (r RetryPolicy) limit(backoff time.Duration) time.Duration {
+	if r.MaxBackoff > 0 && (backoff > r.MaxBackoff || backoff < 0) {
+		return r.MaxBackoff
+	}
+	return backoff
+}
+
+// ResilientClientOption ResilientClient的可选配置
+type ResilientClientOption func(client *ResilientClient)
+
+// RetryWith 设置重试策略
+func RetryWith(policy RetryPolicy) ResilientClientOption {
+	return func(client *ResilientClient) {
+		client.policy = policy
+	}
+}
+
+// BreakWith 设置熔断器集合，多个ResilientClient共享同一集合时，熔断状态也是共享的
+func BreakWith(breakers *CircuitBreakers) ResilientClientOption {
+	return func(client *ResilientClient) {
+		client.breakers = breakers
+	}
+}
+
+/*
+装饰者模式
+*/
+
+// ResilientClient 具备重试、退避和熔断能力的Client装饰器
+type ResilientClient struct {
+	client   *Client
+	policy   RetryPolicy
+	breakers *CircuitBreakers
+}
+
+func NewResilientClient(client *Client, options ...ResilientClientOption) *ResilientClient {
+	resilient := &ResilientClient{
+		client: client,
+		policy: DefaultRetryPolicy(),
+	}
+	for _, option := range options {
+		option(resilient)
+	}
+	if resilient.policy.MaxAttempts < 1 {
+		resilient.policy.MaxAttempts = 1
+	}
+	if resilient.breakers == nil {
+		resilient.breakers = DefaultCircuitBreakers()
+	}
+	return resilient
+}
+
+// Breakers 返回熔断器集合，可用于查询熔断状态或监听状态变化
+func (r *ResilientClient) Breakers() *CircuitBreakers {
+	return r.breakers
+}
+
+func (r *ResilientClient) Close() {
+	r.client.Close()
+}
+
+// Send 发送请求，失败时按重试策略进行重试，不能重试的请求只发送一次；对端熔断时返回ErrCircuitBreakerOpen
+func (r *ResilientClient) Send(dest network.Endpoint, req *Request) (*Response, error) {
+	breaker := r.breakers.Of(dest)
+	maxAttempts := r.policy.MaxAttempts
+	if !r.policy.canRetry(req) {
+		maxAttempts = 1
+	}
+	var resp *Response
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(r.policy.backoff(attempt, resp))
+		}
+		if !breaker.Allow() {
+			return nil, ErrCircuitBreakerOpen
+		}
+		// 每次重试都使用新的reqId，避免收到上一次超时请求的迟到响应
+		resp, err = r.client.Send(dest, req.Clone())
+		if err != nil {
+			breaker.OnFailure()
+			continue
+		}
+		if resp.StatusCode().Code/100 == 5 {
+			breaker.OnFailure()
+		} else {
+			breaker.OnSuccess()
+		}
+		if !r.policy.shouldRetry(resp) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Clone().AddReqId(req.ReqId()), nil
+}
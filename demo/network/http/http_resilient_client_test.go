@@ -0,0 +1,140 @@
+package http
+
+import (
+	"bytes"
+	"demo/network"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResilientClientRetry(t *testing.T) {
+	var count int32
+	server := NewServer(network.DefaultSocket()).Listen("192.168.2.1", 80).
+		Get("/hello", func(req *Request) *Response {
+			if atomic.AddInt32(&count, 1) < 3 {
+				return ResponseOfId(req.ReqId()).AddStatusCode(StatusTooManyRequest).
+					AddHeader(RetryAfterHeader, "0")
+			}
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	c, err := NewClient(network.DefaultSocket(), "192.168.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewResilientClient(c)
+	defer client.Close()
+	req := EmptyRequest().AddMethod(GET).AddUri("/hello")
+	resp, err := client.Send(network.EndpointOf("192.168.2.1", 80), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != StatusOk || resp.ReqId() != req.ReqId() {
+		t.Errorf("want StatusOk with reqId %d got %v, %d", req.ReqId(), resp.StatusCode(), resp.ReqId())
+	}
+	if atomic.LoadInt32(&count) != 3 {
+		t.Errorf("want 3 attempts got %d", count)
+	}
+}
+
+func TestResilientClientCircuitBreaker(t *testing.T) {
+	var healthy int32
+	server := NewServer(network.DefaultSocket()).Listen("192.168.2.3", 80).
+		Get("/hello", func(req *Request) *Response {
+			if atomic.LoadInt32(&healthy) == 1 {
+				return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk)
+			}
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusInternalServerError)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	c, err := NewClient(network.DefaultSocket(), "192.168.2.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	breakers := NewCircuitBreakers(2, 50*time.Millisecond)
+	var transitions []BreakerState
+	breakers.AddListener(func(dest network.Endpoint, from, to BreakerState) {
+		transitions = append(transitions, to)
+	})
+	client := NewResilientClient(c, BreakWith(breakers), RetryWith(RetryPolicy{MaxAttempts: 1}))
+	defer client.Close()
+	dest := network.EndpointOf("192.168.2.3", 80)
+
+	for i := 0; i < 2; i++ {
+		client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/hello"))
+	}
+	if _, err := client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/hello")); err != ErrCircuitBreakerOpen {
+		t.Errorf("want ErrCircuitBreakerOpen got %v", err)
+	}
+	if breakers.States()[dest] != BreakerOpen {
+		t.Errorf("want open got %v", breakers.States()[dest])
+	}
+
+	// 熔断时间过后，半开状态下探测成功则关闭熔断器
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	resp, err := client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/hello"))
+	if err != nil || resp.StatusCode() != StatusOk {
+		t.Errorf("want StatusOk got %v, %v", resp, err)
+	}
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("want transitions %v got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("want transitions %v got %v", want, transitions)
+		}
+	}
+}
+
+func TestResilientClientRetryMethods(t *testing.T) {
+	var count int32
+	server := NewServer(network.DefaultSocket()).Listen("192.168.2.5", 80).
+		Post("/orders", func(req *Request) *Response {
+			atomic.AddInt32(&count, 1)
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusGatewayTimeout)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	c, err := NewClient(network.DefaultSocket(), "192.168.2.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultRetryPolicy()
+	policy.BaseBackoff = time.Millisecond
+	client := NewResilientClient(c, BreakWith(NewCircuitBreakers(10, time.Second)), RetryWith(policy))
+	defer client.Close()
+	dest := network.EndpointOf("192.168.2.5", 80)
+
+	// 默认不重试非幂等的POST请求，避免重复创建
+	resp, err := client.Send(dest, EmptyRequest().AddMethod(POST).AddUri("/orders"))
+	if err != nil || resp.StatusCode() != StatusGatewayTimeout {
+		t.Fatalf("want StatusGatewayTimeout got %v, %v", resp, err)
+	}
+	if atomic.LoadInt32(&count) != 1 {
+		t.Errorf("want 1 attempt got %d", count)
+	}
+
+	policy.RetryMethods = []Method{POST}
+	if !policy.canRetry(EmptyRequest().AddMethod(POST).AddUri("/orders")) {
+		t.Errorf("want POST retried when configured")
+	}
+	// 流式body只能读取一次，任何方法都不重试
+	stream := EmptyRequest().AddMethod(POST).AddUri("/orders").AddBody(NewStreamBody(bytes.NewReader([]byte("order"))))
+	if policy.canRetry(stream) || DefaultRetryPolicy().canRetry(EmptyRequest().AddMethod(PUT).AddUri("/orders").AddBody(NewStreamBody(bytes.NewReader(nil)))) {
+		t.Errorf("want stream body not retried")
+	}
+}
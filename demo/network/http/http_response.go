@@ -6,14 +6,32 @@ type StatusCode struct {
 }
 
 var (
+	StatusContinue            = StatusCode{Code: 100, Details: "Continue"}
 	StatusOk                  = StatusCode{Code: 200, Details: "OK"}
 	StatusCreate              = StatusCode{Code: 201, Details: "Create"}
+	StatusAccepted            = StatusCode{Code: 202, Details: "Accepted"}
 	StatusNoContent           = StatusCode{Code: 204, Details: "No Content"}
+	StatusMovedPermanently    = StatusCode{Code: 301, Details: "Moved Permanently"}
+	StatusFound               = StatusCode{Code: 302, Details: "Found"}
+	StatusNotModified         = StatusCode{Code: 304, Details: "Not Modified"}
 	StatusBadRequest          = StatusCode{Code: 400, Details: "Bad Request"}
+	StatusUnauthorized        = StatusCode{Code: 401, Details: "Unauthorized"}
+	StatusForbidden           = StatusCode{Code: 403, Details: "Forbidden"}
 	StatusNotFound            = StatusCode{Code: 404, Details: "Not Found"}
 	StatusMethodNotAllow      = StatusCode{Code: 405, Details: "Method Not Allow"}
+	StatusNotAcceptable       = StatusCode{Code: 406, Details: "Not Acceptable"}
+	StatusRequestTimeout      = StatusCode{Code: 408, Details: "Request Timeout"}
+	StatusConflict            = StatusCode{Code: 409, Details: "Conflict"}
+	StatusGone                = StatusCode{Code: 410, Details: "Gone"}
+	StatusPreconditionFailed  = StatusCode{Code: 412, Details: "Precondition Failed"}
+	StatusPayloadTooLarge     = StatusCode{Code: 413, Details: "Payload Too Large"}
+	StatusUnsupportedMedia    = StatusCode{Code: 415, Details: "Unsupported Media Type"}
+	StatusUnprocessableEntity = StatusCode{Code: 422, Details: "Unprocessable Entity"}
 	StatusTooManyRequest      = StatusCode{Code: 429, Details: "Too Many Request"}
 	StatusInternalServerError = StatusCode{Code: 500, Details: "Internal Server Error"}
+	StatusNotImplemented      = StatusCode{Code: 501, Details: "Not Implemented"}
+	StatusBadGateway          = StatusCode{Code: 502, Details: "Bad Gateway"}
+	StatusServiceUnavailable  = StatusCode{Code: 503, Details: "Service Unavailable"}
 	StatusGatewayTimeout      = StatusCode{Code: 504, Details: "Gateway Timeout"}
 )
 
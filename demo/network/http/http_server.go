@@ -3,6 +3,9 @@ package http
 import (
 	"demo/network"
 	"errors"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Handler HTTP请求处理接口
@@ -13,14 +16,21 @@ type Server struct {
 	socket        network.Socket
 	localEndpoint network.Endpoint
 	routers       map[Method]map[Uri]Handler
+	middlewares   []Middleware
+	streams       *streamManager
+	mu            sync.Mutex
+	inflight      int           // 正在处理中的请求数
+	isDraining    bool          // 是否处于优雅停机中，此时不再接收新请求
+	drained       chan struct{} // 优雅停机时，所有请求处理完成后关闭
 }
 
 func NewServer(socket network.Socket) *Server {
 	server := &Server{
 		socket:  socket,
 		routers: make(map[Method]map[Uri]Handler),
+		streams: newStreamManager(socket),
 	}
-	server.socket.AddListener(server)
+	server.socket.AddListener(serverListener{server: server})
 	return server
 }
 
@@ -30,79 +40,253 @@ func (s *Server) Listen(ip string, port int) *Server {
 }
 
 func (s *Server) Start() error {
+	s.mu.Lock()
+	s.isDraining = false
+	s.mu.Unlock()
 	return s.socket.Listen(s.localEndpoint)
 }
 
+// Shutdown 立即停止监听，正在处理中的请求的响应将无法送达
 func (s *Server) Shutdown() {
 	s.socket.Close(s.localEndpoint)
 }
 
-func (s *Server) Get(uri Uri, handler Handler) *Server {
-	if _, ok := s.routers[GET]; !ok {
-		s.routers[GET] = make(map[Uri]Handler)
+// GracefulShutdown 优雅停机，不再接收新请求（返回503），等待正在处理中的请求完成后停止监听
+// 超过timeout仍未完成时强制停止，并返回ErrShutdownTimeout
+func (s *Server) GracefulShutdown(timeout time.Duration) error {
+	defer s.Shutdown()
+	s.mu.Lock()
+	s.isDraining = true
+	if s.inflight == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.drained == nil {
+		s.drained = make(chan struct{})
+	}
+	drained := s.drained
+	s.mu.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-time.After(timeout):
+		return ErrShutdownTimeout
 	}
-	s.routers[GET][uri] = handler
-	return s
 }
 
-func (s *Server) Post(uri Uri, handler Handler) *Server {
-	if _, ok := s.routers[POST]; !ok {
-		s.routers[POST] = make(map[Uri]Handler)
+// enter 开始处理请求，优雅停机中时返回false
+func (s *Server) enter() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isDraining {
+		return false
 	}
-	s.routers[POST][uri] = handler
-	return s
+	s.inflight++
+	return true
 }
 
-func (s *Server) Put(uri Uri, handler Handler) *Server {
-	if _, ok := s.routers[PUT]; !ok {
-		s.routers[PUT] = make(map[Uri]Handler)
+// leave 请求处理完成
+func (s *Server) leave() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight--
+	if s.inflight == 0 && s.drained != nil {
+		close(s.drained)
+		s.drained = nil
 	}
-	s.routers[PUT][uri] = handler
+}
+
+// Use 注册中间件，作用于所有路由，按注册顺序由外到内装饰Handler
+func (s *Server) Use(middlewares ...Middleware) *Server {
+	s.middlewares = append(s.middlewares, middlewares...)
 	return s
 }
 
-func (s *Server) Delete(uri Uri, handler Handler) *Server {
-	if _, ok := s.routers[DELETE]; !ok {
-		s.routers[DELETE] = make(map[Uri]Handler)
+// Handle 通用的路由注册方法，为method和uri注册handler
+func (s *Server) Handle(method Method, uri Uri, handler Handler) *Server {
+	if _, ok := s.routers[method]; !ok {
+		s.routers[method] = make(map[Uri]Handler)
 	}
-	s.routers[DELETE][uri] = handler
+	s.routers[method][uri] = handler
 	return s
 }
 
-func (s *Server) Handle(packet *network.Packet) error {
+func (s *Server) Get(uri Uri, handler Handler) *Server {
+	return s.Handle(GET, uri, handler)
+}
+
+func (s *Server) Post(uri Uri, handler Handler) *Server {
+	return s.Handle(POST, uri, handler)
+}
+
+func (s *Server) Put(uri Uri, handler Handler) *Server {
+	return s.Handle(PUT, uri, handler)
+}
+
+func (s *Server) Delete(uri Uri, handler Handler) *Server {
+	return s.Handle(DELETE, uri, handler)
+}
+
+func (s *Server) Patch(uri Uri, handler Handler) *Server {
+	return s.Handle(PATCH, uri, handler)
+}
+
+func (s *Server) Head(uri Uri, handler Handler) *Server {
+	return s.Handle(HEAD, uri, handler)
+}
+
+func (s *Server) Options(uri Uri, handler Handler) *Server {
+	return s.Handle(OPTIONS, uri, handler)
+}
+
+// serve 处理socket上接收到的HTTP请求报文，并发送响应
+func (s *Server) serve(packet *network.Packet) error {
+	if s.streams.receive(packet) {
+		return nil
+	}
 	req, ok := packet.Payload().(*Request)
 	if !ok {
 		return errors.New("invalid packet, not http request")
 	}
+	// 请求没有交给handler处理时，关闭流式body的reader，丢弃后续的分片
+	var reader *StreamReader
+	if body, ok := req.Body().(*StreamBody); ok {
+		reader = s.streams.reader(body.Id(), packet.Dest(), packet.Src())
+		req = req.withBody(reader)
+	}
+	reject := func(resp *Response) error {
+		if reader != nil {
+			reader.Close()
+		}
+		return s.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), resp))
+	}
+	if !s.enter() {
+		return reject(ResponseOfId(req.ReqId()).
+			AddStatusCode(StatusServiceUnavailable).
+			AddHeader(RetryAfterHeader, "1").
+			AddProblemDetails("server is shutting down"))
+	}
+	defer s.leave()
 	if req.IsInValid() {
-		resp := ResponseOfId(req.ReqId()).
+		return reject(ResponseOfId(req.ReqId()).
 			AddStatusCode(StatusBadRequest).
-			AddProblemDetails("uri or method is invalid")
-		return s.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), resp))
+			AddProblemDetails("uri or method is invalid"))
 	}
 
-	router, ok := s.routers[req.Method()]
+	handler, ok := s.route(req.Method(), req.Uri())
+	if !ok {
+		handler, ok = s.autoRoute(req.Method(), req.Uri())
+	}
 	if !ok {
-		resp := ResponseOfId(req.ReqId()).
+		allows := s.allowMethods(req.Uri())
+		if len(allows) == 0 {
+			return reject(ResponseOfId(req.ReqId()).
+				AddStatusCode(StatusNotFound).
+				AddProblemDetails("can not find handler of uri"))
+		}
+		return reject(ResponseOfId(req.ReqId()).
 			AddStatusCode(StatusMethodNotAllow).
-			AddProblemDetails(StatusMethodNotAllow.Details)
-		return s.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), resp))
+			AddHeader("allow", joinMethods(allows)).
+			AddProblemDetails(StatusMethodNotAllow.Details))
 	}
 
-	var handler Handler
+	resp := Chain(handler, s.middlewares...)(req)
+	if err := s.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), resp)); err != nil {
+		return err
+	}
+	if body, ok := resp.Body().(*StreamBody); ok {
+		go s.streams.send(packet.Dest(), packet.Src(), body)
+	}
+	return nil
+}
+
+// route 查找method和uri对应的handler
+func (s *Server) route(method Method, uri Uri) (Handler, bool) {
+	router, ok := s.routers[method]
+	if !ok {
+		return nil, false
+	}
 	for u, h := range router {
-		if req.Uri().Contains(u) {
-			handler = h
-			break
+		if uri.Contains(u) {
+			return h, true
 		}
 	}
-	if handler == nil {
-		resp := ResponseOfId(req.ReqId()).
-			AddStatusCode(StatusNotFound).
-			AddProblemDetails("can not find handler of uri")
-		return s.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), resp))
+	return nil, false
+}
+
+// autoRoute 未显式注册HEAD和OPTIONS路由时，自动生成对应的handler
+// HEAD请求复用GET路由，但不返回body；OPTIONS请求返回uri所支持的方法
+func (s *Server) autoRoute(method Method, uri Uri) (Handler, bool) {
+	switch method {
+	case HEAD:
+		get, ok := s.route(GET, uri)
+		if !ok {
+			return nil, false
+		}
+		return func(req *Request) *Response {
+			return get(req).AddBody(nil)
+		}, true
+	case OPTIONS:
+		allows := s.allowMethods(uri)
+		if len(allows) == 0 {
+			return nil, false
+		}
+		return func(req *Request) *Response {
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusNoContent).
+				AddHeader("allow", joinMethods(allows))
+		}, true
+	default:
+		return nil, false
 	}
+}
 
-	resp := handler(req)
-	return s.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), resp))
+// allowMethods 返回uri所支持的所有方法，包括自动支持的HEAD和OPTIONS
+func (s *Server) allowMethods(uri Uri) []Method {
+	var allows []Method
+	for method := GET; method < HEAD; method++ {
+		if _, ok := s.route(method, uri); ok {
+			allows = append(allows, method)
+		}
+	}
+	// HEAD可复用GET路由
+	_, hasHead := s.route(HEAD, uri)
+	_, hasGet := s.route(GET, uri)
+	if hasHead || hasGet {
+		allows = append(allows, HEAD)
+	}
+	// 只要uri存在路由，就支持OPTIONS
+	if _, ok := s.route(OPTIONS, uri); ok || len(allows) > 0 {
+		allows = append(allows, OPTIONS)
+	}
+	return allows
+}
+
+func joinMethods(methods []Method) string {
+	names := make([]string, 0, len(methods))
+	for _, method := range methods {
+		names = append(names, method.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// serverListener 将Server适配为network.SocketListener，监听socket上的HTTP请求报文
+type serverListener struct {
+	server *Server
+}
+
+func (l serverListener) Handle(packet *network.Packet) error {
+	return l.server.serve(packet)
+}
+
+// RejectWithServiceUnavailable 作为network.Rejecter使用，接收队列满时对HTTP请求回复503响应
+func RejectWithServiceUnavailable(packet *network.Packet) *network.Packet {
+	req, ok := packet.Payload().(*Request)
+	if !ok {
+		return nil
+	}
+	resp := ResponseOfId(req.ReqId()).
+		AddStatusCode(StatusServiceUnavailable).
+		AddHeader(RetryAfterHeader, "1").
+		AddProblemDetails("server is overloaded")
+	return network.NewPacket(packet.Dest(), packet.Src(), resp)
 }
@@ -3,6 +3,7 @@ package http
 import (
 	"demo/network"
 	"testing"
+	"time"
 )
 
 func TestHttpServer(t *testing.T) {
@@ -30,3 +31,88 @@ func TestHttpServer(t *testing.T) {
 	client.Close()
 	server.Shutdown()
 }
+
+func TestHttpServerHandle(t *testing.T) {
+	server := NewServer(network.DefaultSocket()).Listen("192.168.0.1", 80).
+		Get("/hello", func(req *Request) *Response {
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk).AddBody("hello")
+		}).
+		Handle(PATCH, "/hello", func(req *Request) *Response {
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusConflict)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	client, err := NewClient(network.DefaultSocket(), "192.168.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	dest := network.EndpointOf("192.168.0.1", 80)
+
+	resp, _ := client.Send(dest, EmptyRequest().AddMethod(PATCH).AddUri("/hello"))
+	if resp.StatusCode() != StatusConflict {
+		t.Errorf("want StatusConflict got %v", resp.StatusCode())
+	}
+
+	resp, _ = client.Send(dest, EmptyRequest().AddMethod(HEAD).AddUri("/hello"))
+	if resp.StatusCode() != StatusOk || resp.Body() != nil {
+		t.Errorf("want StatusOk without body got %v, %v", resp.StatusCode(), resp.Body())
+	}
+
+	resp, _ = client.Send(dest, EmptyRequest().AddMethod(OPTIONS).AddUri("/hello"))
+	if allow, _ := resp.Header("allow"); allow != "GET, PATCH, HEAD, OPTIONS" {
+		t.Errorf("want allow GET, PATCH, HEAD, OPTIONS got %s", allow)
+	}
+
+	resp, _ = client.Send(dest, EmptyRequest().AddMethod(DELETE).AddUri("/hello"))
+	if resp.StatusCode() != StatusMethodNotAllow {
+		t.Errorf("want StatusMethodNotAllow got %v", resp.StatusCode())
+	}
+}
+
+func TestHttpServerGracefulShutdown(t *testing.T) {
+	server := NewServer(network.DefaultSocket()).Listen("192.168.0.1", 80).
+		Get("/slow", func(req *Request) *Response {
+			time.Sleep(300 * time.Millisecond)
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	dest := network.EndpointOf("192.168.0.1", 80)
+	client1, _ := NewClient(network.DefaultSocket(), "192.168.0.2")
+	defer client1.Close()
+	client2, _ := NewClient(network.DefaultSocket(), "192.168.0.3")
+	defer client2.Close()
+
+	respChan := make(chan *Response)
+	go func() {
+		resp, _ := client1.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/slow"))
+		respChan <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	errChan := make(chan error)
+	go func() {
+		errChan <- server.GracefulShutdown(time.Second)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 优雅停机中的新请求返回503
+	resp, _ := client2.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/slow"))
+	if resp.StatusCode() != StatusServiceUnavailable {
+		t.Errorf("want StatusServiceUnavailable got %v", resp.StatusCode())
+	}
+	// 处理中的请求正常完成
+	if resp := <-respChan; resp.StatusCode() != StatusOk {
+		t.Errorf("want StatusOk got %v", resp.StatusCode())
+	}
+	if err := <-errChan; err != nil {
+		t.Errorf("want graceful shutdown success got %v", err)
+	}
+	if _, err := client2.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/slow")); err != network.ErrConnectionRefuse {
+		t.Errorf("want ErrConnectionRefuse got %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package http
+
+import (
+	"demo/network"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultChunkSize = 1024
+	// streamWindow 流控窗口大小，单位为分片个数，发送端最多有streamWindow个分片未被接收端消费
+	streamWindow = 8
+	// streamTimeout 发送端等待窗口、接收端等待分片的超时时间
+	streamTimeout = 3 * time.Second
+	// streamTombstoneTtl 流关闭后保留墓碑的时间，期间迟到的分片直接丢弃
+	streamTombstoneTtl = 2 * streamTimeout
+)
+
+// stream id生成器
+var streamId uint32 = 0
+
+type StreamId uint32
+
+// StreamBody 流式body，作为Request/Response的body时，数据会从reader中分片读取，通过多个Chunk报文发送给对端
+// 接收端收到的Request/Response的body为*StreamReader
+type StreamBody struct {
+	id        StreamId
+	reader    io.Reader
+	chunkSize int
+}
+
+func NewStreamBody(reader io.Reader) *StreamBody {
+	return &StreamBody{
+		id:        StreamId(atomic.AddUint32(&streamId, 1)),
+		reader:    reader,
+		chunkSize: defaultChunkSize,
+	}
+}
+
+func (s *StreamBody) WithChunkSize(chunkSize int) *StreamBody {
+	s.chunkSize = chunkSize
+	return s
+}
+
+func (s *StreamBody) Id() StreamId {
+	return s.id
+}
+
+// Chunk 流式body的分片报文，seq从0开始递增，最后一个分片的eof为true
+type Chunk struct {
+	streamId StreamId
+	seq      uint32
+	data     []byte
+	eof      bool
+}
+
+func (c *Chunk) StreamId() StreamId {
+	return c.streamId
+}
+
+func (c *Chunk) Seq() uint32 {
+	return c.seq
+}
+
+func (c *Chunk) Data() []byte {
+	return c.data
+}
+
+func (c *Chunk) Eof() bool {
+	return c.eof
+}
+
+// WindowUpdate 接收端消费分片后，通知发送端扩大流控窗口
+type WindowUpdate struct {
+	streamId  StreamId
+	increment int
+}
+
+func (w *WindowUpdate) StreamId() StreamId {
+	return w.streamId
+}
+
+func (w *WindowUpdate) Increment() int {
+	return w.increment
+}
+
+// StreamReader 流式body的接收端，按seq顺序读取分片数据，实现了io.Reader接口
+type StreamReader struct {
+	id       StreamId
+	chunks   chan *Chunk
+	pending  map[uint32]*Chunk // 乱序到达的分片
+	nextSeq  uint32
+	buf      []byte
+	eof      bool
+	err      error // 不为nil时Read直接返回该错误
+	consumed int
+	// onConsume 消费分片后回调，用于发送WindowUpdate
+	onConsume func(increment int)
+	// onClose 读取结束后回调，用于清理资源
+	onClose   func()
+	closeOnce sync.Once
+}
+
+func newStreamReader(id StreamId) *StreamReader {
+	return &StreamReader{
+		id:      id,
+		chunks:  make(chan *Chunk, streamWindow+1),
+		pending: make(map[uint32]*Chunk),
+	}
+}
+
+func (s *StreamReader) Id() StreamId {
+	return s.id
+}
+
+func (s *StreamReader) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	for len(s.buf) == 0 {
+		if s.eof {
+			s.Close()
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			s.Close()
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// Close 结束读取，未读取的数据将被丢弃，重复关闭无副作用
+func (s *StreamReader) Close() error {
+	s.closeOnce.Do(func() {
+		if s.onClose != nil {
+			s.onClose()
+		}
+	})
+	return nil
+}
+
+// next 读取下一个seq的分片，消费一半窗口后通知发送端
+func (s *StreamReader) next() error {
+	chunk, ok := s.pending[s.nextSeq]
+	for !ok {
+		select {
+		case c := <-s.chunks:
+			s.pending[c.seq] = c
+			chunk, ok = s.pending[s.nextSeq]
+		case <-time.After(streamTimeout):
+			return ErrStreamTimeout
+		}
+	}
+	delete(s.pending, s.nextSeq)
+	s.nextSeq++
+	s.buf = chunk.data
+	s.eof = chunk.eof
+	s.consumed++
+	if s.consumed >= streamWindow/2 && !s.eof {
+		if s.onConsume != nil {
+			s.onConsume(s.consumed)
+		}
+		s.consumed = 0
+	}
+	return nil
+}
+
+// streamManager 管理一个Client/Server上所有的流式body，负责分片的发送和接收
+type streamManager struct {
+	socket  network.Socket
+	readers sync.Map // key为StreamId，value为*StreamReader
+	closed  sync.Map // key为StreamId，value为关闭时间，已关闭流的墓碑，避免迟到的分片重新创建StreamReader
+	windows sync.Map // key为StreamId，value为chan struct{}，作为发送端的流控信号量
+}
+
+func newStreamManager(socket network.Socket) *streamManager {
+	return &streamManager{socket: socket}
+}
+
+// reader 返回id对应的StreamReader，分片可能先于Request/Response到达，因此不存在时新建
+// local为接收端地址，peer为发送端地址；流已关闭时返回的StreamReader读取时返回ErrStreamClosed
+func (m *streamManager) reader(id StreamId, local, peer network.Endpoint) *StreamReader {
+	reader := newStreamReader(id)
+	if _, ok := m.closed.Load(id); ok {
+		reader.err = ErrStreamClosed
+		return reader
+	}
+	// 回调需要在存入readers之前设置，避免与并发获取到该reader的goroutine竞争
+	reader.onConsume = func(increment int) {
+		update := &WindowUpdate{streamId: id, increment: increment}
+		m.socket.Send(network.NewPacket(local, peer, update))
+	}
+	reader.onClose = func() {
+		m.close(id)
+	}
+	record, _ := m.readers.LoadOrStore(id, reader)
+	return record.(*StreamReader)
+}
+
+// close 删除StreamReader并留下墓碑，同时清理过期的墓碑
+func (m *streamManager) close(id StreamId) {
+	now := time.Now()
+	m.closed.Store(id, now)
+	m.readers.Delete(id)
+	m.closed.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(time.Time)) > streamTombstoneTtl {
+			m.closed.Delete(key)
+		}
+		return true
+	})
+}
+
+// receive 处理流式body相关的报文，非流式报文时返回false
+func (m *streamManager) receive(packet *network.Packet) bool {
+	switch payload := packet.Payload().(type) {
+	case *Chunk:
+		// 已关闭的流不再接收分片
+		if _, ok := m.closed.Load(payload.streamId); ok {
+			return true
+		}
+		m.reader(payload.streamId, packet.Dest(), packet.Src()).chunks <- payload
+		return true
+	case *WindowUpdate:
+		if record, ok := m.windows.Load(payload.streamId); ok {
+			window := record.(chan struct{})
+			for i := 0; i < payload.increment; i++ {
+				select {
+				case window <- struct{}{}:
+				default:
+				}
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// send 从body中分片读取数据发送给对端，窗口耗尽时等待接收端的WindowUpdate
+func (m *streamManager) send(local, peer network.Endpoint, body *StreamBody) error {
+	window := make(chan struct{}, streamWindow)
+	for i := 0; i < streamWindow; i++ {
+		window <- struct{}{}
+	}
+	m.windows.Store(body.id, window)
+	defer m.windows.Delete(body.id)
+
+	buf := make([]byte, body.chunkSize)
+	for seq := uint32(0); ; seq++ {
+		select {
+		case <-window:
+		case <-time.After(streamTimeout):
+			return ErrStreamTimeout
+		}
+		n, err := io.ReadFull(body.reader, buf)
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return err
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		chunk := &Chunk{streamId: body.id, seq: seq, data: data, eof: eof}
+		if err := m.socket.Send(network.NewPacket(local, peer, chunk)); err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"bytes"
+	"demo/network"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStreamBody(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	server := NewServer(network.DefaultSocket()).Listen("192.168.4.1", 80).
+		Get("/download", func(req *Request) *Response {
+			body := NewStreamBody(bytes.NewReader(data)).WithChunkSize(512)
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk).AddBody(body)
+		}).
+		Post("/upload", func(req *Request) *Response {
+			reader, ok := req.Body().(*StreamReader)
+			if !ok {
+				return ResponseOfId(req.ReqId()).AddStatusCode(StatusBadRequest)
+			}
+			upload, err := io.ReadAll(reader)
+			if err != nil {
+				return ResponseOfId(req.ReqId()).AddStatusCode(StatusInternalServerError).
+					AddProblemDetails(err.Error())
+			}
+			return ResponseOfId(req.ReqId()).AddStatusCode(StatusOk).
+				AddHeader("length", strconv.Itoa(len(upload)))
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+
+	client, err := NewClient(network.DefaultSocket(), "192.168.4.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	dest := network.EndpointOf("192.168.4.1", 80)
+
+	resp, err := client.Send(dest, EmptyRequest().AddMethod(GET).AddUri("/download"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, ok := resp.Body().(*StreamReader)
+	if !ok {
+		t.Fatalf("want *StreamReader got %T", resp.Body())
+	}
+	download, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(download, data) {
+		t.Errorf("want %d bytes got %d bytes", len(data), len(download))
+	}
+
+	req := EmptyRequest().AddMethod(POST).AddUri("/upload").
+		AddBody(NewStreamBody(bytes.NewReader(data)).WithChunkSize(256))
+	resp, err = client.Send(dest, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length, _ := resp.Header("length"); length != strconv.Itoa(len(data)) {
+		t.Errorf("want upload length %d got %s, %v", len(data), length, resp.ProblemDetails())
+	}
+}
+
+func TestStreamRejected(t *testing.T) {
+	server := NewServer(network.DefaultSocket()).Listen("192.168.4.3", 80)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+	client, err := NewClient(network.DefaultSocket(), "192.168.4.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// 请求没有handler处理时，关闭流式body的reader，之后到达的分片不会重新创建reader
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	req := EmptyRequest().AddMethod(POST).AddUri("/missing").
+		AddBody(NewStreamBody(bytes.NewReader(data)).WithChunkSize(256))
+	resp, err := client.Send(network.EndpointOf("192.168.4.3", 80), req)
+	if err != nil || resp.StatusCode() != StatusNotFound {
+		t.Fatalf("want StatusNotFound got %v, %v", resp, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	server.streams.readers.Range(func(key, value interface{}) bool {
+		t.Errorf("want no reader got stream %v", key)
+		return true
+	})
+	reader := server.streams.reader(req.Body().(*StreamBody).Id(), network.EndpointOf("192.168.4.3", 80), client.LocalEndpoint())
+	if _, err := reader.Read(make([]byte, 1)); err != ErrStreamClosed {
+		t.Errorf("want ErrStreamClosed got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package network
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 /*
 单例模式
@@ -9,6 +12,10 @@ import "sync"
 // 全局唯一的网络实例，模拟网络功能
 type network struct {
 	sockets sync.Map
+	hooksMu sync.RWMutex
+	hooks   []PacketHook
+	// topology 网络拓扑，为nil时任意endpoint之间都可以通信
+	topology atomic.Value
 }
 
 // 懒汉版单例模式
@@ -19,10 +26,9 @@ func Instance() *network {
 }
 
 func (n *network) Listen(endpoint Endpoint, socket Socket) error {
-	if _, ok := n.sockets.Load(endpoint); ok {
+	if _, loaded := n.sockets.LoadOrStore(endpoint, socket); loaded {
 		return ErrEndpointAlreadyListened
 	}
-	n.sockets.Store(endpoint, socket)
 	return nil
 }
 
@@ -34,12 +40,62 @@ func (n *network) DisconnectAll() {
 	n.sockets = sync.Map{}
 }
 
+// AddHook 增加报文钩子，如抓包
+func (n *network) AddHook(hook PacketHook) {
+	n.hooksMu.Lock()
+	defer n.hooksMu.Unlock()
+	n.hooks = append(n.hooks, hook)
+}
+
+func (n *network) RemoveHook(hook PacketHook) {
+	n.hooksMu.Lock()
+	defer n.hooksMu.Unlock()
+	for i, h := range n.hooks {
+		if h == hook {
+			n.hooks = append(n.hooks[:i:i], n.hooks[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetTopology 设置网络拓扑，为nil时取消拓扑限制
+func (n *network) SetTopology(topology *Topology) {
+	n.topology.Store(topologyHolder{topology: topology})
+}
+
+func (n *network) Topology() *Topology {
+	holder, _ := n.topology.Load().(topologyHolder)
+	return holder.topology
+}
+
 func (n *network) Send(packet *Packet) error {
+	if topology := n.Topology(); topology != nil {
+		src, dest, err := topology.Route(packet.Src(), packet.Dest())
+		if err != nil {
+			return err
+		}
+		packet = NewPacket(src, dest, packet.Payload())
+	}
+	// 路由成功后才回调钩子，不可达或被ACL拒绝的报文不会被抓包
+	n.hooksMu.RLock()
+	for _, hook := range n.hooks {
+		hook.OnPacket(packet)
+	}
+	n.hooksMu.RUnlock()
 	record, rOk := n.sockets.Load(packet.Dest())
 	socket, sOk := record.(Socket)
 	if !rOk || !sOk {
 		return ErrConnectionRefuse
 	}
+	// 具备接收队列的socket由其自身的worker处理报文，避免每个报文新起一个goroutine
+	if queue, ok := socket.(QueuedReceiver); ok {
+		return queue.Enqueue(packet)
+	}
 	go socket.Receive(packet)
 	return nil
 }
+
+// topologyHolder atomic.Value不能存储nil，因此包装一层
+type topologyHolder struct {
+	topology *Topology
+}
@@ -0,0 +1,172 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy 接收队列满时的处理策略
+type OverflowPolicy uint8
+
+const (
+	DropOnOverflow   OverflowPolicy = iota // 丢弃报文
+	BlockOnOverflow                        // 阻塞发送方，直到队列有空位
+	RejectOnOverflow                       // 通过Rejecter生成拒绝报文回复发送方，如HTTP 503
+)
+
+// Rejecter 根据被拒绝的报文生成回复报文，返回nil表示不回复
+type Rejecter func(packet *Packet) *Packet
+
+// QueuedReceiver 具备接收队列的socket，network会将报文放入其队列，而不是每个报文新起一个goroutine
+type QueuedReceiver interface {
+	Enqueue(packet *Packet) error
+}
+
+// QueueMetrics 接收队列统计数据
+type QueueMetrics struct {
+	Depth    int    // 当前队列深度
+	Capacity int    // 队列容量
+	Enqueued uint64 // 累计入队报文数
+	Dropped  uint64 // 累计丢弃报文数
+	Rejected uint64 // 累计拒绝报文数
+}
+
+/*
+装饰者模式
+*/
+
+// QueuedSocket 为Socket增加有界接收队列和固定大小的worker池，限制报文处理的goroutine数量
+// 注意，handler在worker中同步执行，如果handler需要等待同一socket上的后续报文（如流式body），worker数需要足够
+type QueuedSocket struct {
+	socket   Socket
+	capacity int
+	workers  int
+	policy   OverflowPolicy
+	rejecter Rejecter
+	mu       sync.RWMutex
+	queue    chan *Packet
+	stop     chan struct{}
+	enqueued uint64
+	dropped  uint64
+	rejected uint64
+}
+
+func NewQueuedSocket(socket Socket, capacity, workers int, policy OverflowPolicy) *QueuedSocket {
+	return &QueuedSocket{
+		socket:   socket,
+		capacity: capacity,
+		workers:  workers,
+		policy:   policy,
+	}
+}
+
+// WithRejecter 设置RejectOnOverflow策略下的拒绝报文生成方法
+func (q *QueuedSocket) WithRejecter(rejecter Rejecter) *QueuedSocket {
+	q.rejecter = rejecter
+	return q
+}
+
+func (q *QueuedSocket) Listen(endpoint Endpoint) error {
+	if err := Instance().Listen(endpoint, q); err != nil {
+		return err
+	}
+	q.start()
+	return nil
+}
+
+func (q *QueuedSocket) Close(endpoint Endpoint) {
+	q.socket.Close(endpoint)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		close(q.stop)
+		q.stop = nil
+	}
+}
+
+func (q *QueuedSocket) Send(packet *Packet) error {
+	return q.socket.Send(packet)
+}
+
+func (q *QueuedSocket) Receive(packet *Packet) {
+	q.socket.Receive(packet)
+}
+
+func (q *QueuedSocket) AddListener(listener SocketListener) {
+	q.socket.AddListener(listener)
+}
+
+// Enqueue 将报文放入接收队列，队列满时按照OverflowPolicy处理
+func (q *QueuedSocket) Enqueue(packet *Packet) error {
+	q.mu.RLock()
+	queue, stop := q.queue, q.stop
+	q.mu.RUnlock()
+	if stop == nil {
+		return ErrConnectionRefuse
+	}
+	select {
+	case queue <- packet:
+		atomic.AddUint64(&q.enqueued, 1)
+		return nil
+	default:
+	}
+	switch q.policy {
+	case BlockOnOverflow:
+		select {
+		case queue <- packet:
+			atomic.AddUint64(&q.enqueued, 1)
+			return nil
+		case <-stop:
+			return ErrConnectionRefuse
+		}
+	case RejectOnOverflow:
+		atomic.AddUint64(&q.rejected, 1)
+		if q.rejecter != nil {
+			if reply := q.rejecter(packet); reply != nil {
+				return q.socket.Send(reply)
+			}
+		}
+		return ErrReceiveQueueFull
+	default:
+		atomic.AddUint64(&q.dropped, 1)
+		return nil
+	}
+}
+
+func (q *QueuedSocket) Metrics() QueueMetrics {
+	q.mu.RLock()
+	depth := len(q.queue)
+	q.mu.RUnlock()
+	return QueueMetrics{
+		Depth:    depth,
+		Capacity: q.capacity,
+		Enqueued: atomic.LoadUint64(&q.enqueued),
+		Dropped:  atomic.LoadUint64(&q.dropped),
+		Rejected: atomic.LoadUint64(&q.rejected),
+	}
+}
+
+// start 启动worker池，重复Listen时只启动一次
+func (q *QueuedSocket) start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		return
+	}
+	q.queue = make(chan *Packet, q.capacity)
+	q.stop = make(chan struct{})
+	for i := 0; i < q.workers; i++ {
+		go q.work(q.queue, q.stop)
+	}
+}
+
+func (q *QueuedSocket) work(queue chan *Packet, stop chan struct{}) {
+	for {
+		select {
+		case packet := <-queue:
+			q.socket.Receive(packet)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+// Subnet 子网/VPC，由CIDR定义，可归属于某个region
+type Subnet struct {
+	name   string
+	cidr   *net.IPNet
+	region string
+}
+
+func (s *Subnet) Name() string {
+	return s.name
+}
+
+func (s *Subnet) Region() string {
+	return s.region
+}
+
+// Contains 判断ip是否属于该子网
+func (s *Subnet) Contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && s.cidr.Contains(parsed)
+}
+
+// AclAction ACL规则动作
+type AclAction uint8
+
+const (
+	Allow AclAction = iota
+	Deny
+)
+
+// AclRule ACL规则，src和dest为CIDR，port为0时匹配所有端口
+type AclRule struct {
+	action AclAction
+	src    *net.IPNet
+	dest   *net.IPNet
+	port   int
+}
+
+func (a AclRule) match(src, dest Endpoint) bool {
+	srcIp, destIp := net.ParseIP(src.Ip()), net.ParseIP(dest.Ip())
+	if srcIp == nil || destIp == nil {
+		return false
+	}
+	return a.src.Contains(srcIp) && a.dest.Contains(destIp) && (a.port == 0 || a.port == dest.Port())
+}
+
+// Router 路由器，连接多个子网，并提供ACL和静态NAT功能
+// 路由器的规则与所属拓扑共用一把锁，修改规则时不会与路由计算并发
+type Router struct {
+	mu      *sync.RWMutex
+	name    string
+	subnets []*Subnet
+	rules   []AclRule
+	nat     map[string]string // key为公网ip，value为私网ip
+}
+
+func (r *Router) Name() string {
+	return r.name
+}
+
+// Allow 增加允许规则，规则按添加顺序匹配，都不匹配时默认允许
+func (r *Router) Allow(srcCidr, destCidr string, port int) error {
+	return r.addRule(Allow, srcCidr, destCidr, port)
+}
+
+// Deny 增加拒绝规则，规则按添加顺序匹配，都不匹配时默认允许
+func (r *Router) Deny(srcCidr, destCidr string, port int) error {
+	return r.addRule(Deny, srcCidr, destCidr, port)
+}
+
+// AddNat 增加静态NAT映射，发往publicIp的报文会被转换到privateIp，privateIp的回包源地址会被转换为publicIp
+func (r *Router) AddNat(publicIp, privateIp string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nat[publicIp] = privateIp
+}
+
+func (r *Router) addRule(action AclAction, srcCidr, destCidr string, port int) error {
+	_, src, err := net.ParseCIDR(srcCidr)
+	if err != nil {
+		return err
+	}
+	_, dest, err := net.ParseCIDR(destCidr)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, AclRule{action: action, src: src, dest: dest, port: port})
+	return nil
+}
+
+func (r *Router) permit(src, dest Endpoint) bool {
+	for _, rule := range r.rules {
+		if rule.match(src, dest) {
+			return rule.action == Allow
+		}
+	}
+	return true
+}
+
+func (r *Router) connects(subnet *Subnet) bool {
+	for _, s := range r.subnets {
+		if s == subnet {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Router) publicIpOf(privateIp string) (string, bool) {
+	for public, private := range r.nat {
+		if private == privateIp {
+			return public, true
+		}
+	}
+	return "", false
+}
+
+// Topology 网络拓扑，由子网和连接子网的路由器组成
+// 同一子网内的endpoint之间可以直接通信，不同子网间需要经过路由器，不属于任何子网的ip不可达
+type Topology struct {
+	mu      sync.RWMutex
+	subnets []*Subnet
+	routers []*Router
+}
+
+func NewTopology() *Topology {
+	return &Topology{}
+}
+
+// AddSubnet 增加子网，region为子网所在的region id，可为空
+func (t *Topology) AddSubnet(name, cidr, region string) (*Subnet, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	subnet := &Subnet{name: name, cidr: ipNet, region: region}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subnets = append(t.subnets, subnet)
+	return subnet, nil
+}
+
+// AddRouter 增加连接subnets的路由器
+func (t *Topology) AddRouter(name string, subnets ...*Subnet) *Router {
+	router := &Router{mu: &t.mu, name: name, subnets: subnets, nat: make(map[string]string)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routers = append(t.routers, router)
+	return router
+}
+
+// SubnetOf 返回ip所属的子网
+func (t *Topology) SubnetOf(ip string) (*Subnet, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.subnetOf(ip)
+}
+
+// RegionOf 返回ip所属子网的region
+func (t *Topology) RegionOf(ip string) (string, bool) {
+	subnet, ok := t.SubnetOf(ip)
+	if !ok || subnet.region == "" {
+		return "", false
+	}
+	return subnet.region, true
+}
+
+// Reachable 判断src能否访问dest
+func (t *Topology) Reachable(src, dest Endpoint) error {
+	_, _, err := t.Route(src, dest)
+	return err
+}
+
+// Route 计算src到dest的路由，返回经过NAT转换后的src和dest
+func (t *Topology) Route(src, dest Endpoint) (Endpoint, Endpoint, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	srcSubnet, ok := t.subnetOf(src.Ip())
+	if !ok {
+		return src, dest, ErrNetworkUnreachable
+	}
+	// 目的地址为公网ip时，进行目的地址转换
+	if _, ok := t.subnetOf(dest.Ip()); !ok {
+		translated, ok := t.dnat(srcSubnet, dest)
+		if !ok {
+			return src, dest, ErrNetworkUnreachable
+		}
+		dest = translated
+	}
+	destSubnet, ok := t.subnetOf(dest.Ip())
+	if !ok {
+		return src, dest, ErrNetworkUnreachable
+	}
+	if srcSubnet == destSubnet {
+		return src, dest, nil
+	}
+	path, ok := t.path(srcSubnet, destSubnet)
+	if !ok {
+		return src, dest, ErrNetworkUnreachable
+	}
+	translatedSrc := src
+	for _, router := range path {
+		if !router.permit(src, dest) {
+			return src, dest, ErrAclDenied
+		}
+		// 跨子网时，私网源地址转换为公网地址
+		if public, ok := router.publicIpOf(src.Ip()); ok {
+			translatedSrc = EndpointOf(public, src.Port())
+		}
+	}
+	return translatedSrc, dest, nil
+}
+
+// dnat 目的地址转换，只有src子网到私网ip所在子网路径上的路由器才能转换
+func (t *Topology) dnat(srcSubnet *Subnet, dest Endpoint) (Endpoint, bool) {
+	for _, router := range t.routers {
+		private, ok := router.nat[dest.Ip()]
+		if !ok {
+			continue
+		}
+		privateSubnet, ok := t.subnetOf(private)
+		if !ok {
+			continue
+		}
+		if t.isOnPath(router, srcSubnet, privateSubnet) {
+			return EndpointOf(private, dest.Port()), true
+		}
+	}
+	return dest, false
+}
+
+// isOnPath 路由器是否在from子网到to子网的路径上，同一子网时路由器需要连接该子网
+func (t *Topology) isOnPath(router *Router, from, to *Subnet) bool {
+	if from == to {
+		return router.connects(from)
+	}
+	path, ok := t.path(from, to)
+	if !ok {
+		return false
+	}
+	for _, r := range path {
+		if r == router {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Topology) subnetOf(ip string) (*Subnet, bool) {
+	for _, subnet := range t.subnets {
+		if subnet.Contains(ip) {
+			return subnet, true
+		}
+	}
+	return nil, false
+}
+
+// path 广度优先搜索从from子网到to子网需要经过的路由器
+func (t *Topology) path(from, to *Subnet) ([]*Router, bool) {
+	type node struct {
+		subnet *Subnet
+		path   []*Router
+	}
+	visited := map[*Subnet]bool{from: true}
+	queue := []node{{subnet: from}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, router := range t.routers {
+			if !router.connects(cur.subnet) {
+				continue
+			}
+			for _, next := range router.subnets {
+				if visited[next] {
+					continue
+				}
+				path := append(append([]*Router{}, cur.path...), router)
+				if next == to {
+					return path, true
+				}
+				visited[next] = true
+				queue = append(queue, node{subnet: next, path: path})
+			}
+		}
+	}
+	return nil, false
+}
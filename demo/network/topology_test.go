@@ -0,0 +1,134 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+)
+
+type recordListener struct {
+	packets chan *Packet
+}
+
+func (r *recordListener) Handle(packet *Packet) error {
+	r.packets <- packet
+	return nil
+}
+
+func TestTopology(t *testing.T) {
+	topology := NewTopology()
+	vpc1, _ := topology.AddSubnet("vpc-1", "192.168.10.0/24", "region-1")
+	vpc2, _ := topology.AddSubnet("vpc-2", "10.0.10.0/24", "region-2")
+	vpc3, _ := topology.AddSubnet("vpc-3", "10.0.20.0/24", "region-2")
+	router := topology.AddRouter("router-1", vpc1, vpc2)
+	router.AddNat("172.16.0.1", "10.0.10.1")
+	router.Deny("10.0.10.0/24", "192.168.10.0/24", 8080)
+	Instance().SetTopology(topology)
+	defer Instance().SetTopology(nil)
+
+	if region, _ := topology.RegionOf("10.0.10.1"); region != "region-2" {
+		t.Errorf("want region-2 got %s", region)
+	}
+	if err := topology.Reachable(EndpointOf("192.168.10.1", 80), EndpointOf("10.0.20.1", 80)); err != ErrNetworkUnreachable {
+		t.Errorf("want ErrNetworkUnreachable got %v", err)
+	}
+	if err := topology.Reachable(EndpointOf("10.0.10.1", 80), EndpointOf("192.168.10.1", 8080)); err != ErrAclDenied {
+		t.Errorf("want ErrAclDenied got %v", err)
+	}
+	// vpc-3通过router-2与vpc-2相连后，可以从vpc-1经过两跳到达
+	topology.AddRouter("router-2", vpc2, vpc3)
+	if err := topology.Reachable(EndpointOf("192.168.10.1", 80), EndpointOf("10.0.20.1", 80)); err != nil {
+		t.Errorf("want reachable got %v", err)
+	}
+
+	server := &recordListener{packets: make(chan *Packet, 1)}
+	serverSocket := DefaultSocket()
+	serverSocket.AddListener(server)
+	serverSocket.Listen(EndpointOf("10.0.10.1", 80))
+	defer serverSocket.Close(EndpointOf("10.0.10.1", 80))
+	client := &recordListener{packets: make(chan *Packet, 1)}
+	clientSocket := DefaultSocket()
+	clientSocket.AddListener(client)
+	clientSocket.Listen(EndpointOf("192.168.10.1", 10000))
+	defer clientSocket.Close(EndpointOf("192.168.10.1", 10000))
+
+	// 通过公网ip访问，目的地址被转换为私网ip
+	err := clientSocket.Send(NewPacket(EndpointOf("192.168.10.1", 10000), EndpointOf("172.16.0.1", 80), "req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-server.packets
+	if req.Dest() != EndpointOf("10.0.10.1", 80) {
+		t.Errorf("want dest 10.0.10.1:80 got %s", req.Dest())
+	}
+	// 回包的源地址被转换为公网ip
+	serverSocket.Send(NewPacket(req.Dest(), req.Src(), "resp"))
+	resp := <-client.packets
+	if resp.Src() != EndpointOf("172.16.0.1", 80) {
+		t.Errorf("want src 172.16.0.1:80 got %s", resp.Src())
+	}
+	if err := clientSocket.Send(NewPacket(EndpointOf("192.168.10.1", 10000), EndpointOf("8.8.8.8", 80), "req")); err != ErrNetworkUnreachable {
+		t.Errorf("want ErrNetworkUnreachable got %v", err)
+	}
+}
+
+func TestTopologyNatOnPath(t *testing.T) {
+	topology := NewTopology()
+	vpc1, _ := topology.AddSubnet("vpc-1", "192.168.10.0/24", "")
+	vpc2, _ := topology.AddSubnet("vpc-2", "10.0.10.0/24", "")
+	vpc3, _ := topology.AddSubnet("vpc-3", "10.0.20.0/24", "")
+	vpc4, _ := topology.AddSubnet("vpc-4", "10.0.30.0/24", "")
+	topology.AddRouter("router-1", vpc1, vpc2)
+	router := topology.AddRouter("router-2", vpc3, vpc4)
+	router.AddNat("172.16.0.2", "10.0.30.1")
+
+	// router-2不在vpc-1到vpc-4的路径上，不能进行目的地址转换
+	if err := topology.Reachable(EndpointOf("192.168.10.1", 80), EndpointOf("172.16.0.2", 80)); err != ErrNetworkUnreachable {
+		t.Errorf("want ErrNetworkUnreachable got %v", err)
+	}
+	_, dest, err := topology.Route(EndpointOf("10.0.20.1", 80), EndpointOf("172.16.0.2", 80))
+	if err != nil || dest != EndpointOf("10.0.30.1", 80) {
+		t.Errorf("want dest 10.0.30.1:80 got %s, %v", dest, err)
+	}
+
+	// 修改规则与路由计算可以并发进行
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			router.Deny("10.0.20.0/24", "10.0.30.0/24", 8000+i)
+			router.AddNat("172.16.0.3", "10.0.30.3")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		topology.Reachable(EndpointOf("10.0.20.1", 80), EndpointOf("172.16.0.2", 80))
+	}
+	<-done
+}
+
+func TestTopologyCapture(t *testing.T) {
+	topology := NewTopology()
+	vpc1, _ := topology.AddSubnet("vpc-1", "192.168.20.0/24", "")
+	vpc2, _ := topology.AddSubnet("vpc-2", "10.0.40.0/24", "")
+	router := topology.AddRouter("router-1", vpc1, vpc2)
+	router.AddNat("172.16.0.4", "10.0.40.1")
+	router.Deny("192.168.20.0/24", "10.0.40.0/24", 22)
+	Instance().SetTopology(topology)
+	defer Instance().SetTopology(nil)
+	buf := &bytes.Buffer{}
+	capture := NewPacketCapture(buf)
+	Instance().AddHook(capture)
+	defer Instance().RemoveHook(capture)
+
+	// 不可达和被ACL拒绝的报文不会被抓包，抓到的是NAT转换后的报文
+	src := EndpointOf("192.168.20.1", 10000)
+	Instance().Send(NewPacket(src, EndpointOf("8.8.8.8", 80), "req"))
+	Instance().Send(NewPacket(src, EndpointOf("172.16.0.4", 22), "req"))
+	Instance().Send(NewPacket(src, EndpointOf("172.16.0.4", 80), "req"))
+	records, err := ReadCapture(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Dest != EndpointOf("10.0.40.1", 80) {
+		t.Errorf("want 1 record to 10.0.40.1:80 got %+v", records)
+	}
+}
@@ -0,0 +1,235 @@
+package broker
+
+import (
+	"context"
+	"demo/mq"
+	"demo/network"
+	"demo/network/http"
+	"demo/service"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	produceUri = "/api/v1/mq/produce"
+	consumeUri = "/api/v1/mq/consume"
+	ackUri     = "/api/v1/mq/ack"
+	nackUri    = "/api/v1/mq/nack"
+	// maxPollWait 消费请求的最长等待时间，需小于http.Client的响应超时时间
+	maxPollWait = time.Second
+	// maxProduceWait topic积压满时生产请求的最长等待时间，需小于http.Client的响应超时时间，避免客户端超时后消息仍被生产
+	maxProduceWait = time.Second
+	// deliveryTtl 投递未确认时在broker上保留的时间，超过后只能等待消息重新投递
+	deliveryTtl = 5 * time.Minute
+	// mqErrorHeader 错误响应中标识mq错误的header，值为mqClosed时表示mq已关闭，
+	// 没有该header的503来自http.Server的关闭或过载保护，稍后可以重试
+	mqErrorHeader = "mq-error"
+	mqClosed      = "closed"
+)
+
+// ProduceBody 生产请求的body
+type ProduceBody struct {
+	Messages []*mq.Message `json:"messages"`
+	At       time.Time     `json:"at,omitempty"` // 定时消息的到期时间，零值表示立即生产
+}
+
+// ConsumeBody 消费响应的body，消费组消费时Deliveries与Messages一一对应
+type ConsumeBody struct {
+	Messages   []*mq.Message  `json:"messages"`
+	Deliveries []DeliveryInfo `json:"deliveries,omitempty"`
+}
+
+// DeliveryInfo 消费组投递信息，Ack/Nack时通过Id确认
+type DeliveryInfo struct {
+	Id       string `json:"id"`
+	Offset   int64  `json:"offset"`
+	Attempts int    `json:"attempts"`
+}
+
+// AckBody Ack/Nack请求的body
+type AckBody struct {
+	Id string `json:"id"`
+}
+
+func init() {
+	http.RegisterBodyType(new(ProduceBody))
+	http.RegisterBodyType(new(ConsumeBody))
+	http.RegisterBodyType(new(AckBody))
+}
+
+// pendingDelivery 等待Ack/Nack的投递
+type pendingDelivery struct {
+	delivery *mq.Delivery
+	expireAt time.Time
+}
+
+// Broker 消息队列服务，通过http.Server对外提供生产、消费、确认接口，使不同主机上的sidecar和监控系统共享同一个消息队列
+type Broker struct {
+	queue      mq.Mq
+	server     *http.Server
+	localIp    string
+	deliveries sync.Map // key为投递id，value为*pendingDelivery
+}
+
+func NewBroker(localIp string, queue mq.Mq, socket network.Socket) *Broker {
+	return &Broker{
+		queue:   queue,
+		server:  http.NewServer(socket).Listen(localIp, 80),
+		localIp: localIp,
+	}
+}
+
+func (b *Broker) Run() error {
+	return b.server.Post(produceUri, b.produce).
+		Get(consumeUri, b.consume).
+		Post(ackUri, b.ack).
+		Post(nackUri, b.nack).
+		Start()
+}
+
+func (b *Broker) Endpoint() network.Endpoint {
+	return network.EndpointOf(b.localIp, 80)
+}
+
+func (b *Broker) Shutdown() error {
+	return b.server.GracefulShutdown(service.ShutdownTimeout)
+}
+
+// 生产消息，body为*ProduceBody
+func (b *Broker) produce(req *http.Request) *http.Response {
+	body, ok := req.Body().(*ProduceBody)
+	if !ok {
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusBadRequest).
+			AddProblemDetails("produce request's body is not *ProduceBody")
+	}
+	var err error
+	if body.At.IsZero() {
+		ctx, cancel := context.WithTimeout(context.Background(), maxProduceWait)
+		err = b.queue.ProduceBatchContext(ctx, body.Messages...)
+		cancel()
+	} else {
+		for _, message := range body.Messages {
+			if err = b.queue.ProduceAt(message, body.At); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return errorResponse(req, err)
+	}
+	return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusCreate)
+}
+
+// 消费消息，query参数：topic，group为空时点对点消费，n为最多消费的条数，wait为最长等待的毫秒数
+// 点对点消费时消息在发送响应前已从队列中移除，响应丢失（如客户端超时）时消息也随之丢失，即至多一次；
+// 需要至少一次时使用消费组，未Ack的消息会被重新投递
+func (b *Broker) consume(req *http.Request) *http.Response {
+	topic, ok := req.QueryParam("topic")
+	if !ok || topic == "" {
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusBadRequest).
+			AddProblemDetails("consume request not contain topic")
+	}
+	n := intParam(req, "n", 1)
+	wait := time.Duration(intParam(req, "wait", 0)) * time.Millisecond
+	if wait > maxPollWait {
+		wait = maxPollWait
+	}
+	group, _ := req.QueryParam("group")
+	if group == "" {
+		messages, err := b.queue.ConsumeN(mq.Topic(topic), n, wait)
+		if err != nil {
+			return errorResponse(req, err)
+		}
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusOk).
+			AddBody(&ConsumeBody{Messages: messages})
+	}
+	b.sweep()
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	body := &ConsumeBody{}
+	for len(body.Messages) < n {
+		delivery, err := b.queue.ConsumeGroupContext(ctx, group, mq.Topic(topic))
+		if err != nil && ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return errorResponse(req, err)
+		}
+		id := uuid.NewString()
+		b.deliveries.Store(id, &pendingDelivery{delivery: delivery, expireAt: time.Now().Add(deliveryTtl)})
+		body.Messages = append(body.Messages, delivery.Message())
+		body.Deliveries = append(body.Deliveries, DeliveryInfo{
+			Id:       id,
+			Offset:   delivery.Offset(),
+			Attempts: delivery.Attempts(),
+		})
+		// 已有消息时不再等待
+		cancel()
+	}
+	return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusOk).AddBody(body)
+}
+
+// 确认消息，body为*AckBody
+func (b *Broker) ack(req *http.Request) *http.Response {
+	return b.settle(req, (*mq.Delivery).Ack)
+}
+
+// 消息处理失败，body为*AckBody
+func (b *Broker) nack(req *http.Request) *http.Response {
+	return b.settle(req, (*mq.Delivery).Nack)
+}
+
+func (b *Broker) settle(req *http.Request, settle func(delivery *mq.Delivery) error) *http.Response {
+	body, ok := req.Body().(*AckBody)
+	if !ok {
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusBadRequest).
+			AddProblemDetails("ack request's body is not *AckBody")
+	}
+	record, ok := b.deliveries.LoadAndDelete(body.Id)
+	if !ok {
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusGone).
+			AddProblemDetails("delivery " + body.Id + " not exist or expired")
+	}
+	if err := settle(record.(*pendingDelivery).delivery); err != nil {
+		return errorResponse(req, err)
+	}
+	return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusNoContent)
+}
+
+// sweep 清理过期未确认的投递
+func (b *Broker) sweep() {
+	now := time.Now()
+	b.deliveries.Range(func(key, value interface{}) bool {
+		if now.After(value.(*pendingDelivery).expireAt) {
+			b.deliveries.Delete(key)
+		}
+		return true
+	})
+}
+
+func intParam(req *http.Request, key string, defaultValue int) int {
+	value, ok := req.QueryParam(key)
+	if !ok {
+		return defaultValue
+	}
+	if iVal, err := strconv.Atoi(value); err == nil && iVal >= 0 {
+		return iVal
+	}
+	return defaultValue
+}
+
+// errorResponse 将mq的错误转换为http响应
+func errorResponse(req *http.Request, err error) *http.Response {
+	resp := http.ResponseOfId(req.ReqId()).AddProblemDetails(err.Error())
+	switch err {
+	case mq.ErrTopicFull:
+		return resp.AddStatusCode(http.StatusTooManyRequest).AddHeader(http.RetryAfterHeader, "1")
+	case mq.ErrMqClosed:
+		return resp.AddStatusCode(http.StatusServiceUnavailable).AddHeader(mqErrorHeader, mqClosed)
+	default:
+		return resp.AddStatusCode(http.StatusInternalServerError)
+	}
+}
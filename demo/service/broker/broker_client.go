@@ -0,0 +1,209 @@
+package broker
+
+import (
+	"context"
+	"demo/mq"
+	"demo/network"
+	"demo/network/http"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrBrokerUnavailable broker正在关闭或过载，可以稍后重试
+	ErrBrokerUnavailable = errors.New("broker is unavailable")
+)
+
+// Client 消息队列服务的客户端，实现了mq.Mq接口，可以替代mq.MemoryMqInstance()在不同主机间共享消息队列
+type Client struct {
+	localIp string
+	broker  network.Endpoint
+	pool    *http.ClientPool
+}
+
+// NewClient localIp为客户端所在主机的ip，broker为消息队列服务的endpoint
+// 客户端直接使用network.DefaultSocket，避免AccessLogSidecar上报access log时递归产生新的access log
+func NewClient(localIp string, broker network.Endpoint) *Client {
+	return &Client{
+		localIp: localIp,
+		broker:  broker,
+		pool: http.NewClientPool(func() network.Socket {
+			return network.DefaultSocket()
+		}),
+	}
+}
+
+func (c *Client) Close() {
+	c.pool.Close()
+}
+
+func (c *Client) Consume(topic mq.Topic) (*mq.Message, error) {
+	return c.ConsumeContext(context.Background(), topic)
+}
+
+// ConsumeContext 通过长轮询消费消息，直到有消息或ctx结束；点对点消费为至多一次，响应丢失时消息也随之丢失
+func (c *Client) ConsumeContext(ctx context.Context, topic mq.Topic) (*mq.Message, error) {
+	for {
+		body, err := c.consume(topic, "", 1, pollWait(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if len(body.Messages) > 0 {
+			return body.Messages[0], nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) TryConsume(topic mq.Topic) (*mq.Message, error) {
+	body, err := c.consume(topic, "", 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(body.Messages) == 0 {
+		return nil, mq.ErrNoMessage
+	}
+	return body.Messages[0], nil
+}
+
+func (c *Client) ConsumeN(topic mq.Topic, n int, wait time.Duration) ([]*mq.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	var messages []*mq.Message
+	for len(messages) < n {
+		body, err := c.consume(topic, "", n-len(messages), pollWait(ctx))
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, body.Messages...)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return messages, nil
+}
+
+func (c *Client) ConsumeGroup(group string, topic mq.Topic) (*mq.Delivery, error) {
+	return c.ConsumeGroupContext(context.Background(), group, topic)
+}
+
+func (c *Client) ConsumeGroupContext(ctx context.Context, group string, topic mq.Topic) (*mq.Delivery, error) {
+	for {
+		body, err := c.consume(topic, group, 1, pollWait(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if len(body.Messages) > 0 && len(body.Deliveries) > 0 {
+			return c.deliveryOf(group, body.Messages[0], body.Deliveries[0]), nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) Produce(message *mq.Message) error {
+	return c.ProduceBatch(message)
+}
+
+func (c *Client) ProduceBatch(messages ...*mq.Message) error {
+	return c.produce(&ProduceBody{Messages: messages})
+}
+
+// ProduceBatchContext ctx只在发送请求前检查，topic积压满时broker最多等待maxProduceWait
+func (c *Client) ProduceBatchContext(ctx context.Context, messages ...*mq.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ProduceBatch(messages...)
+}
+
+func (c *Client) ProduceAt(message *mq.Message, at time.Time) error {
+	return c.produce(&ProduceBody{Messages: []*mq.Message{message}, At: at})
+}
+
+func (c *Client) ProduceAfter(message *mq.Message, delay time.Duration) error {
+	return c.ProduceAt(message, time.Now().Add(delay))
+}
+
+func (c *Client) produce(body *ProduceBody) error {
+	req := http.EmptyRequest().AddMethod(http.POST).AddUri(produceUri).AddBody(body)
+	_, err := c.send(req)
+	return err
+}
+
+func (c *Client) consume(topic mq.Topic, group string, n int, wait time.Duration) (*ConsumeBody, error) {
+	req := http.EmptyRequest().AddMethod(http.GET).AddUri(consumeUri).
+		AddQueryParam("topic", string(topic)).
+		AddQueryParam("n", strconv.Itoa(n)).
+		AddQueryParam("wait", strconv.FormatInt(wait.Milliseconds(), 10))
+	if group != "" {
+		req.AddQueryParam("group", group)
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	body, ok := resp.Body().(*ConsumeBody)
+	if !ok {
+		return nil, fmt.Errorf("consume response's body is not *ConsumeBody but %T", resp.Body())
+	}
+	return body, nil
+}
+
+func (c *Client) deliveryOf(group string, message *mq.Message, info DeliveryInfo) *mq.Delivery {
+	settle := func(uri http.Uri) func() error {
+		return func() error {
+			req := http.EmptyRequest().AddMethod(http.POST).AddUri(uri).AddBody(&AckBody{Id: info.Id})
+			_, err := c.send(req)
+			return err
+		}
+	}
+	return mq.NewDelivery(message, group, info.Offset, info.Attempts, settle(ackUri), settle(nackUri))
+}
+
+// send 从连接池中获取http.Client发送请求，并将错误响应转换为mq的错误
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	client, err := c.pool.Get(c.localIp)
+	if err != nil {
+		return nil, err
+	}
+	defer c.pool.Put(client)
+	resp, err := client.Send(c.broker, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsSuccess() {
+		return resp, nil
+	}
+	switch resp.StatusCode() {
+	case http.StatusTooManyRequest:
+		return nil, mq.ErrTopicFull
+	case http.StatusServiceUnavailable:
+		if value, _ := resp.Header(mqErrorHeader); value == mqClosed {
+			return nil, mq.ErrMqClosed
+		}
+		return nil, fmt.Errorf("%w: %s", ErrBrokerUnavailable, resp.ProblemDetails())
+	default:
+		return nil, fmt.Errorf("broker response %d: %s", resp.StatusCode().Code, resp.ProblemDetails())
+	}
+}
+
+// pollWait 长轮询的等待时间，不超过maxPollWait和ctx的剩余时间
+func pollWait(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return maxPollWait
+	}
+	if wait := time.Until(deadline); wait < maxPollWait {
+		if wait < 0 {
+			return 0
+		}
+		return wait
+	}
+	return maxPollWait
+}
@@ -0,0 +1,163 @@
+package broker
+
+import (
+	"demo/mq"
+	"demo/network"
+	"demo/network/http"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBroker(t *testing.T, ip string) *Broker {
+	queue, err := mq.NewFileMq(t.TempDir(), mq.DefaultTopicConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewBroker(ip, queue, network.DefaultSocket())
+	if err := broker.Run(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = broker.Shutdown()
+		_ = queue.Close()
+	})
+	return broker
+}
+
+func TestBrokerProduceConsume(t *testing.T) {
+	broker := newTestBroker(t, "192.168.0.50")
+	producer := NewClient("192.168.0.51", broker.Endpoint())
+	defer producer.Close()
+	consumer := NewClient("192.168.0.52", broker.Endpoint())
+	defer consumer.Close()
+
+	msg := mq.NewMessage("broker.test", "hello").WithKey("k1").AddHeader("type", "recv_req")
+	if err := producer.Produce(msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := consumer.Consume("broker.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := got.Header("type"); got.Payload() != "hello" || got.Key() != "k1" || typ != "recv_req" {
+		t.Fatalf("want hello/k1/recv_req got %s/%s/%s", got.Payload(), got.Key(), typ)
+	}
+	if _, err := consumer.TryConsume("broker.test"); err != mq.ErrNoMessage {
+		t.Fatalf("want ErrNoMessage got %v", err)
+	}
+
+	if err := producer.ProduceBatch(mq.NewMessage("broker.test", "1"), mq.NewMessage("broker.test", "2")); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := consumer.ConsumeN("broker.test", 2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Payload() != "1" || messages[1].Payload() != "2" {
+		t.Fatalf("want [1 2] got %v", messages)
+	}
+}
+
+func TestBrokerConsumeGroup(t *testing.T) {
+	broker := newTestBroker(t, "192.168.0.53")
+	client := NewClient("192.168.0.54", broker.Endpoint())
+	defer client.Close()
+
+	if err := client.Produce(mq.NewMessage("broker.group", "hello")); err != nil {
+		t.Fatal(err)
+	}
+	delivery, err := client.ConsumeGroup("g1", "broker.group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Message().Payload() != "hello" || delivery.Attempts() != 1 {
+		t.Fatalf("want hello with 1 attempt got %s with %d", delivery.Message().Payload(), delivery.Attempts())
+	}
+	if err := delivery.Nack(); err != nil {
+		t.Fatal(err)
+	}
+	delivery, err = client.ConsumeGroup("g1", "broker.group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Attempts() != 2 {
+		t.Fatalf("want 2 attempts got %d", delivery.Attempts())
+	}
+	if err := delivery.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := delivery.Ack(); err == nil {
+		t.Fatal("want error when ack twice")
+	}
+}
+
+func TestBrokerProduceAfter(t *testing.T) {
+	broker := newTestBroker(t, "192.168.0.55")
+	client := NewClient("192.168.0.56", broker.Endpoint())
+	defer client.Close()
+
+	start := time.Now()
+	if err := client.ProduceAfter(mq.NewMessage("broker.delay", "later"), 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.TryConsume("broker.delay"); err != mq.ErrNoMessage {
+		t.Fatalf("want ErrNoMessage got %v", err)
+	}
+	msg, err := client.Consume("broker.delay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Payload() != "later" || time.Since(start) < 200*time.Millisecond {
+		t.Fatalf("want later after 200ms got %s after %v", msg.Payload(), time.Since(start))
+	}
+}
+
+func TestBrokerProduceFull(t *testing.T) {
+	broker := newTestBroker(t, "192.168.0.57")
+	conf := mq.DefaultTopicConfig()
+	conf.Capacity, conf.ProduceTimeout = 1, 10*time.Second
+	if err := broker.queue.(mq.Admin).CreateTopic("full", conf); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("192.168.0.58", broker.Endpoint())
+	defer client.Close()
+	if err := client.Produce(mq.NewMessage("full", "1")); err != nil {
+		t.Fatal(err)
+	}
+	// topic积压满时broker最多等待maxProduceWait，在客户端超时前返回ErrTopicFull
+	start := time.Now()
+	if err := client.Produce(mq.NewMessage("full", "2")); err != mq.ErrTopicFull {
+		t.Errorf("want ErrTopicFull, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*maxProduceWait {
+		t.Errorf("want produce return within %s, got %s", 2*maxProduceWait, elapsed)
+	}
+}
+
+func TestBrokerUnavailable(t *testing.T) {
+	broker := newTestBroker(t, "192.168.0.62")
+	client := NewClient("192.168.0.63", broker.Endpoint())
+	defer client.Close()
+	// 只有mq关闭时返回ErrMqClosed
+	_ = broker.queue.(*mq.FileMq).Close()
+	if err := client.Produce(mq.NewMessage("test", "1")); err != mq.ErrMqClosed {
+		t.Errorf("want ErrMqClosed, got %v", err)
+	}
+
+	// 其他的503可以重试
+	server := http.NewServer(network.DefaultSocket()).Listen("192.168.0.64", 80).
+		Post(produceUri, func(req *http.Request) *http.Response {
+			return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusServiceUnavailable).
+				AddProblemDetails("server is overloaded")
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+	overloaded := NewClient("192.168.0.63", network.EndpointOf("192.168.0.64", 80))
+	defer overloaded.Close()
+	if err := overloaded.Produce(mq.NewMessage("test", "1")); !errors.Is(err, ErrBrokerUnavailable) {
+		t.Errorf("want ErrBrokerUnavailable, got %v", err)
+	}
+}
@@ -3,12 +3,18 @@ package mediator
 import (
 	"demo/network"
 	"demo/network/http"
+	"demo/service"
 	"demo/service/registry/model"
 	"demo/sidecar"
 	"errors"
 	"strings"
 )
 
+// 注册服务发现响应的body类型，抓包回放时可以还原
+func init() {
+	http.RegisterBodyType(new(model.ServiceProfile))
+}
+
 // ServiceMediator 服务中介，根据mediator-uri，向Registry发现对端地址，转发请求
 // 其中，mediator-uri的形式为/{serviceType}+ServiceUri
 type ServiceMediator struct {
@@ -16,6 +22,8 @@ type ServiceMediator struct {
 	localIp          string
 	server           *http.Server
 	sidecarFactory   sidecar.Factory
+	breakers         *http.CircuitBreakers // 所有转发请求共享的熔断器
+	clientPool       *http.ClientPool      // 复用转发请求的http客户端
 }
 
 func NewServiceMediator(registryEndpoint network.Endpoint, localIp string, sidecarFactory sidecar.Factory) *ServiceMediator {
@@ -24,6 +32,8 @@ func NewServiceMediator(registryEndpoint network.Endpoint, localIp string, sidec
 		localIp:          localIp,
 		server:           http.NewServer(sidecarFactory.Create()).Listen(localIp, 80),
 		sidecarFactory:   sidecarFactory,
+		breakers:         http.DefaultCircuitBreakers(),
+		clientPool:       http.NewClientPool(sidecarFactory.Create),
 	}
 }
 
@@ -39,14 +49,14 @@ func (s *ServiceMediator) Forward(req *http.Request) *http.Response {
 			AddProblemDetails("discovery " + string(svcType) + " failed: " + err.Error())
 	}
 	forwardReq := req.Clone().AddUri(svcUri)
-	client, err := http.NewClient(s.sidecarFactory.Create(), s.localIp)
+	client, err := s.clientPool.Get(s.localIp)
 	if err != nil {
 		return http.ResponseOfId(req.ReqId()).
 			AddStatusCode(http.StatusInternalServerError).
 			AddProblemDetails("create http client failed: " + err.Error())
 	}
-	defer client.Close()
-	resp, err := client.Send(dest, forwardReq)
+	defer s.clientPool.Put(client)
+	resp, err := s.resilient(client).Send(dest, forwardReq)
 	if err != nil {
 		return http.ResponseOfId(req.ReqId()).
 			AddStatusCode(http.StatusInternalServerError).
@@ -55,11 +65,17 @@ func (s *ServiceMediator) Forward(req *http.Request) *http.Response {
 	return resp.Clone().AddReqId(req.ReqId())
 }
 
+// Breakers 返回转发请求的熔断器集合，可用于监控各对端的熔断状态
+func (s *ServiceMediator) Breakers() *http.CircuitBreakers {
+	return s.breakers
+}
+
 func (s *ServiceMediator) Run() error {
 	return s.server.Put("/", s.Forward).
 		Post("/", s.Forward).
 		Get("/", s.Forward).
 		Delete("/", s.Forward).
+		Patch("/", s.Forward).
 		Start()
 }
 
@@ -68,8 +84,9 @@ func (s *ServiceMediator) Endpoint() network.Endpoint {
 }
 
 func (s *ServiceMediator) Shutdown() error {
-	s.server.Shutdown()
-	return nil
+	err := s.server.GracefulShutdown(service.ShutdownTimeout)
+	s.clientPool.Close()
+	return err
 }
 
 func (s *ServiceMediator) svcTypeOf(mediatorUri http.Uri) model.ServiceType {
@@ -85,14 +102,14 @@ func (s *ServiceMediator) svcUriOf(mediatorUri http.Uri) http.Uri {
 
 // 根据serviceType进行服务发现
 func (s *ServiceMediator) discovery(svcType model.ServiceType) (network.Endpoint, error) {
-	client, err := http.NewClient(s.sidecarFactory.Create(), s.localIp)
+	client, err := s.clientPool.Get(s.localIp)
 	if err != nil {
 		return network.Endpoint{}, err
 	}
-	defer client.Close()
+	defer s.clientPool.Put(client)
 	req := http.EmptyRequest().AddUri("/api/v1/service-profile").
 		AddMethod(http.GET).AddQueryParam("service-type", string(svcType))
-	resp, err := client.Send(s.registryEndpoint, req)
+	resp, err := s.resilient(client).Send(s.registryEndpoint, req)
 	if err != nil {
 		return network.Endpoint{}, err
 	}
@@ -101,3 +118,9 @@ func (s *ServiceMediator) discovery(svcType model.ServiceType) (network.Endpoint
 	}
 	return resp.Body().(*model.ServiceProfile).Endpoint, nil
 }
+
+// 为连接池中的http客户端增加重试和熔断能力
+// 使用默认的重试策略，只重试幂等的请求，转发的POST、PATCH等请求有意不重试，避免在对端重复执行
+func (s *ServiceMediator) resilient(client *http.Client) *http.ResilientClient {
+	return http.NewResilientClient(client, http.BreakWith(s.breakers))
+}
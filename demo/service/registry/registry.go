@@ -4,6 +4,7 @@ import (
 	"demo/db"
 	"demo/network"
 	"demo/network/http"
+	"demo/service"
 	"demo/service/registry/model"
 	"demo/sidecar"
 	"reflect"
@@ -15,6 +16,13 @@ const (
 	subscriptionTable = "subscriptions"
 )
 
+// 注册请求和通知的body类型，抓包回放时可以还原
+func init() {
+	http.RegisterBodyType(new(model.ServiceProfile))
+	http.RegisterBodyType(new(model.Subscription))
+	http.RegisterBodyType(new(model.Notification))
+}
+
 /**
  * 单一职责原则（SRP）： 一个模块应该有且只有一个导致其变化的原因
  * SRP是聚合和拆分的一个平衡，太过聚合会导致牵一发动全身，拆分过细又会提升复杂性。
@@ -65,6 +73,5 @@ func (r *Registry) Endpoint() network.Endpoint {
 }
 
 func (r *Registry) Shutdown() error {
-	r.server.Shutdown()
-	return nil
+	return r.server.GracefulShutdown(service.ShutdownTimeout)
 }
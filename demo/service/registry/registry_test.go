@@ -6,8 +6,11 @@ import (
 	"demo/network/http"
 	"demo/service/registry/model"
 	"demo/sidecar"
+	"encoding/json"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestRegistry(t *testing.T) {
@@ -71,3 +74,120 @@ func TestRegistry(t *testing.T) {
 		t.Fatalf("want StatusNotFound got %v", dResp2.StatusCode())
 	}
 }
+
+func TestRegistryRegionCheck(t *testing.T) {
+	topology := network.NewTopology()
+	vpc1, _ := topology.AddSubnet("vpc-1", "192.168.0.0/24", "1")
+	vpc2, _ := topology.AddSubnet("vpc-2", "10.0.0.0/24", "2")
+	topology.AddRouter("router", vpc1, vpc2)
+	network.Instance().SetTopology(topology)
+	defer network.Instance().SetTopology(nil)
+
+	mdb := db.MemoryDbInstance()
+	defer mdb.Clear()
+	registry := NewRegistry("192.168.0.10", mdb, sidecar.NewRawSocketFactory())
+	if err := registry.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Shutdown()
+	client, _ := http.NewClient(network.DefaultSocket(), "10.0.0.2")
+	defer client.Close()
+
+	region := model.NewRegion("1")
+	profile := model.NewServiceProfileBuilder().WithId("svc1").WithType("svc").
+		WithStatus(model.Normal).WithRegion(region).WithEndpoint("10.0.0.2", 80).Build()
+	req := http.EmptyRequest().AddUri("/api/v1/service-profile").AddMethod(http.PUT).AddBody(profile)
+	resp, err := client.Send(registry.Endpoint(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("want StatusBadRequest got %v", resp.StatusCode())
+	}
+
+	profile.Region = model.NewRegion("2")
+	req = http.EmptyRequest().AddUri("/api/v1/service-profile").AddMethod(http.PUT).AddBody(profile)
+	resp, err = client.Send(registry.Endpoint(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusCreate {
+		t.Fatalf("want StatusCreate got %v", resp.StatusCode())
+	}
+}
+
+func TestRegistryBodyTypes(t *testing.T) {
+	profile := model.NewServiceProfileBuilder().WithId("svc1").WithType("svc").
+		WithEndpoint("192.168.0.3", 80).WithRegion(model.NewRegion("1")).Build()
+	notification := model.NewNotification("sub1")
+	notification.Profile = profile
+	// 抓包回放时body还原为原始类型
+	for _, body := range []interface{}{profile, model.NewSubscription("sub1"), notification} {
+		data, err := json.Marshal(http.EmptyRequest().AddMethod(http.POST).AddUri("/api").AddBody(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := http.EmptyRequest()
+		if err := json.Unmarshal(data, req); err != nil {
+			t.Fatal(err)
+		}
+		if reflect.TypeOf(req.Body()) != reflect.TypeOf(body) {
+			t.Errorf("want body type %T got %T", body, req.Body())
+		}
+	}
+}
+
+func TestRegistryNotifyRetry(t *testing.T) {
+	mdb := db.MemoryDbInstance()
+	defer mdb.Clear()
+	registry := NewRegistry("192.168.0.20", mdb, sidecar.NewRawSocketFactory())
+	if err := registry.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Shutdown()
+
+	// 订阅者第一次处理通知失败，第二次成功
+	var attempts int32
+	notified := make(chan *model.Notification, 1)
+	subscriber := http.NewServer(network.DefaultSocket()).Listen("192.168.0.21", 80).
+		Post("/notify", func(req *http.Request) *http.Response {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusServiceUnavailable)
+			}
+			notified <- req.Body().(*model.Notification)
+			return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusNoContent)
+		})
+	if err := subscriber.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Shutdown()
+	client, _ := http.NewClient(network.DefaultSocket(), "192.168.0.22")
+	defer client.Close()
+
+	subscription := model.NewSubscription("")
+	subscription.SrcSvcId, subscription.TargetSvcType = "subscriber", "notified"
+	subscription.NotifyUrl = "http://192.168.0.21:80/notify"
+	resp, err := client.Send(registry.Endpoint(), http.EmptyRequest().AddUri("/api/v1/subscription").
+		AddMethod(http.PUT).AddBody(subscription))
+	if err != nil || resp.StatusCode() != http.StatusCreate {
+		t.Fatalf("want StatusCreate got %v, %v", resp, err)
+	}
+	profile := model.NewServiceProfileBuilder().WithId("notified1").WithType("notified").
+		WithStatus(model.Normal).WithRegion(model.NewRegion("1")).Build()
+	resp, err = client.Send(registry.Endpoint(), http.EmptyRequest().AddUri("/api/v1/service-profile").
+		AddMethod(http.PUT).AddBody(profile))
+	if err != nil || resp.StatusCode() != http.StatusCreate {
+		t.Fatalf("want StatusCreate got %v, %v", resp, err)
+	}
+	select {
+	case notification := <-notified:
+		if notification.Type != model.Register || notification.Profile.Id != "notified1" {
+			t.Errorf("want register notification of notified1 got %+v", notification)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification not retried")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 2 {
+		t.Errorf("want 2 attempts got %d", n)
+	}
+}
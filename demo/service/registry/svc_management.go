@@ -2,6 +2,7 @@ package registry
 
 import (
 	"demo/db"
+	"demo/network"
 	"demo/network/http"
 	"demo/service/registry/model"
 	"demo/sidecar"
@@ -15,6 +16,7 @@ type svcManagement struct {
 	localIp        string
 	db             db.Db
 	sidecarFactory sidecar.Factory
+	breakers       *http.CircuitBreakers // 通知订阅者时使用的熔断器
 }
 
 func newSvcManagement(localIp string, db db.Db, sidecarFactory sidecar.Factory) *svcManagement {
@@ -22,6 +24,7 @@ func newSvcManagement(localIp string, db db.Db, sidecarFactory sidecar.Factory)
 		localIp:        localIp,
 		db:             db,
 		sidecarFactory: sidecarFactory,
+		breakers:       http.DefaultCircuitBreakers(),
 	}
 }
 
@@ -33,6 +36,10 @@ func (s *svcManagement) register(req *http.Request) *http.Response {
 			AddStatusCode(http.StatusBadRequest).
 			AddProblemDetails("service register request's body is not *ServiceProfile")
 	}
+	if err := s.checkRegion(profile); err != nil {
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusBadRequest).
+			AddProblemDetails(err.Error())
+	}
 	transaction := s.db.CreateTransaction("register" + profile.Id)
 	transaction.Begin()
 	region := new(model.Region)
@@ -61,6 +68,10 @@ func (s *svcManagement) update(req *http.Request) *http.Response {
 			AddStatusCode(http.StatusBadRequest).
 			AddProblemDetails("service update request's body is not *ServiceProfile")
 	}
+	if err := s.checkRegion(profile); err != nil {
+		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusBadRequest).
+			AddProblemDetails(err.Error())
+	}
 	transaction := s.db.CreateTransaction("register" + profile.Id)
 	transaction.Begin()
 	// 先更新regions表
@@ -145,6 +156,26 @@ func (s *svcManagement) unsubscribe(req *http.Request) *http.Response {
 	return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusNoContent)
 }
 
+// 配置了网络拓扑时，校验服务的region与其endpoint所在子网的region一致
+func (s *svcManagement) checkRegion(profile *model.ServiceProfile) error {
+	topology := network.Instance().Topology()
+	if topology == nil || profile.Region == nil {
+		return nil
+	}
+	region, ok := topology.RegionOf(profile.Endpoint.Ip())
+	if !ok || region == profile.Region.Id {
+		return nil
+	}
+	return fmt.Errorf("service %s region %s mismatch with network region %s", profile.Id, profile.Region.Id, region)
+}
+
+// notifyRetryPolicy 通知携带订阅id，订阅者重复收到同一通知也不会出错，因此POST的通知请求也需要重试
+func notifyRetryPolicy() http.RetryPolicy {
+	policy := http.DefaultRetryPolicy()
+	policy.RetryMethods = []http.Method{http.POST}
+	return policy
+}
+
 // 服务通知
 func (s *svcManagement) notify(notifyType model.NotifyType, profile *model.ServiceProfile) {
 	visitor := model.NewSubscriptionVisitor(profile.Id, profile.Type)
@@ -153,11 +184,14 @@ func (s *svcManagement) notify(notifyType model.NotifyType, profile *model.Servi
 		fmt.Println(err.Error())
 		return
 	}
-	httpClient, err := http.NewClient(s.sidecarFactory.Create(), s.localIp)
+	client, err := http.NewClient(s.sidecarFactory.Create(), s.localIp)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
+	httpClient := http.NewResilientClient(client, http.BreakWith(s.breakers), http.RetryWith(notifyRetryPolicy()))
+	defer httpClient.Close()
+	// 某个订阅者通知失败时，继续通知其他订阅者
 	for _, record := range result {
 		subscription := record.(*model.Subscription)
 		notification := model.NewNotification(subscription.Id)
@@ -166,18 +200,18 @@ func (s *svcManagement) notify(notifyType model.NotifyType, profile *model.Servi
 		notifyUri, err := subscription.NotifyUri()
 		if err != nil {
 			fmt.Println(err.Error())
-			return
+			continue
 		}
 		notifyEndpoint, err := subscription.NotifyEndpoint()
 		if err != nil {
 			fmt.Println(err.Error())
-			return
+			continue
 		}
 		req := http.EmptyRequest().AddUri(http.Uri(notifyUri)).AddMethod(http.POST).AddBody(notification)
 		resp, err := httpClient.Send(notifyEndpoint, req)
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			fmt.Printf("notify %s failed: %s\n", subscription.SrcSvcId, err.Error())
+			continue
 		}
 		fmt.Printf("notify %s success, resp %+v", subscription.SrcSvcId, resp)
 	}
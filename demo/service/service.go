@@ -1,12 +1,18 @@
 package service
 
-import "demo/network"
+import (
+	"demo/network"
+	"time"
+)
+
+// ShutdownTimeout 服务优雅停机时，等待处理中请求完成的最长时间
+const ShutdownTimeout = 3 * time.Second
 
 type Service interface {
 	// Run 运行服务
 	Run() error
 	// Endpoint 返回服务对外提供服务的endpoint
 	Endpoint() network.Endpoint
-	// Shutdown 停止服务
+	// Shutdown 停止服务，不再接收新请求，并在ShutdownTimeout内等待处理中的请求完成
 	Shutdown() error
 }
@@ -3,6 +3,7 @@ package shopping
 import (
 	"demo/network"
 	"demo/network/http"
+	"demo/service"
 	"demo/service/registry/model"
 	"demo/sidecar"
 	"fmt"
@@ -48,8 +49,7 @@ func (s *ServiceTemplate) Endpoint() network.Endpoint {
 }
 
 func (s *ServiceTemplate) Shutdown() error {
-	s.server.Shutdown()
-	return nil
+	return s.server.GracefulShutdown(service.ShutdownTimeout)
 }
 
 func (s *ServiceTemplate) WithLocalIp(localIp string) *ServiceTemplate {
@@ -3,6 +3,7 @@ package shopping
 import (
 	"demo/network"
 	"demo/network/http"
+	"demo/service"
 	"demo/sidecar"
 	"fmt"
 )
@@ -16,6 +17,7 @@ type Center struct {
 	localIp                 string
 	sidecarFactory          sidecar.Factory
 	serviceMediatorEndpoint network.Endpoint
+	clientPool              *http.ClientPool
 }
 
 func NewCenter(localIp string, factory sidecar.Factory, mediatorEndpoint network.Endpoint) *Center {
@@ -24,6 +26,7 @@ func NewCenter(localIp string, factory sidecar.Factory, mediatorEndpoint network
 		localIp:                 localIp,
 		sidecarFactory:          factory,
 		serviceMediatorEndpoint: mediatorEndpoint,
+		clientPool:              http.NewClientPool(factory.Create),
 	}
 }
 
@@ -36,8 +39,9 @@ func (c *Center) Endpoint() network.Endpoint {
 }
 
 func (c *Center) Shutdown() error {
-	c.server.Shutdown()
-	return nil
+	err := c.server.GracefulShutdown(service.ShutdownTimeout)
+	c.clientPool.Close()
+	return err
 }
 
 func (c *Center) buy(req *http.Request) *http.Response {
@@ -53,12 +57,12 @@ func (c *Center) buy(req *http.Request) *http.Response {
 	}
 	fmt.Printf("\nuser %s start to buy good %s.\n", user, goods)
 
-	client, err := http.NewClient(c.sidecarFactory.Create(), c.localIp)
+	client, err := c.clientPool.Get(c.localIp)
 	if err != nil {
 		return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusInternalServerError).
 			AddProblemDetails(err.Error())
 	}
-	defer client.Close()
+	defer c.clientPool.Put(client)
 
 	fmt.Println("\nshopping center send create order request to order service.")
 	orderReq := http.EmptyRequest().AddUri("/order-service/api/v1/order").AddMethod(http.PUT)
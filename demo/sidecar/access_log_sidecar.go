@@ -5,6 +5,28 @@ import (
 	"demo/network"
 	"demo/network/http"
 	"fmt"
+	"strconv"
+	"time"
+)
+
+// AccessLogType access log的类型
+type AccessLogType string
+
+const (
+	RecvReq  AccessLogType = "recv_req"  // 接收请求
+	RecvResp AccessLogType = "recv_resp" // 接收响应
+	SendReq  AccessLogType = "send_req"  // 发送请求
+	SendResp AccessLogType = "send_resp" // 发送响应
+)
+
+// 结构化access log消息的header，监控系统据此提取监控记录
+const (
+	EndpointHeader = "endpoint"
+	TypeHeader     = "type"
+	PeerHeader     = "peer"
+	ReqIdHeader    = "reqId"
+	StatusHeader   = "status" // 响应的状态码
+	TimeHeader     = "time"   // 记录产生的时间，unix纳秒
 )
 
 // AccessLogSidecar HTTP access log修饰器，拦截socket接收和发送报文，上报access log到Mq上，供监控系统统计分析
@@ -31,33 +53,52 @@ func (a *AccessLogSidecar) Close(endpoint network.Endpoint) {
 }
 
 func (a *AccessLogSidecar) Send(packet *network.Packet) error {
-	if _, ok := packet.Payload().(*http.Request); ok {
+	if req, ok := packet.Payload().(*http.Request); ok {
 		accessLog := fmt.Sprintf("[%s][SEND_REQ]send http request to %s", packet.Src(), packet.Dest())
-		message := mq.NewMessage(a.topic, accessLog)
-		a.producer.Produce(message)
+		a.producer.Produce(a.messageOf(accessLog, packet.Src(), packet.Dest(), SendReq).
+			AddHeader(ReqIdHeader, reqIdOf(req.ReqId())))
 	}
-	if _, ok := packet.Payload().(*http.Response); ok {
+	if resp, ok := packet.Payload().(*http.Response); ok {
 		accessLog := fmt.Sprintf("[%s][SEND_RESP]send http response to %s", packet.Src(), packet.Dest())
-		message := mq.NewMessage(a.topic, accessLog)
-		a.producer.Produce(message)
+		a.producer.Produce(a.responseMessageOf(accessLog, packet.Src(), packet.Dest(), SendResp, resp))
 	}
 	return a.socket.Send(packet)
 }
 
 func (a *AccessLogSidecar) Receive(packet *network.Packet) {
-	if _, ok := packet.Payload().(*http.Request); ok {
+	if req, ok := packet.Payload().(*http.Request); ok {
 		accessLog := fmt.Sprintf("[%s][RECV_REQ]receive http request from %s", packet.Dest(), packet.Src())
-		message := mq.NewMessage(a.topic, accessLog)
-		a.producer.Produce(message)
+		a.producer.Produce(a.messageOf(accessLog, packet.Dest(), packet.Src(), RecvReq).
+			AddHeader(ReqIdHeader, reqIdOf(req.ReqId())))
 	}
-	if _, ok := packet.Payload().(*http.Response); ok {
+	if resp, ok := packet.Payload().(*http.Response); ok {
 		accessLog := fmt.Sprintf("[%s][RECV_RESP]receive http response from %s", packet.Dest(), packet.Src())
-		message := mq.NewMessage(a.topic, accessLog)
-		a.producer.Produce(message)
+		a.producer.Produce(a.responseMessageOf(accessLog, packet.Dest(), packet.Src(), RecvResp, resp))
 	}
 	a.socket.Receive(packet)
 }
 
+// messageOf 生成结构化的access log消息，以endpoint作为key，保证同一endpoint的日志顺序消费
+func (a *AccessLogSidecar) messageOf(accessLog string, endpoint, peer network.Endpoint, logType AccessLogType) *mq.Message {
+	return mq.NewMessage(a.topic, accessLog).
+		WithKey(endpoint.String()).
+		AddHeader(EndpointHeader, endpoint.String()).
+		AddHeader(TypeHeader, string(logType)).
+		AddHeader(PeerHeader, peer.String()).
+		AddHeader(TimeHeader, strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+// responseMessageOf 响应的access log额外带上请求id和状态码，用于关联请求计算时延和错误率
+func (a *AccessLogSidecar) responseMessageOf(accessLog string, endpoint, peer network.Endpoint, logType AccessLogType, resp *http.Response) *mq.Message {
+	return a.messageOf(accessLog, endpoint, peer, logType).
+		AddHeader(ReqIdHeader, reqIdOf(resp.ReqId())).
+		AddHeader(StatusHeader, strconv.Itoa(int(resp.StatusCode().Code)))
+}
+
+func reqIdOf(reqId http.ReqId) string {
+	return strconv.FormatUint(uint64(reqId), 10)
+}
+
 func (a *AccessLogSidecar) AddListener(listener network.SocketListener) {
 	a.socket.AddListener(listener)
 }
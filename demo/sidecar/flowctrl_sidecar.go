@@ -42,10 +42,11 @@ func (f *FlowCtrlSidecar) Receive(packet *network.Packet) {
 		f.socket.Receive(packet)
 		return
 	}
-	// 流控后返回429 Too Many Request响应
+	// 流控后返回429 Too Many Request响应，流控状态每秒更新一次，因此建议客户端1s后重试
 	if !f.ctx.TryAccept() {
 		httpResp := http.ResponseOfId(httpReq.ReqId()).
 			AddStatusCode(http.StatusTooManyRequest).
+			AddHeader(http.RetryAfterHeader, "1").
 			AddProblemDetails("enter flow ctrl state")
 		f.socket.Send(network.NewPacket(packet.Dest(), packet.Src(), httpResp))
 		return
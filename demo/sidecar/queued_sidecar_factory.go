@@ -0,0 +1,28 @@
+package sidecar
+
+import (
+	"demo/network"
+	"demo/network/http"
+)
+
+// QueuedFactory 为其他工厂创建的sidecar增加有界接收队列和worker池，队列满时回复HTTP 503
+type QueuedFactory struct {
+	factory  Factory
+	capacity int
+	workers  int
+	policy   network.OverflowPolicy
+}
+
+func NewQueuedFactory(factory Factory, capacity, workers int, policy network.OverflowPolicy) *QueuedFactory {
+	return &QueuedFactory{
+		factory:  factory,
+		capacity: capacity,
+		workers:  workers,
+		policy:   policy,
+	}
+}
+
+func (q QueuedFactory) Create() network.Socket {
+	return network.NewQueuedSocket(q.factory.Create(), q.capacity, q.workers, q.policy).
+		WithRejecter(http.RejectWithServiceUnavailable)
+}
@@ -0,0 +1,57 @@
+package sidecar
+
+import (
+	"demo/network"
+	"demo/network/http"
+	"testing"
+	"time"
+)
+
+func TestQueuedSidecar(t *testing.T) {
+	release := make(chan struct{})
+	socket := NewQueuedFactory(NewRawSocketFactory(), 1, 1, network.RejectOnOverflow).Create()
+	server := http.NewServer(socket).Listen("192.168.6.1", 80).
+		Get("/block", func(req *http.Request) *http.Response {
+			<-release
+			return http.ResponseOfId(req.ReqId()).AddStatusCode(http.StatusOk)
+		})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown()
+	dest := network.EndpointOf("192.168.6.1", 80)
+
+	// 第一个请求占用唯一的worker，第二个请求在队列中等待
+	respChan := make(chan *http.Response, 2)
+	for i := 0; i < 2; i++ {
+		client, _ := http.NewClient(network.DefaultSocket(), "192.168.6.2")
+		defer client.Close()
+		go func() {
+			resp, _ := client.Send(dest, http.EmptyRequest().AddMethod(http.GET).AddUri("/block"))
+			respChan <- resp
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	metrics := socket.(*network.QueuedSocket).Metrics()
+	if metrics.Depth != 1 || metrics.Capacity != 1 {
+		t.Errorf("want queue depth 1 capacity 1 got %+v", metrics)
+	}
+
+	// 队列已满，第三个请求被拒绝
+	client, _ := http.NewClient(network.DefaultSocket(), "192.168.6.3")
+	defer client.Close()
+	resp, _ := client.Send(dest, http.EmptyRequest().AddMethod(http.GET).AddUri("/block"))
+	if resp.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("want StatusServiceUnavailable got %v", resp.StatusCode())
+	}
+	if metrics := socket.(*network.QueuedSocket).Metrics(); metrics.Rejected != 1 {
+		t.Errorf("want 1 rejected got %+v", metrics)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if resp := <-respChan; resp.StatusCode() != http.StatusOk {
+			t.Errorf("want StatusOk got %v", resp.StatusCode())
+		}
+	}
+}